@@ -0,0 +1,288 @@
+// Package adif reads the ADIF log files written by logging software, in the
+// tagged .adi format and the XML .adx format.
+package adif
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Format type
+type Format int
+
+// ADIF formats
+const (
+	// ADI is the tagged format, <CALL:6>YB0AAA <EOR>
+	ADI Format = iota
+	// ADX is the XML format
+	ADX
+)
+
+// FormatOf function guesses the format from a file name, .adx is ADX and
+// everything else ADI
+func FormatOf(fileName string) Format {
+	if strings.EqualFold(filepath.Ext(fileName), ".adx") {
+		return ADX
+	}
+	return ADI
+}
+
+// Record type is one QSO, field names are lower case
+type Record struct {
+	Line   int
+	Fields map[string]string
+}
+
+// Get method returns the value of a field, name is case insensitive
+func (record Record) Get(name string) string {
+	return strings.TrimSpace(record.Fields[strings.ToLower(name)])
+}
+
+// Log type is the content of an ADIF file. Records holds the records that
+// could be read, Errors one *ParseError per record or tag that could not.
+type Log struct {
+	Header  map[string]string
+	Records []Record
+	Errors  []error
+}
+
+// ParseError type
+type ParseError struct {
+	Line  int
+	Field string
+	Err   error
+}
+
+// Error method
+func (e *ParseError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %s: %v", e.Line, e.Field, e.Err)
+}
+
+// Unwrap method
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Parse errors
+var (
+	ErrMalformedTag = errors.New("malformed tag")
+	ErrTruncated    = errors.New("data shorter than its length")
+	ErrNoRecords    = errors.New("no records")
+)
+
+// Read function reads a whole ADIF file. A malformed record is skipped and
+// reported in Log.Errors, the returned error is only set when r fails or the
+// file is not ADIF at all.
+func Read(r io.Reader, format Format) (*Log, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var log *Log
+	if format == ADX {
+		log, err = readADX(data)
+	} else {
+		log = readADI(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(log.Records) == 0 && len(log.Errors) == 0 {
+		return log, ErrNoRecords
+	}
+	return log, nil
+}
+
+// readADI parses the tagged format
+func readADI(data []byte) *Log {
+	log := &Log{Header: map[string]string{}}
+
+	// a header is present when the file does not start with a tag
+	fields := map[string]string{}
+	inHeader := len(bytes.TrimSpace(data)) > 0 && bytes.TrimSpace(data)[0] != '<'
+	recordLine := 0
+	recordBroken := false
+
+	line := 1
+	pos := 0
+	advance := func(to int) {
+		line += bytes.Count(data[pos:to], []byte{'\n'})
+		pos = to
+	}
+
+	for {
+		start := bytes.IndexByte(data[pos:], '<')
+		if start < 0 {
+			break
+		}
+		advance(pos + start)
+
+		end := bytes.IndexByte(data[pos:], '>')
+		if end < 0 {
+			log.Errors = append(log.Errors, &ParseError{Line: line, Err: ErrMalformedTag})
+			break
+		}
+		tag := string(data[pos+1 : pos+end])
+		tagLine := line
+		advance(pos + end + 1)
+
+		parts := strings.Split(tag, ":")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+
+		switch {
+		case name == "eoh" && len(parts) == 1:
+			for key, value := range fields {
+				log.Header[key] = value
+			}
+			fields = map[string]string{}
+			inHeader = false
+			recordBroken = false
+			continue
+		case name == "eor" && len(parts) == 1:
+			if !recordBroken && len(fields) > 0 {
+				log.Records = append(log.Records, Record{Line: recordLine, Fields: fields})
+			}
+			fields = map[string]string{}
+			recordBroken = false
+			continue
+		}
+
+		if len(fields) == 0 && !recordBroken {
+			recordLine = tagLine
+		}
+
+		if len(parts) < 2 || name == "" {
+			log.Errors = append(log.Errors, &ParseError{Line: tagLine, Field: name, Err: ErrMalformedTag})
+			recordBroken = !inHeader
+			continue
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || length < 0 {
+			log.Errors = append(log.Errors, &ParseError{Line: tagLine, Field: name, Err: fmt.Errorf("%w: bad length %q", ErrMalformedTag, parts[1])})
+			recordBroken = !inHeader
+			continue
+		}
+		if pos+length > len(data) {
+			log.Errors = append(log.Errors, &ParseError{Line: tagLine, Field: name, Err: ErrTruncated})
+			break
+		}
+
+		fields[name] = string(data[pos : pos+length])
+		advance(pos + length)
+	}
+
+	return log
+}
+
+// readADX parses the XML format
+func readADX(data []byte) (*Log, error) {
+	log := &Log{Header: map[string]string{}}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var fields map[string]string
+	var section string
+	recordLine := 0
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return log, nil
+		}
+		if err != nil {
+			line, _ := decoder.InputPos()
+			if len(log.Records) == 0 && section == "" {
+				return nil, err
+			}
+			log.Errors = append(log.Errors, &ParseError{Line: line, Err: err})
+			return log, nil
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		name := strings.ToLower(start.Name.Local)
+		switch name {
+		case "adx":
+			continue
+		case "header", "records":
+			section = name
+			continue
+		case "record":
+			recordLine, _ = decoder.InputPos()
+			fields = map[string]string{}
+			if err := readADXRecord(decoder, fields); err != nil {
+				log.Errors = append(log.Errors, &ParseError{Line: recordLine, Err: err})
+				continue
+			}
+			log.Records = append(log.Records, Record{Line: recordLine, Fields: fields})
+			continue
+		}
+
+		if section == "header" {
+			var value string
+			if err := decoder.DecodeElement(&value, &start); err != nil {
+				line, _ := decoder.InputPos()
+				log.Errors = append(log.Errors, &ParseError{Line: line, Field: name, Err: err})
+				continue
+			}
+			log.Header[adxFieldName(start)] = value
+		}
+	}
+}
+
+// readADXRecord reads the fields of a RECORD element up to its end
+func readADXRecord(decoder *xml.Decoder, fields map[string]string) error {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			var value string
+			if err := decoder.DecodeElement(&value, &element); err != nil {
+				return err
+			}
+			fields[adxFieldName(element)] = value
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// adxFieldName returns the ADI name of an ADX element, APP and USERDEF
+// elements carry their name in attributes
+func adxFieldName(element xml.StartElement) string {
+	name := strings.ToLower(element.Name.Local)
+	attr := func(key string) string {
+		for _, a := range element.Attr {
+			if strings.EqualFold(a.Name.Local, key) {
+				return strings.ToLower(a.Value)
+			}
+		}
+		return ""
+	}
+
+	switch name {
+	case "app":
+		return "app_" + attr("programid") + "_" + attr("fieldname")
+	case "userdef":
+		if fieldName := attr("fieldname"); fieldName != "" {
+			return fieldName
+		}
+	}
+	return name
+}
@@ -0,0 +1,136 @@
+package adif
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadADI(t *testing.T) {
+	data := "exported log <ADIF_VER:5>3.1.4 <EOH>\n" +
+		"<CALL:6>YB0AAA <QSO_DATE:8>20260301 <TIME_ON:4>1230 <FREQ:5>7.135 <MODE:3>SSB <EOR>\n" +
+		"<call:6>yb1bbb <Name:4>Budi <EOR>\n" +
+		"<CALL:6>YC2CCC <QSO_DATE:x>20260302 <EOR>\n"
+
+	log, err := Read(strings.NewReader(data), ADI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Records) != 2 || len(log.Errors) != 1 {
+		t.Fatalf("got %+v, want 2 records and 1 error", log)
+	}
+	for _, c := range []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"header", log.Header["adif_ver"], "3.1.4"},
+		{"first line", log.Records[0].Line, 2},
+		{"second line", log.Records[1].Line, 3},
+		{"upper case field", log.Records[0].Get("FREQ"), "7.135"},
+		{"lower case field", log.Records[1].Get("name"), "Budi"},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	// the bad length of line 4
+	var parseErr *ParseError
+	if !errors.As(log.Errors[0], &parseErr) || !errors.Is(parseErr, ErrMalformedTag) {
+		t.Fatalf("got %v, want a malformed tag", log.Errors[0])
+	}
+	if parseErr.Line != 4 || parseErr.Field != "qso_date" {
+		t.Fatalf("got line %d field %s, want the QSO_DATE of line 4", parseErr.Line, parseErr.Field)
+	}
+}
+
+func TestReadADIErrors(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		data    string
+		records int
+		err     error
+	}{
+		{"truncated field", "<CALL:6>YB0AAA <EOR>\n<CALL:10>YB1", 1, ErrTruncated},
+		{"no tags", "no tags at all", 0, ErrNoRecords},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			log, err := Read(strings.NewReader(c.data), ADI)
+			if err == nil && len(log.Errors) > 0 {
+				err = log.Errors[0]
+			}
+			if !errors.Is(err, c.err) {
+				t.Fatalf("got %v, want %v", err, c.err)
+			}
+			if log != nil && len(log.Records) != c.records {
+				t.Fatalf("got %d records, want %d", len(log.Records), c.records)
+			}
+		})
+	}
+}
+
+func TestReadADX(t *testing.T) {
+	data := `<?xml version="1.0"?>
+<ADX>
+  <HEADER><ADIF_VER>3.1.4</ADIF_VER></HEADER>
+  <RECORDS>
+    <RECORD>
+      <CALL>YB0AAA</CALL><QSO_DATE>20260301</QSO_DATE><MODE>SSB</MODE>
+      <APP PROGRAMID="LOGGER" FIELDNAME="X" TYPE="S">v</APP>
+    </RECORD>
+    <RECORD><CALL>YB1BBB</CALL></RECORD>
+  </RECORDS>
+</ADX>`
+
+	log, err := Read(strings.NewReader(data), ADX)
+	if err != nil || len(log.Records) != 2 || log.Header["adif_ver"] != "3.1.4" {
+		t.Fatalf("got %+v (%v), want 2 records", log, err)
+	}
+	record := log.Records[0]
+	for _, c := range []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"call", record.Get("call"), "YB0AAA"},
+		{"line", record.Line, 5},
+		{"APP field", record.Get("app_logger_x"), "v"},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	for name, want := range map[string]Format{"log.adi": ADI, "LOG.ADX": ADX, "log.txt": ADI} {
+		if got := FormatOf(name); got != want {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer := NewWriter(&out)
+	if err := writer.WriteHeader("", Field{Name: "PROGRAMID", Value: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	when := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	writer.WriteRecord(Field{Name: "call", Value: "YB0AAA"}, Field{Name: "qso_date", Value: Date(when)}, Field{Name: "time_on", Value: Time(when)}, Field{Name: "name"})
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if writer.Records() != 1 {
+		t.Fatalf("got %d records, want 1", writer.Records())
+	}
+	if !strings.Contains(out.String(), "<CALL:6>YB0AAA <QSO_DATE:8>20260301 <TIME_ON:6>123000 <EOR>\n") {
+		t.Fatalf("got %q, want the record without the empty NAME", out.String())
+	}
+
+	log, err := Read(&out, ADI)
+	if err != nil || log.Header["programid"] != "test" || log.Header["adif_ver"] != Version || len(log.Records) != 1 {
+		t.Fatalf("got %+v (%v), want the header and the record back", log, err)
+	}
+}
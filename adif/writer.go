@@ -0,0 +1,108 @@
+package adif
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version written in the header
+const Version = "3.1.4"
+
+// Field type is one field of a record, written in the order given
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Writer type writes the tagged .adi format
+type Writer struct {
+	buf     *bufio.Writer
+	records int64
+}
+
+// NewWriter function
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{buf: bufio.NewWriter(w)}
+}
+
+// WriteHeader method writes the header, text is the free text line on top.
+// ADIF_VER, CREATED_TIMESTAMP and PROGRAMID are added when missing.
+func (writer *Writer) WriteHeader(text string, fields ...Field) error {
+	defaults := []Field{
+		{Name: "ADIF_VER", Value: Version},
+		{Name: "CREATED_TIMESTAMP", Value: time.Now().UTC().Format("20060102 150405")},
+		{Name: "PROGRAMID", Value: "gomongo"},
+	}
+	for _, field := range defaults {
+		if !hasField(fields, field.Name) {
+			fields = append(fields, field)
+		}
+	}
+
+	if text == "" {
+		text = "ADIF export"
+	}
+	// the header text must not start with a tag
+	writer.buf.WriteString(strings.TrimLeft(text, "<") + "\n")
+	for _, field := range fields {
+		writer.writeField(field)
+		writer.buf.WriteByte('\n')
+	}
+	_, err := writer.buf.WriteString("<EOH>\n")
+	return err
+}
+
+// WriteRecord method writes one record, empty fields are left out
+func (writer *Writer) WriteRecord(fields ...Field) error {
+	for _, field := range fields {
+		if field.Value == "" {
+			continue
+		}
+		writer.writeField(field)
+		writer.buf.WriteByte(' ')
+	}
+	if _, err := writer.buf.WriteString("<EOR>\n"); err != nil {
+		return err
+	}
+	writer.records++
+	return nil
+}
+
+// writeField writes <NAME:length>value, the length counts bytes
+func (writer *Writer) writeField(field Field) {
+	writer.buf.WriteString("<" + strings.ToUpper(field.Name) + ":" + strconv.Itoa(len(field.Value)) + ">")
+	writer.buf.WriteString(field.Value)
+}
+
+// Flush method
+func (writer *Writer) Flush() error {
+	return writer.buf.Flush()
+}
+
+// Records method returns the number of records written
+func (writer *Writer) Records() int64 {
+	return writer.records
+}
+
+// Date function formats t as an ADIF date
+func Date(t time.Time) string {
+	return t.UTC().Format("20060102")
+}
+
+// Time function formats t as an ADIF time
+func Time(t time.Time) string {
+	return t.UTC().Format("150405")
+}
+
+// hasField reports whether fields has name
+func hasField(fields []Field, name string) bool {
+	for _, field := range fields {
+		if strings.EqualFold(field.Name, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package gomongo
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/gomongo/adif"
+	"github.com/agustadewa/gomongo/modes"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExportFilter type narrows an export, zero values keep everything.
+// Modes match every spelling of the mode, From is inclusive and To exclusive.
+type ExportFilter struct {
+	Bands []string
+	Modes []string
+	From  time.Time
+	To    time.Time
+}
+
+// match returns the $match of the filter on the flattened QSOs
+func (filter ExportFilter) match() bson.M {
+	match := bson.M{"date": bson.M{"$exists": true}}
+	if len(filter.Bands) > 0 {
+		match["band"] = bson.M{"$in": filter.Bands}
+	}
+	if len(filter.Modes) > 0 {
+		match["mode"] = modes.Filter(filter.Modes...)
+	}
+
+	// dates are unix milliseconds strings, all 13 digits long, so they
+	// compare as strings in the same order as numbers
+	date := bson.M{"$exists": true}
+	if !filter.From.IsZero() {
+		date["$gte"] = millisString(filter.From)
+	}
+	if !filter.To.IsZero() {
+		date["$lt"] = millisString(filter.To)
+	}
+	match["date"] = date
+	return match
+}
+
+// millisString returns t in the unix milliseconds string stored in attributes
+func millisString(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
+
+// timeFromMillis reverses millisString
+func timeFromMillis(millis int64) time.Time {
+	return time.Unix(0, millis*int64(time.Millisecond)).UTC()
+}
+
+// exportedQSO is one document of eventQSOPipeline
+type exportedQSO struct {
+	CallSign   string `bson:"call_sign"`
+	LoggedCall string `bson:"logged_call"`
+	Name       string `bson:"name"`
+	Frequency  string `bson:"frequency"`
+	Band       string `bson:"band"`
+	Mode       string `bson:"mode"`
+	RST        string `bson:"rst"`
+	Date       string `bson:"date"`
+	Grid       string `bson:"grid"`
+}
+
+// loggedCall returns the call sign as logged, the call sign of a QSO
+// stored without logged_call
+func (qso exportedQSO) loggedCall() string {
+	if qso.LoggedCall != "" {
+		return qso.LoggedCall
+	}
+	return qso.CallSign
+}
+
+// adifFields returns the ADIF fields of the QSO
+func (qso exportedQSO) adifFields() ([]adif.Field, error) {
+	millis, err := strconv.ParseInt(qso.Date, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	date := timeFromMillis(millis)
+
+	// a stored submode is written as its mode and SUBMODE
+	mode, submode, err := modes.Normalize(qso.Mode)
+	if err != nil {
+		mode, submode = strings.ToUpper(qso.Mode), ""
+	}
+
+	return []adif.Field{
+		{Name: "CALL", Value: qso.loggedCall()},
+		{Name: "QSO_DATE", Value: adif.Date(date)},
+		{Name: "TIME_ON", Value: adif.Time(date)},
+		{Name: "BAND", Value: strings.ToLower(qso.Band)},
+		{Name: "FREQ", Value: qso.Frequency},
+		{Name: "MODE", Value: mode},
+		{Name: "SUBMODE", Value: submode},
+		{Name: "RST_SENT", Value: qso.RST},
+		{Name: "NAME", Value: qso.Name},
+		{Name: "GRIDSQUARE", Value: qso.Grid},
+	}, nil
+}
+
+// ExportADIF method writes the QSOs of eventID matching filter to w as an
+// ADIF file, in date order, reading them one at a time from the cursor.
+// It returns the number of records written.
+func (adaptor *Adaptor) ExportADIF(ctx context.Context, eventID string, filter ExportFilter, w io.Writer) (int64, error) {
+	return ExportADIF(ctx, adaptor, eventID, filter, w)
+}
+
+// ExportADIF function, see Adaptor.ExportADIF
+func ExportADIF(ctx context.Context, store Querier, eventID string, filter ExportFilter, w io.Writer) (int64, error) {
+	var event models.Event
+	OID, err := objectID("ExportADIF", eventID)
+	if err != nil {
+		return 0, err
+	}
+	if err := store.QueryFindV2(ctx, models.CollEvent, &options.FindOneOptions{}, bson.M{"_id": OID}, &event); err != nil {
+		return 0, err
+	}
+
+	writer := adif.NewWriter(w)
+	if err := writer.WriteHeader("QSO log of " + event.Name); err != nil {
+		return 0, err
+	}
+
+	pipeline := eventQSOPipeline(eventID, event.Name).
+		Match(filter.match()).
+		Sort(SortKey{Key: "date", Order: 1}, SortKey{Key: "call_sign", Order: 1})
+
+	err = store.QueryAggregateEach(ctx, models.CollIdentity, options.Aggregate().SetAllowDiskUse(true), pipeline.Stages(), func(raw bson.Raw) error {
+		var qso exportedQSO
+		if err := bson.Unmarshal(raw, &qso); err != nil {
+			return wrapError("ExportADIF", err)
+		}
+		fields, err := qso.adifFields()
+		if err != nil {
+			return validationError("ExportADIF", "%s: bad date %q", qso.CallSign, qso.Date)
+		}
+		return writer.WriteRecord(fields...)
+	})
+	if err != nil {
+		return writer.Records(), err
+	}
+
+	return writer.Records(), writer.Flush()
+}
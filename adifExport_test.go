@@ -0,0 +1,51 @@
+package gomongo
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestExportADIF(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	eventID := primitive.NewObjectID()
+	if _, err := store.QueryInsertV3(ctx, models.CollEvent, bson.M{"_id": eventID, "name": "Field Day"}); err != nil {
+		t.Fatal(err)
+	}
+	importQSOs(t, store, eventID.Hex(),
+		QSO{CallSign: "YB1BBB", Frequency: "14.2", Band: "20m", Mode: "CW", RST: "599", Date: time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)},
+		QSO{CallSign: "YB0AAA", Name: "Ani", Frequency: "7.135", Band: "40m", Mode: "SSB", RST: "59", Date: time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)},
+		QSO{CallSign: "YC2CCC", Frequency: "14.080", Mode: "ft4", Date: time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC)},
+	)
+
+	for _, c := range []struct {
+		name    string
+		filter  ExportFilter
+		written int64
+		want    string
+	}{
+		{"every QSO in date order", ExportFilter{}, 3,
+			"<EOH>\n<CALL:6>YB0AAA <QSO_DATE:8>20260301 <TIME_ON:6>123000 <BAND:3>40m <FREQ:5>7.135 <MODE:3>SSB <RST_SENT:2>59 <NAME:3>Ani <EOR>\n<CALL:6>YB1BBB"},
+		{"CW from March 2", ExportFilter{Modes: []string{"CW"}, From: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)}, 1, "<CALL:6>YB1BBB"},
+		{"FT4 as MFSK", ExportFilter{Modes: []string{"MFSK"}}, 1, "<MODE:4>MFSK <SUBMODE:3>FT4"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+			written, err := ExportADIF(ctx, store, eventID.Hex(), c.filter, &out)
+			if err != nil || written != c.written {
+				t.Fatalf("got %d records (%v), want %d", written, err, c.written)
+			}
+			if !strings.Contains(out.String(), c.want) {
+				t.Fatalf("got %s, want %q in it", out.String(), c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/gomongo/adif"
+	"github.com/agustadewa/gomongo/bandplan"
+)
+
+// QSOFromADIF function maps an ADIF record onto a QSO. The frequency is
+// kept in MHz as in the attributes, the mode is the SUBMODE when there is
+// one, the RST is the report sent and the station the STATION_CALLSIGN.
+func QSOFromADIF(record adif.Record) (QSO, error) {
+	mode := record.Get("submode")
+	if mode == "" {
+		mode = record.Get("mode")
+	}
+	qso := QSO{
+		CallSign: strings.ToUpper(record.Get("call")),
+		Name:     record.Get("name"),
+		Band:     strings.ToLower(record.Get("band")),
+		Mode:     strings.ToUpper(mode),
+		RST:      record.Get("rst_sent"),
+		Grid:     record.Get("gridsquare"),
+		Station:  strings.ToUpper(record.Get("station_callsign")),
+		Line:     record.Line,
+	}
+
+	if freq := record.Get("freq"); freq != "" {
+		frequency, err := bandplan.Parse(freq + " MHz")
+		if err != nil {
+			return qso, &adif.ParseError{Line: record.Line, Field: "freq", Err: err}
+		}
+		qso.Frequency = frequency.String()
+	}
+
+	date, err := adifTime(record.Get("qso_date"), record.Get("time_on"))
+	if err != nil {
+		return qso, &adif.ParseError{Line: record.Line, Field: "qso_date", Err: err}
+	}
+	qso.Date = date
+
+	return qso, nil
+}
+
+// adifTime parses an ADIF date YYYYMMDD and time HHMM or HHMMSS, in UTC
+func adifTime(date, clock string) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, errors.New("date is missing")
+	}
+
+	switch len(clock) {
+	case 0:
+		clock = "000000"
+	case 4:
+		clock += "00"
+	}
+	return time.Parse("20060102150405", date+clock)
+}
+
+// ImportADIF function reads an ADIF log and imports its QSOs into eventID,
+// see ImportQSOs. The errors of records that could not be read are part of
+// the result errors, with their line.
+func ImportADIF(ctx context.Context, store Querier, eventID string, r io.Reader, format adif.Format, opt ImportOptions) (ImportResult, error) {
+	log, err := adif.Read(r, format)
+	if err != nil {
+		return ImportResult{DryRun: opt.DryRun}, validationError("ImportADIF", "%v", err)
+	}
+
+	var errs []error
+	qsos := make([]QSO, 0, len(log.Records))
+	for _, record := range log.Records {
+		qso, err := QSOFromADIF(record)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		qsos = append(qsos, qso)
+	}
+
+	result, err := ImportQSOs(ctx, store, eventID, qsos, opt)
+	result.Records += len(log.Errors) + len(errs)
+	result.Errors = append(append(append([]error{}, log.Errors...), errs...), result.Errors...)
+	return result, err
+}
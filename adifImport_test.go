@@ -0,0 +1,63 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/agustadewa/gomongo/adif"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// adifLog holds 2 QSOs of YB0AAA, one of YB1BBB without a mode and a record
+// with a bad field length on line 5
+const adifLog = "exported log <ADIF_VER:5>3.1.4 <EOH>\n" +
+	"<CALL:6>YB0AAA <QSO_DATE:8>20260301 <TIME_ON:4>1230 <FREQ:5>7.135 <BAND:3>40m <MODE:3>SSB <RST_SENT:2>59 <EOR>\n" +
+	"<CALL:6>YB0AAA <QSO_DATE:8>20260301 <TIME_ON:4>1300 <FREQ:6>14.200 <BAND:3>20m <MODE:2>CW <RST_SENT:3>599 <EOR>\n" +
+	"<CALL:6>YB1BBB <QSO_DATE:8>20260302 <FREQ:5>7.135 <EOR>\n" +
+	"<CALL:6>YC2CCC <QSO_DATE:x>20260302 <EOR>\n"
+
+func TestImportADIFDryRun(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	result, err := ImportADIF(ctx, store, "event", strings.NewReader(adifLog), adif.ADI, ImportOptions{DryRun: true})
+	if err != nil || result.Imported != 2 || result.NewIdentities != 1 || len(result.Errors) != 2 {
+		t.Fatalf("got %+v (%v), want 2 QSOs of 1 identity and 2 errors", result, err)
+	}
+	var parseErr *adif.ParseError
+	if !errors.As(result.Errors[0], &parseErr) || parseErr.Line != 5 {
+		t.Errorf("got %v, want an error on line 5", result.Errors[0])
+	}
+	if count, _ := store.QueryCount(ctx, models.CollIdentity, bson.M{}); count != 0 {
+		t.Errorf("dry run wrote %d identities", count)
+	}
+}
+
+func TestImportADIF(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	if _, err := ImportADIF(ctx, store, "event", strings.NewReader(adifLog), adif.ADI, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var imported models.Identity
+	if err := store.QueryFindV2(ctx, models.CollIdentity, nil, bson.M{"event_id": "event", "call_sign": "YB0AAA"}, &imported); err != nil {
+		t.Fatal(err)
+	}
+	var modes []string
+	for _, attribute := range imported.Attributes {
+		modes = append(modes, attribute.Mode)
+	}
+	if got := strings.Join(modes, " "); got != "SSB CW" {
+		t.Fatalf("got %s, want the SSB and CW QSOs", got)
+	}
+
+	// importing the same log again adds nothing
+	again, err := ImportADIF(ctx, store, "event", strings.NewReader(adifLog), adif.ADI, ImportOptions{})
+	if err != nil || again.Imported != 0 || again.Duplicates != 2 {
+		t.Fatalf("got %+v (%v), want the QSOs skipped as duplicates", again, err)
+	}
+}
@@ -0,0 +1,414 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/gomongo/bandplan"
+	"github.com/agustadewa/gomongo/modes"
+	"github.com/agustadewa/gomongo/tools"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Award collections
+const (
+	// CollAward holds the award definitions, keyed by award id
+	CollAward = "award"
+	// CollAwardIssue holds the awards issued, keyed by award id and call sign
+	CollAwardIssue = "award_issue"
+)
+
+// AwardKind type is what an award requirement counts
+type AwardKind string
+
+// Award kinds
+const (
+	// AwardQSOs counts QSOs
+	AwardQSOs AwardKind = "qsos"
+	// AwardBands counts distinct bands
+	AwardBands AwardKind = "bands"
+	// AwardModes counts distinct modes
+	AwardModes AwardKind = "modes"
+	// AwardEvents counts distinct events, the special stations worked
+	AwardEvents AwardKind = "events"
+)
+
+// AwardRequirement type is met when every one of Values is worked, or when
+// Count is reached when Values is empty
+type AwardRequirement struct {
+	Kind   AwardKind `json:"kind" bson:"kind"`
+	Count  int       `json:"count,omitempty" bson:"count,omitempty"`
+	Values []string  `json:"values,omitempty" bson:"values,omitempty"`
+}
+
+// Award type is an award definition, a call sign qualifies when it meets
+// every requirement with the QSOs of Events, of every event when Events is
+// empty. QSOs flagged as dupes do not count.
+type Award struct {
+	ID           string             `json:"id" bson:"_id"`
+	Name         string             `json:"name" bson:"name"`
+	Events       []string           `json:"events" bson:"events"`
+	Requirements []AwardRequirement `json:"requirements" bson:"requirements"`
+	// Digits of the certificate numbers, 4 when 0
+	Digits int `json:"digits" bson:"digits"`
+}
+
+// RequirementProgress type
+type RequirementProgress struct {
+	Kind    AwardKind `json:"kind" bson:"kind"`
+	Need    int       `json:"need" bson:"need"`
+	Have    int       `json:"have" bson:"have"`
+	Worked  []string  `json:"worked,omitempty" bson:"worked,omitempty"`
+	Missing []string  `json:"missing,omitempty" bson:"missing,omitempty"`
+	Met     bool      `json:"met" bson:"met"`
+}
+
+// AwardProgress type is how far a call sign is from an award
+type AwardProgress struct {
+	AwardID      string                `json:"award_id" bson:"award_id"`
+	CallSign     string                `json:"call_sign" bson:"call_sign"`
+	Name         string                `json:"name" bson:"name"`
+	Requirements []RequirementProgress `json:"requirements" bson:"requirements"`
+	Eligible     bool                  `json:"eligible" bson:"eligible"`
+	// QualifiedAt is the date of the QSO that met the last requirement
+	QualifiedAt time.Time `json:"qualified_at,omitempty" bson:"qualified_at,omitempty"`
+}
+
+// AwardIssue type is an award issued to a call sign, it keeps its number
+// when the certificate is printed again
+type AwardIssue struct {
+	ID          string    `json:"id" bson:"_id"`
+	AwardID     string    `json:"award_id" bson:"award_id"`
+	CallSign    string    `json:"call_sign" bson:"call_sign"`
+	Name        string    `json:"name" bson:"name"`
+	Number      string    `json:"number" bson:"number"`
+	Bands       []string  `json:"bands" bson:"bands"`
+	Modes       []string  `json:"modes" bson:"modes"`
+	QualifiedAt time.Time `json:"qualified_at" bson:"qualified_at"`
+	IssuedAt    time.Time `json:"issued_at" bson:"issued_at"`
+}
+
+// SaveAward function inserts or replaces the definition of award.ID
+func SaveAward(ctx context.Context, store Querier, award Award) error {
+	if award.ID == "" {
+		return validationError("SaveAward", "the award id is missing")
+	}
+	if len(award.Requirements) == 0 {
+		return validationError("SaveAward", "award %s has no requirement", award.ID)
+	}
+	for i, requirement := range award.Requirements {
+		switch requirement.Kind {
+		case AwardQSOs, AwardBands, AwardModes, AwardEvents:
+		default:
+			return validationError("SaveAward", "requirement %d: unknown kind %q", i, requirement.Kind)
+		}
+		if requirement.Count < 1 && len(requirement.Values) == 0 {
+			return validationError("SaveAward", "requirement %d: count or values are needed", i)
+		}
+	}
+
+	opt := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	update := bson.M{"$set": bson.M{
+		"name":         award.Name,
+		"events":       award.Events,
+		"requirements": award.Requirements,
+		"digits":       award.Digits,
+	}}
+	var saved Award
+	return store.QueryFindAndUpdateV2(ctx, CollAward, opt, bson.M{"_id": award.ID}, update, &saved)
+}
+
+// LoadAward function returns the definition of id, ErrNotFound when there is none
+func LoadAward(ctx context.Context, store Querier, id string) (Award, error) {
+	var award Award
+	err := store.QueryFindV2(ctx, CollAward, &options.FindOneOptions{}, bson.M{"_id": id}, &award)
+	return award, err
+}
+
+// awardQSO is one document of awardPipeline
+type awardQSO struct {
+	EventID   string `bson:"event_id"`
+	CallSign  string `bson:"call_sign"`
+	Name      string `bson:"name"`
+	Frequency string `bson:"frequency"`
+	Band      string `bson:"band"`
+	Mode      string `bson:"mode"`
+	Date      string `bson:"date"`
+}
+
+// awardPipeline returns the QSOs counting for award, one document each, in
+// call sign and date order, of callSign only when it is not empty
+func awardPipeline(award Award, callSign string) Pipeline {
+	match := bson.M{}
+	if len(award.Events) > 0 {
+		match["event_id"] = bson.M{"$in": award.Events}
+	}
+	if callSign != "" {
+		match["call_sign"] = callSign
+	}
+
+	return NewPipeline().
+		Match(match).
+		Unwind("$attributes", false).
+		ReplaceRoot(MergeObjects(
+			bson.M{
+				"event_id":  "$$ROOT.event_id",
+				"call_sign": "$$ROOT.call_sign",
+				"name":      Trim("$$ROOT.name"),
+			},
+			"$$ROOT.attributes",
+		)).
+		Match(bson.M{"date": bson.M{"$exists": true}, "dupe": bson.M{"$ne": true}}).
+		Sort(SortKey{Key: "call_sign", Order: 1}, SortKey{Key: "date", Order: 1})
+}
+
+// awardTracker follows the progress of one call sign QSO by QSO
+type awardTracker struct {
+	award    Award
+	progress AwardProgress
+	qsos     int
+	worked   map[AwardKind]map[string]bool
+	order    map[AwardKind][]string
+}
+
+// newAwardTracker function
+func newAwardTracker(award Award, callSign string) *awardTracker {
+	return &awardTracker{
+		award:    award,
+		progress: AwardProgress{AwardID: award.ID, CallSign: callSign},
+		worked:   map[AwardKind]map[string]bool{AwardBands: {}, AwardModes: {}, AwardEvents: {}},
+		order:    map[AwardKind][]string{},
+	}
+}
+
+// add counts one QSO and notes the date the award is reached
+func (tracker *awardTracker) add(qso awardQSO) {
+	if tracker.progress.Name == "" {
+		tracker.progress.Name = qso.Name
+	}
+	tracker.qsos++
+
+	contact := newDupeContact(qso.Frequency, qso.Band, qso.Mode, time.Time{})
+	tracker.work(AwardBands, contact.band)
+	tracker.work(AwardModes, contact.mode)
+	tracker.work(AwardEvents, qso.EventID)
+
+	if !tracker.progress.Eligible && tracker.eligible() {
+		millis, _ := strconv.ParseInt(qso.Date, 10, 64)
+		tracker.progress.Eligible = true
+		tracker.progress.QualifiedAt = timeFromMillis(millis)
+	}
+}
+
+// work records value of kind
+func (tracker *awardTracker) work(kind AwardKind, value string) {
+	if value == "" || tracker.worked[kind][value] {
+		return
+	}
+	tracker.worked[kind][value] = true
+	tracker.order[kind] = append(tracker.order[kind], value)
+}
+
+// requirement returns the progress of requirement
+func (tracker *awardTracker) requirement(requirement AwardRequirement) RequirementProgress {
+	progress := RequirementProgress{Kind: requirement.Kind, Need: requirement.Count}
+	if requirement.Kind == AwardQSOs {
+		progress.Have = tracker.qsos
+		progress.Met = progress.Have >= progress.Need
+		return progress
+	}
+
+	progress.Worked = append([]string{}, tracker.order[requirement.Kind]...)
+	if len(requirement.Values) == 0 {
+		progress.Have = len(progress.Worked)
+		progress.Met = progress.Have >= progress.Need
+		return progress
+	}
+
+	progress.Need = len(requirement.Values)
+	for _, value := range requirement.Values {
+		if tracker.worked[requirement.Kind][awardValue(requirement.Kind, value)] {
+			progress.Have++
+		} else {
+			progress.Missing = append(progress.Missing, value)
+		}
+	}
+	progress.Met = progress.Have >= progress.Need
+	return progress
+}
+
+// awardValue returns value written the way QSOs are counted
+func awardValue(kind AwardKind, value string) string {
+	switch kind {
+	case AwardBands:
+		if band := bandplan.NormalizeBand(value); band != "" {
+			return band
+		}
+	case AwardModes:
+		return modes.Canonical(value)
+	}
+	return value
+}
+
+// eligible reports whether every requirement is met
+func (tracker *awardTracker) eligible() bool {
+	for _, requirement := range tracker.award.Requirements {
+		if !tracker.requirement(requirement).Met {
+			return false
+		}
+	}
+	return true
+}
+
+// result returns the progress with every requirement
+func (tracker *awardTracker) result() AwardProgress {
+	progress := tracker.progress
+	progress.Requirements = make([]RequirementProgress, 0, len(tracker.award.Requirements))
+	for _, requirement := range tracker.award.Requirements {
+		progress.Requirements = append(progress.Requirements, tracker.requirement(requirement))
+	}
+	return progress
+}
+
+// AwardProgressOf function returns how far callSign is from award
+func AwardProgressOf(ctx context.Context, store Querier, award Award, callSign string) (AwardProgress, error) {
+	tracker := newAwardTracker(award, callSign)
+	err := store.QueryAggregateEach(ctx, models.CollIdentity, options.Aggregate().SetAllowDiskUse(true), awardPipeline(award, callSign).Stages(), func(raw bson.Raw) error {
+		var qso awardQSO
+		if err := bson.Unmarshal(raw, &qso); err != nil {
+			return wrapError("AwardProgressOf", err)
+		}
+		tracker.add(qso)
+		return nil
+	})
+	return tracker.result(), err
+}
+
+// EligibleStations function returns the progress of every call sign that
+// qualifies for award, the first to qualify first
+func EligibleStations(ctx context.Context, store Querier, award Award) ([]AwardProgress, error) {
+	var eligible []AwardProgress
+	var tracker *awardTracker
+	done := func() {
+		if tracker != nil && tracker.progress.Eligible {
+			eligible = append(eligible, tracker.result())
+		}
+	}
+
+	err := store.QueryAggregateEach(ctx, models.CollIdentity, options.Aggregate().SetAllowDiskUse(true), awardPipeline(award, "").Stages(), func(raw bson.Raw) error {
+		var qso awardQSO
+		if err := bson.Unmarshal(raw, &qso); err != nil {
+			return wrapError("EligibleStations", err)
+		}
+		if tracker == nil || tracker.progress.CallSign != qso.CallSign {
+			done()
+			tracker = newAwardTracker(award, qso.CallSign)
+		}
+		tracker.add(qso)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	done()
+
+	sort.SliceStable(eligible, func(i, j int) bool {
+		return eligible[i].QualifiedAt.Before(eligible[j].QualifiedAt)
+	})
+	return eligible, nil
+}
+
+// IssueAward function issues award to callSign once it qualifies, numbering
+// the certificates of the award in issue order. An award issued before is
+// returned with its number, a call sign that does not qualify fails with
+// ErrValidation. The number is taken and the issue stored in one
+// transaction, so an issue that loses a race does not use up a number.
+func IssueAward(ctx context.Context, store Querier, award Award, callSign string) (AwardIssue, error) {
+	id := award.ID + "|" + callSign
+
+	var issue AwardIssue
+	err := store.QueryFindV2(ctx, CollAwardIssue, &options.FindOneOptions{}, bson.M{"_id": id}, &issue)
+	if err == nil || !errors.Is(err, ErrNotFound) {
+		return issue, err
+	}
+
+	progress, err := AwardProgressOf(ctx, store, award, callSign)
+	if err != nil {
+		return issue, err
+	}
+	if !progress.Eligible {
+		return issue, validationError("IssueAward", "%s does not qualify for %s yet", callSign, award.ID)
+	}
+
+	digits := award.Digits
+	if digits == 0 {
+		digits = 4
+	}
+	issue = AwardIssue{
+		ID:          id,
+		AwardID:     award.ID,
+		CallSign:    callSign,
+		Name:        progress.Name,
+		QualifiedAt: progress.QualifiedAt,
+		IssuedAt:    time.Now().UTC(),
+	}
+	for _, requirement := range progress.Requirements {
+		switch requirement.Kind {
+		case AwardBands:
+			issue.Bands = requirement.Worked
+		case AwardModes:
+			issue.Modes = requirement.Worked
+		}
+	}
+
+	err = store.WithTransaction(ctx, func(txCtx context.Context) error {
+		number, err := NewSequence(store, SequenceKey{EventID: "award:" + award.ID}, SequenceFormat{Digits: digits}).Next(txCtx)
+		if err != nil {
+			return err
+		}
+		issue.Number = number
+		_, err = store.QueryInsertV3(txCtx, CollAwardIssue, issue)
+		return err
+	})
+	if errors.Is(err, ErrDuplicateKey) {
+		// issued concurrently, keep the number stored first, ours was rolled back
+		err = store.QueryFindV2(ctx, CollAwardIssue, &options.FindOneOptions{}, bson.M{"_id": id}, &issue)
+	}
+	return issue, err
+}
+
+// Identity method returns the identity a certificate template prints: the
+// bands and modes worked are the band and mode of its only attribute and the
+// date is the date the award was reached
+func (issue AwardIssue) Identity() (models.Identity, error) {
+	return qsoIdentity(issue.CallSign, issue.Name, bson.M{
+		"band": strings.Join(issue.Bands, " "),
+		"mode": strings.Join(issue.Modes, " "),
+		"date": millisString(issue.QualifiedAt),
+	})
+}
+
+// PrintAwardPDF method issues the award awardID to callSign and renders its
+// certificate with the template of imageCertTemplate, see tools.Tools.PrintPDFV4
+func (adaptor *Adaptor) PrintAwardPDF(ctx context.Context, awardID, callSign, templatePath, fileType string, w io.Writer, imageCertTemplate models.ImageCertTemplate) (AwardIssue, error) {
+	award, err := LoadAward(ctx, adaptor, awardID)
+	if err != nil {
+		return AwardIssue{}, err
+	}
+	issue, err := IssueAward(ctx, adaptor, award, callSign)
+	if err != nil {
+		return issue, err
+	}
+	identity, err := issue.Identity()
+	if err != nil {
+		return issue, err
+	}
+	return issue, tools.Tools{Layouts: adaptor}.PrintPDFV4(issue.Number, identity, 0, templatePath, fileType, w, imageCertTemplate)
+}
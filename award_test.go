@@ -0,0 +1,173 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// awardDate is the date of the first QSO of the award tests
+var awardDate = time.Date(2026, 4, 1, 8, 0, 0, 0, time.UTC)
+
+// workedAll stores the fixture QSOs in 2 events, YB1BBB works 20m in the
+// second one, and returns the saved award of both events on 40m and 20m
+func workedAll(t *testing.T, store Querier) Award {
+	t.Helper()
+	for i, eventID := range []string{"event-a", "event-b"} {
+		qsos := workedQSOs(awardDate.Add(time.Duration(i) * time.Hour))
+		if i == 1 {
+			qsos = append(qsos, QSO{CallSign: "YB1BBB", Frequency: "14.02", Mode: "CW", Date: awardDate.Add(2 * time.Hour)})
+		}
+		importQSOs(t, store, eventID, qsos...)
+	}
+
+	award := Award{
+		ID:     "worked-all",
+		Name:   "Worked All Stations",
+		Events: []string{"event-a", "event-b"},
+		Requirements: []AwardRequirement{
+			{Kind: AwardEvents, Count: 2},
+			{Kind: AwardBands, Values: []string{"40m", "20M"}},
+		},
+	}
+	if err := SaveAward(context.Background(), store, award); err != nil {
+		t.Fatal(err)
+	}
+	return award
+}
+
+func TestSaveAward(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+	workedAll(t, store)
+
+	award, err := LoadAward(ctx, store, "worked-all")
+	if err != nil || len(award.Requirements) != 2 {
+		t.Fatalf("got %+v (%v), want the saved award", award, err)
+	}
+	if err := SaveAward(ctx, store, Award{ID: "empty"}); !errors.Is(err, ErrValidation) {
+		t.Fatalf("got %v, want ErrValidation for an award without requirement", err)
+	}
+}
+
+func TestAwardProgressOf(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+	award := workedAll(t, store)
+
+	for _, c := range []struct {
+		callSign string
+		eligible bool
+		missing  string
+	}{
+		{"YB1BBB", true, ""},
+		{"JA1AAA", false, "40m"},
+		{"W1AW", false, "20M"},
+	} {
+		t.Run(c.callSign, func(t *testing.T) {
+			progress, err := AwardProgressOf(ctx, store, award, c.callSign)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if progress.Eligible != c.eligible {
+				t.Errorf("got eligible %v, want %v", progress.Eligible, c.eligible)
+			}
+			// both events are worked, the bands may miss
+			if bands := progress.Requirements[1]; strings.Join(bands.Missing, " ") != c.missing {
+				t.Errorf("got %v missing, want %q", bands.Missing, c.missing)
+			}
+		})
+	}
+}
+
+func TestEligibleStations(t *testing.T) {
+	store := NewMemoryAdaptor()
+	award := workedAll(t, store)
+
+	eligible, err := EligibleStations(context.Background(), store, award)
+	if err != nil || len(eligible) != 1 {
+		t.Fatalf("got %+v (%v), want 1 station", eligible, err)
+	}
+	// the 20m QSO completes the award
+	if got := eligible[0]; got.CallSign != "YB1BBB" || !got.QualifiedAt.Equal(awardDate.Add(2*time.Hour)) {
+		t.Fatalf("got %+v, want YB1BBB qualified with its 20m QSO", got)
+	}
+}
+
+func TestIssueAward(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+	award := workedAll(t, store)
+
+	// in order, a number is issued once per station
+	for _, c := range []struct {
+		name     string
+		callSign string
+		number   string
+		err      error
+	}{
+		{"not qualified", "JA1AAA", "", ErrValidation},
+		{"first", "YB1BBB", "0001", nil},
+		{"again", "YB1BBB", "0001", nil},
+	} {
+		issue, err := IssueAward(ctx, store, award, c.callSign)
+		if !errors.Is(err, c.err) || issue.Number != c.number {
+			t.Fatalf("%s: got %q (%v), want %q (%v)", c.name, issue.Number, err, c.number, c.err)
+		}
+	}
+}
+
+func TestAwardIssueIdentity(t *testing.T) {
+	store := NewMemoryAdaptor()
+	award := workedAll(t, store)
+
+	issue, err := IssueAward(context.Background(), store, award, "YB1BBB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := issue.Identity()
+	if err != nil || identity.CallSign != "YB1BBB" || len(identity.Attributes) != 1 {
+		t.Fatalf("got %+v (%v), want one attribute of YB1BBB", identity, err)
+	}
+	if attribute := identity.Attributes[0]; attribute.Band != "40m 20m" {
+		t.Fatalf("got %+v, want the bands worked on 40m 20m", attribute)
+	}
+}
+
+// racingStore misses the award issue of its first lookup, as when another
+// request issues it between the lookup and the insert
+type racingStore struct {
+	*MemoryAdaptor
+	missed bool
+}
+
+func (store *racingStore) QueryFindV2(ctx context.Context, collName string, findOneOptions *options.FindOneOptions, query interface{}, result interface{}) error {
+	if collName == CollAwardIssue && !store.missed {
+		store.missed = true
+		return &Error{Op: "QueryFindV2", Kind: ErrNotFound, Err: errors.New("missed")}
+	}
+	return store.MemoryAdaptor.QueryFindV2(ctx, collName, findOneOptions, query, result)
+}
+
+func TestIssueAwardRace(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	importQSOs(t, store, "event", workedQSOs(awardDate)...)
+	award := Award{ID: "worked", Requirements: []AwardRequirement{{Kind: AwardEvents, Count: 1}}}
+
+	if issue, err := IssueAward(ctx, store, award, "YB1BBB"); err != nil || issue.Number != "0001" {
+		t.Fatalf("got %+v (%v), want 0001", issue, err)
+	}
+	// the insert of the race loser fails and its number is rolled back
+	if issue, err := IssueAward(ctx, &racingStore{MemoryAdaptor: store}, award, "YB1BBB"); err != nil || issue.Number != "0001" {
+		t.Fatalf("got %+v (%v), want the number stored first", issue, err)
+	}
+	if issue, err := IssueAward(ctx, store, award, "JA1AAA"); err != nil || issue.Number != "0002" {
+		t.Fatalf("got %+v (%v), want 0002", issue, err)
+	}
+}
@@ -0,0 +1,305 @@
+// Package bandplan parses and formats amateur radio frequencies and maps them
+// to the bands of the IARU region band plans.
+package bandplan
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Frequency type, in Hz
+type Frequency int64
+
+// Unit type
+type Unit int64
+
+// Units
+const (
+	Hz  Unit = 1
+	KHz Unit = 1000
+	MHz Unit = 1000000
+	GHz Unit = 1000000000
+)
+
+// Errors
+var (
+	ErrFrequency    = errors.New("invalid frequency")
+	ErrUnknownUnit  = errors.New("unknown unit")
+	ErrOutOfBand    = errors.New("frequency outside the amateur bands")
+	ErrUnknownBand  = errors.New("unknown band")
+	ErrBandMismatch = errors.New("frequency is not in band")
+)
+
+// ParseUnit function, case insensitive
+func ParseUnit(s string) (Unit, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "hz":
+		return Hz, nil
+	case "khz", "k":
+		return KHz, nil
+	case "mhz", "m":
+		return MHz, nil
+	case "ghz", "g":
+		return GHz, nil
+	}
+	return 0, fmt.Errorf("%w %q", ErrUnknownUnit, s)
+}
+
+// String method
+func (unit Unit) String() string {
+	switch unit {
+	case Hz:
+		return "Hz"
+	case KHz:
+		return "kHz"
+	case GHz:
+		return "GHz"
+	}
+	return "MHz"
+}
+
+// Parse function reads "7.135", "7.135 MHz", "7135 kHz", "7135000Hz" or
+// "7,135". A value without a unit is read in the first of MHz, the unit the
+// attributes are stored in, kHz, the unit of logging software, and Hz that
+// puts it in an amateur band, "1296.2" is 23cm and "7135" is 40m. A value in
+// no band is read as MHz below 1000, kHz below 1000000 and Hz above.
+func Parse(s string) (Frequency, error) {
+	text := strings.TrimSpace(s)
+	split := strings.IndexFunc(text, func(r rune) bool {
+		return unicode.IsLetter(r)
+	})
+
+	number, unitText := text, ""
+	if split >= 0 {
+		number, unitText = strings.TrimSpace(text[:split]), text[split:]
+	}
+	number = strings.ReplaceAll(number, ",", ".")
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value <= 0 || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("%w %q", ErrFrequency, s)
+	}
+
+	if unitText != "" {
+		unit, err := ParseUnit(unitText)
+		if err != nil {
+			return 0, err
+		}
+		return Frequency(math.Round(value * float64(unit))), nil
+	}
+
+	for _, unit := range []Unit{MHz, KHz, Hz} {
+		f := Frequency(math.Round(value * float64(unit)))
+		if _, err := BandOf(f, AnyRegion); err == nil {
+			return f, nil
+		}
+	}
+	unit := Hz
+	switch {
+	case value < 1000:
+		unit = MHz
+	case value < 1000000:
+		unit = KHz
+	}
+	return Frequency(math.Round(value * float64(unit))), nil
+}
+
+// In method returns the frequency in unit
+func (f Frequency) In(unit Unit) float64 {
+	return float64(f) / float64(unit)
+}
+
+// Format method prints the frequency in unit without trailing zeros and
+// without the unit name, e.g. "7.135" or "7135"
+func (f Frequency) Format(unit Unit) string {
+	return strconv.FormatFloat(f.In(unit), 'f', -1, 64)
+}
+
+// FormatMin method prints the frequency in unit as Format does, with at
+// least decimals digits after the point, e.g. "145.240" for 3
+func (f Frequency) FormatMin(unit Unit, decimals int) string {
+	text := f.Format(unit)
+	point := strings.IndexByte(text, '.')
+	if point < 0 {
+		if decimals <= 0 {
+			return text
+		}
+		text, point = text+".", len(text)
+	}
+	if missing := decimals - (len(text) - point - 1); missing > 0 {
+		text += strings.Repeat("0", missing)
+	}
+	return text
+}
+
+// String method is the canonical form stored in attributes, MHz without unit
+// and with at least 3 decimals as events spell them, "7.135" or "145.240"
+func (f Frequency) String() string {
+	return f.FormatMin(MHz, 3)
+}
+
+// Region type, the IARU region. The zero value AnyRegion accepts the band
+// edges of every region.
+type Region int
+
+// IARU regions
+const (
+	AnyRegion Region = iota
+	// Region1 Europe, Africa, Middle East and northern Asia
+	Region1
+	// Region2 the Americas
+	Region2
+	// Region3 Asia Pacific
+	Region3
+)
+
+// Band type, Lower and Upper are inclusive
+type Band struct {
+	Name  string
+	Lower Frequency
+	Upper Frequency
+}
+
+// Contains method
+func (band Band) Contains(f Frequency) bool {
+	return f >= band.Lower && f <= band.Upper
+}
+
+// plan is one band with its edges per region, a zero band means the band is
+// not allocated in that region
+type plan struct {
+	name  string
+	edges [4][2]float64 // kHz, indexed by Region, AnyRegion holds the widest edges
+}
+
+// band returns a plan with the same edges in every region
+func band(name string, lower, upper float64) plan {
+	return plan{name: name, edges: [4][2]float64{{lower, upper}, {lower, upper}, {lower, upper}, {lower, upper}}}
+}
+
+// plans are the amateur allocations of the IARU band plans, in kHz
+var plans = []plan{
+	band("2200m", 135.7, 137.8),
+	band("630m", 472, 479),
+	{name: "160m", edges: [4][2]float64{{1800, 2000}, {1810, 2000}, {1800, 2000}, {1800, 2000}}},
+	{name: "80m", edges: [4][2]float64{{3500, 4000}, {3500, 3800}, {3500, 4000}, {3500, 3900}}},
+	band("60m", 5351.5, 5366.5),
+	{name: "40m", edges: [4][2]float64{{7000, 7300}, {7000, 7200}, {7000, 7300}, {7000, 7200}}},
+	band("30m", 10100, 10150),
+	band("20m", 14000, 14350),
+	band("17m", 18068, 18168),
+	band("15m", 21000, 21450),
+	band("12m", 24890, 24990),
+	band("10m", 28000, 29700),
+	{name: "6m", edges: [4][2]float64{{50000, 54000}, {50000, 52000}, {50000, 54000}, {50000, 54000}}},
+	{name: "4m", edges: [4][2]float64{{70000, 70500}, {70000, 70500}, {}, {}}},
+	{name: "2m", edges: [4][2]float64{{144000, 148000}, {144000, 146000}, {144000, 148000}, {144000, 148000}}},
+	{name: "1.25m", edges: [4][2]float64{{222000, 225000}, {}, {222000, 225000}, {}}},
+	{name: "70cm", edges: [4][2]float64{{420000, 450000}, {430000, 440000}, {420000, 450000}, {430000, 440000}}},
+	{name: "33cm", edges: [4][2]float64{{902000, 928000}, {}, {902000, 928000}, {}}},
+	band("23cm", 1240000, 1300000),
+	band("13cm", 2300000, 2450000),
+}
+
+// bandIn returns the band of p in region
+func (p plan) bandIn(region Region) (Band, bool) {
+	if region < AnyRegion || region > Region3 {
+		region = AnyRegion
+	}
+	edges := p.edges[region]
+	if edges[1] == 0 {
+		return Band{}, false
+	}
+	return Band{
+		Name:  p.name,
+		Lower: Frequency(math.Round(edges[0] * float64(KHz))),
+		Upper: Frequency(math.Round(edges[1] * float64(KHz))),
+	}, true
+}
+
+// Bands function returns the bands of region from the lowest
+func Bands(region Region) []Band {
+	bands := make([]Band, 0, len(plans))
+	for _, p := range plans {
+		if band, ok := p.bandIn(region); ok {
+			bands = append(bands, band)
+		}
+	}
+	return bands
+}
+
+// BandOf function returns the band of region holding f
+func BandOf(f Frequency, region Region) (Band, error) {
+	for _, p := range plans {
+		if band, ok := p.bandIn(region); ok && band.Contains(f) {
+			return band, nil
+		}
+	}
+	return Band{}, fmt.Errorf("%w: %s MHz", ErrOutOfBand, f)
+}
+
+// NormalizeBand function returns the canonical name of a band written as
+// "40 m", "40M", "2 M" or "70 cm", "" when it is not a band
+func NormalizeBand(name string) string {
+	compact := strings.ToLower(strings.Join(strings.Fields(name), ""))
+	if compact == "" {
+		return ""
+	}
+	if !strings.HasSuffix(compact, "m") {
+		compact += "m"
+	}
+	for _, p := range plans {
+		if p.name == compact {
+			return compact
+		}
+	}
+	return ""
+}
+
+// LookupBand function returns the band of region called name, see NormalizeBand
+func LookupBand(name string, region Region) (Band, error) {
+	canonical := NormalizeBand(name)
+	for _, p := range plans {
+		if p.name != canonical {
+			continue
+		}
+		if band, ok := p.bandIn(region); ok {
+			return band, nil
+		}
+	}
+	return Band{}, fmt.Errorf("%w %q", ErrUnknownBand, name)
+}
+
+// Normalize function parses a frequency and a band as stored in an
+// attribute, either may be empty. It returns the canonical frequency and
+// band, deriving the band from the frequency, and fails when they disagree.
+func Normalize(frequency, bandName string, region Region) (string, string, error) {
+	if strings.TrimSpace(frequency) == "" {
+		if strings.TrimSpace(bandName) == "" {
+			return "", "", nil
+		}
+		band, err := LookupBand(bandName, region)
+		if err != nil {
+			return "", "", err
+		}
+		return "", band.Name, nil
+	}
+
+	f, err := Parse(frequency)
+	if err != nil {
+		return "", "", err
+	}
+	band, err := BandOf(f, region)
+	if err != nil {
+		return "", "", err
+	}
+
+	if strings.TrimSpace(bandName) != "" && NormalizeBand(bandName) != band.Name {
+		return "", "", fmt.Errorf("%w %s: %s MHz", ErrBandMismatch, bandName, f)
+	}
+	return f.String(), band.Name, nil
+}
@@ -0,0 +1,127 @@
+package bandplan
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]Frequency{
+		"7.135":        7135000,
+		"7,135":        7135000,
+		"7.135 MHz":    7135000,
+		"7135 kHz":     7135000,
+		"7135":         7135000,
+		"7135000Hz":    7135000,
+		"145240 kHz":   145240000,
+		"1.2G":         1200000000,
+		" 14.200 mhz ": 14200000,
+		"1296.2":       1296200000,
+		"2400":         2400000000,
+		"1800":         1800000,
+		"472":          472000,
+		"100":          100000000,
+	} {
+		got, err := Parse(in)
+		if err != nil || got != want {
+			t.Errorf("%q: got %d (%v), want %d", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"", "abc", "-7.1", "0", "7.1 parsecs"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("%q: want an error", in)
+		}
+	}
+	if _, err := Parse("7.1 furlong"); !errors.Is(err, ErrUnknownUnit) {
+		t.Errorf("got %v, want ErrUnknownUnit", err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, c := range []struct {
+		name, got, want string
+	}{
+		{"String", Frequency(7135000).String(), "7.135"},
+		{"kHz", Frequency(7135000).Format(KHz), "7135"},
+		{"Hz", Frequency(7135000).Format(Hz), "7135000"},
+		{"VHF", Frequency(145240000).String(), "145.240"},
+		{"trailing zeros", Frequency(14000000).String(), "14.000"},
+		{"fourth decimal", Frequency(7074500).String(), "7.0745"},
+		{"UHF", Frequency(1296200000).String(), "1296.200"},
+		{"no decimal", Frequency(7000000).FormatMin(KHz, 0), "7000"},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestParseUnit(t *testing.T) {
+	if unit, err := ParseUnit("kHz"); err != nil || unit != KHz {
+		t.Fatalf("got %v (%v), want kHz", unit, err)
+	}
+	if got := KHz.String(); got != "kHz" {
+		t.Fatalf("got %q, want kHz", got)
+	}
+}
+
+func TestNormalizeBand(t *testing.T) {
+	for in, want := range map[string]string{"40 m": "40m", "40M": "40m", "2 M": "2m", "70 cm": "70cm", "23CM": "23cm", "40": "40m", "41m": "", "": ""} {
+		if got := NormalizeBand(in); got != want {
+			t.Errorf("%q: got %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for _, c := range []struct {
+		frequency, band    string
+		region             Region
+		wantFreq, wantBand string
+	}{
+		{"7.135 MHz", "", Region3, "7.135", "40m"},
+		{"7135", "", Region3, "7.135", "40m"},
+		{"145240 kHz", "", Region3, "145.240", "2m"},
+		{"7.250", "40 M", Region2, "7.250", "40m"},
+		{"1296.2", "23cm", Region1, "1296.200", "23cm"},
+		{"", "70 cm", AnyRegion, "", "70cm"},
+		{"", "", AnyRegion, "", ""},
+	} {
+		frequency, band, err := Normalize(c.frequency, c.band, c.region)
+		if err != nil || frequency != c.wantFreq || band != c.wantBand {
+			t.Errorf("%q %q: got %q %q (%v), want %q %q", c.frequency, c.band, frequency, band, err, c.wantFreq, c.wantBand)
+		}
+	}
+
+}
+
+func TestNormalizeErrors(t *testing.T) {
+	for _, c := range []struct {
+		frequency, band string
+		region          Region
+		err             error
+	}{
+		// 7.250 is outside 40m in region 3
+		{"7.250", "40 m", Region3, ErrOutOfBand},
+		{"14.2", "40m", AnyRegion, ErrBandMismatch},
+		{"", "41m", AnyRegion, ErrUnknownBand},
+	} {
+		if _, _, err := Normalize(c.frequency, c.band, c.region); !errors.Is(err, c.err) {
+			t.Errorf("%q %q: got %v, want %v", c.frequency, c.band, err, c.err)
+		}
+	}
+}
+
+func TestBands(t *testing.T) {
+	band, err := BandOf(Frequency(1296200000), AnyRegion)
+	if err != nil || band.Name != "23cm" {
+		t.Fatalf("got %+v (%v), want 23cm", band, err)
+	}
+	if _, err := LookupBand("4m", Region2); !errors.Is(err, ErrUnknownBand) {
+		t.Fatalf("got %v, want no 4m band in region 2", err)
+	}
+	if len(Bands(Region1)) <= len(Bands(Region3))-1 || len(Bands(AnyRegion)) != len(plans) {
+		t.Fatalf("got %d bands in region 1, %d in region 3 and %d in any region", len(Bands(Region1)), len(Bands(Region3)), len(Bands(AnyRegion)))
+	}
+}
@@ -0,0 +1,476 @@
+package gomongo
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agustadewa/gomongo/tools"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BatchFormat type is what RenderBatch writes
+type BatchFormat string
+
+// Batch formats
+const (
+	// BatchZIP writes a ZIP archive of one file per certificate and a
+	// manifest.csv, the zero format is BatchZIP
+	BatchZIP BatchFormat = "zip"
+	// BatchPDF writes a single PDF of one page per certificate, the manifest
+	// goes to BatchOptions.Manifest
+	BatchPDF BatchFormat = "pdf"
+)
+
+// batchManifest is the name of the manifest in the ZIP archive
+const batchManifest = "manifest.csv"
+
+// BatchOptions type
+type BatchOptions struct {
+	// Filter narrows the QSOs printed, dupes are never printed
+	Filter       ExportFilter
+	Format       BatchFormat
+	Layout       tools.CertLayout
+	TemplatePath string
+	FileType     string
+	// Output is the format of the certificates of a BatchZIP, PDF when zero.
+	// A BatchPDF is always a PDF.
+	Output tools.CertOutput
+	// Numbers formats the certificate numbers, 4 digits when Digits is 0
+	Numbers SequenceFormat
+	// Workers rendering the certificates of a ZIP archive, the number of
+	// CPUs when 0. PNG and JPEG certificates are rendered by fewer workers
+	// when their images would take more than 4 times MaxRasterPixels.
+	Workers int
+	// Manifest receives the manifest CSV of a BatchPDF, it is left out when nil
+	Manifest io.Writer
+}
+
+// maxBatchPixels bounds the pixels of the images a ZIP archive renders at once
+const maxBatchPixels = 4 * tools.MaxRasterPixels
+
+// BatchCertificate type is one row of the manifest, File is the certificate
+// in the archive or the page of the single PDF
+type BatchCertificate struct {
+	File      string    `json:"file"`
+	Number    string    `json:"number"`
+	CallSign  string    `json:"call_sign"`
+	Name      string    `json:"name"`
+	Frequency string    `json:"frequency"`
+	Band      string    `json:"band"`
+	Mode      string    `json:"mode"`
+	Date      time.Time `json:"date"`
+}
+
+// record returns the manifest row of the certificate
+func (cert BatchCertificate) record() []string {
+	return []string{cert.File, cert.Number, cert.CallSign, cert.Name, cert.Frequency, cert.Band, cert.Mode, cert.Date.Format(time.RFC3339)}
+}
+
+// batchManifestHeader is the first row of the manifest
+var batchManifestHeader = []string{"file", "number", "call_sign", "name", "frequency", "band", "mode", "date"}
+
+// BatchResult type
+type BatchResult struct {
+	// Certificates written
+	Certificates int `json:"certificates"`
+	// Issued is the number of certificates numbered by this batch, the
+	// others kept the number of their attribute
+	Issued int `json:"issued"`
+}
+
+// batchQSO is one document of the batch pipeline
+type batchQSO struct {
+	CallSign   string `bson:"call_sign"`
+	LoggedCall string `bson:"logged_call"`
+	Name       string `bson:"name"`
+	Frequency  string `bson:"frequency"`
+	Band       string `bson:"band"`
+	Mode       string `bson:"mode"`
+	RST        string `bson:"rst"`
+	Date       string `bson:"date"`
+	Counter    int64  `bson:"counter"`
+}
+
+// loggedCall returns the call sign as logged, the call sign of a QSO
+// stored without logged_call
+func (qso batchQSO) loggedCall() string {
+	if qso.LoggedCall != "" {
+		return qso.LoggedCall
+	}
+	return qso.CallSign
+}
+
+// batchJob is one certificate on its way to the writer
+type batchJob struct {
+	cert     BatchCertificate
+	identity models.Identity
+	done     chan batchRendered
+}
+
+// batchRendered is the certificate file of a job
+type batchRendered struct {
+	file []byte
+	err  error
+}
+
+// batchNumberer hands out the certificate numbers of one batch
+type batchNumberer struct {
+	store     Querier
+	eventID   string
+	format    SequenceFormat
+	sequences map[string]*Sequence
+	issued    int
+}
+
+// number returns the number of qso, the counter of its attribute when it has
+// one, else the next number of the sequence of its frequency, stored on the
+// attribute so printing it again gives the same number. An attribute numbered
+// by another batch in the meantime keeps its number.
+func (numberer *batchNumberer) number(ctx context.Context, qso batchQSO) (string, error) {
+	seq, ok := numberer.sequences[qso.Frequency]
+	if !ok {
+		var err error
+		if seq, err = certificateSequence(ctx, numberer.store, numberer.eventID, qso.Frequency, numberer.format); err != nil {
+			return "", err
+		}
+		numberer.sequences[qso.Frequency] = seq
+	}
+	if qso.Counter > 0 {
+		return seq.Format(qso.Counter), nil
+	}
+
+	value, err := seq.NextValue(ctx)
+	if err != nil {
+		return "", err
+	}
+	attribute := bson.M{"frequency": qso.Frequency, "mode": qso.Mode, "date": qso.Date}
+	filter := bson.M{
+		"event_id":  numberer.eventID,
+		"call_sign": qso.CallSign,
+		// not numbered yet, imported attributes store a counter of 0
+		"attributes": bson.M{"$elemMatch": bson.M{"frequency": qso.Frequency, "mode": qso.Mode, "date": qso.Date, "counter": bson.M{"$in": bson.A{nil, 0}}}},
+	}
+	update := bson.M{"$set": bson.M{"attributes.$.counter": value}}
+	var result mongo.UpdateResult
+	if err := numberer.store.QueryUpdateOne(ctx, models.CollIdentity, nil, filter, update, &result); err != nil {
+		return "", err
+	}
+	if result.MatchedCount == 0 {
+		counter, err := numberer.storedCounter(ctx, qso.CallSign, attribute)
+		if err != nil {
+			return "", err
+		}
+		return seq.Format(counter), nil
+	}
+	if err := setEventCounter(ctx, numberer.store, numberer.eventID, qso.Frequency, value); err != nil {
+		return "", err
+	}
+	numberer.issued++
+	return seq.Format(value), nil
+}
+
+// storedCounter returns the counter stored on the attribute of callSign
+// matching attribute, ErrNotFound when it is gone or not numbered
+func (numberer *batchNumberer) storedCounter(ctx context.Context, callSign string, attribute bson.M) (int64, error) {
+	var identity struct {
+		Attributes []struct {
+			Counter int64 `bson:"counter"`
+		} `bson:"attributes"`
+	}
+	opt := options.FindOne().SetProjection(bson.M{"attributes": bson.M{"$elemMatch": attribute}})
+	filter := bson.M{"event_id": numberer.eventID, "call_sign": callSign, "attributes": bson.M{"$elemMatch": attribute}}
+	if err := numberer.store.QueryFindV2(ctx, models.CollIdentity, opt, filter, &identity); err != nil {
+		return 0, err
+	}
+	if len(identity.Attributes) == 0 || identity.Attributes[0].Counter == 0 {
+		return 0, &Error{Op: "RenderBatch", Kind: ErrNotFound, Err: fmt.Errorf("QSO of %s is not numbered", callSign)}
+	}
+	return identity.Attributes[0].Counter, nil
+}
+
+// batchFileName returns the name of the certificate file of cert in the archive
+func batchFileName(cert BatchCertificate, ext string) string {
+	clean := strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, cert.CallSign+"_"+cert.Number)
+	return clean + ext
+}
+
+// logBatchDownload records the certificate in the download log
+func logBatchDownload(ctx context.Context, store Querier, eventID string, cert BatchCertificate) error {
+	_, err := store.QueryInsertV3(ctx, models.CollCertificateDownloadLog, bson.M{
+		"event_id":           eventID,
+		"call_sign":          cert.CallSign,
+		"name":               cert.Name,
+		"frequency":          cert.Frequency,
+		"band":               cert.Band,
+		"mode":               cert.Mode,
+		"certificate_number": cert.Number,
+		"batch":              true,
+		"created_at":         time.Now().UTC(),
+	})
+	return err
+}
+
+// RenderBatch method, see RenderBatch
+func (adaptor *Adaptor) RenderBatch(ctx context.Context, eventID string, w io.Writer, opt BatchOptions) (BatchResult, error) {
+	return RenderBatch(ctx, adaptor, eventID, w, opt)
+}
+
+// RenderBatch function renders the certificates of the QSOs of eventID
+// matching opt.Filter to w, in call sign and date order, reading the QSOs
+// one at a time from the cursor. QSOs without a certificate number get the
+// next number of the Sequence of their event and frequency, the one
+// IssueCertificate numbers with. Every
+// certificate written is recorded in the download log. The ZIP archive is
+// rendered by opt.Workers at once and streamed as the certificates are
+// ready, the single PDF is written at the end. Without any QSO the archive
+// holds the manifest only and the single PDF fails with ErrNotFound.
+func RenderBatch(ctx context.Context, store Querier, eventID string, w io.Writer, opt BatchOptions) (BatchResult, error) {
+	format := opt.Format
+	if format == "" {
+		format = BatchZIP
+	}
+	if format != BatchZIP && format != BatchPDF {
+		return BatchResult{}, validationError("RenderBatch", "unknown format %q", format)
+	}
+	if err := opt.Layout.Validate(); err != nil {
+		return BatchResult{}, &Error{Op: "RenderBatch", Kind: ErrValidation, Err: err}
+	}
+	if err := opt.Output.Validate(); err != nil {
+		return BatchResult{}, &Error{Op: "RenderBatch", Kind: ErrValidation, Err: err}
+	}
+	if format == BatchPDF && opt.Output.Raster() {
+		return BatchResult{}, validationError("RenderBatch", "a %s batch cannot hold %s certificates", format, opt.Output.Format)
+	}
+	if opt.Output.Raster() {
+		if _, _, err := opt.Layout.RasterSize(opt.Output); err != nil {
+			return BatchResult{}, &Error{Op: "RenderBatch", Kind: ErrValidation, Err: err}
+		}
+	}
+	if opt.Numbers.Digits == 0 {
+		opt.Numbers.Digits = 4
+	}
+
+	numberer := &batchNumberer{store: store, eventID: eventID, format: opt.Numbers, sequences: map[string]*Sequence{}}
+	var result BatchResult
+	var err error
+	if format == BatchPDF {
+		result, err = renderBatchPDF(ctx, store, eventID, w, opt, numberer)
+	} else {
+		result, err = renderBatchZIP(ctx, store, eventID, w, opt, numberer)
+	}
+	result.Issued = numberer.issued
+	return result, err
+}
+
+// eachBatchQSO calls fn with the certificate of every QSO of the batch
+func eachBatchQSO(ctx context.Context, store Querier, eventID string, opt BatchOptions, numberer *batchNumberer, fn func(cert BatchCertificate, identity models.Identity) error) error {
+	match := opt.Filter.match()
+	match["dupe"] = bson.M{"$ne": true}
+	pipeline := eventQSOPipeline(eventID, "").
+		Match(match).
+		Sort(SortKey{Key: "call_sign", Order: 1}, SortKey{Key: "date", Order: 1})
+
+	return store.QueryAggregateEach(ctx, models.CollIdentity, options.Aggregate().SetAllowDiskUse(true), pipeline.Stages(), func(raw bson.Raw) error {
+		var qso batchQSO
+		if err := bson.Unmarshal(raw, &qso); err != nil {
+			return wrapError("RenderBatch", err)
+		}
+		number, err := numberer.number(ctx, qso)
+		if err != nil {
+			return err
+		}
+		millis, _ := strconv.ParseInt(qso.Date, 10, 64)
+
+		cert := BatchCertificate{
+			Number:    number,
+			CallSign:  qso.loggedCall(),
+			Name:      qso.Name,
+			Frequency: qso.Frequency,
+			Band:      qso.Band,
+			Mode:      qso.Mode,
+			Date:      timeFromMillis(millis),
+		}
+		identity, err := qsoIdentity(qso.loggedCall(), qso.Name, bson.M{
+			"frequency": qso.Frequency,
+			"band":      qso.Band,
+			"mode":      qso.Mode,
+			"rst":       qso.RST,
+			"date":      qso.Date,
+		})
+		if err != nil {
+			return err
+		}
+		return fn(cert, identity)
+	})
+}
+
+// renderBatchPDF writes the certificates as the pages of a single PDF
+func renderBatchPDF(ctx context.Context, store Querier, eventID string, w io.Writer, opt BatchOptions, numberer *batchNumberer) (BatchResult, error) {
+	var result BatchResult
+	var certs []BatchCertificate
+	var pages []tools.CertPage
+	err := eachBatchQSO(ctx, store, eventID, opt, numberer, func(cert BatchCertificate, identity models.Identity) error {
+		cert.File = "page " + strconv.Itoa(len(pages)+1)
+		certs = append(certs, cert)
+		pages = append(pages, tools.CertPage{CertNumber: cert.Number, Identity: identity})
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	if len(pages) == 0 {
+		return result, &Error{Op: "RenderBatch", Kind: ErrNotFound, Err: fmt.Errorf("no QSO of %s to print", eventID)}
+	}
+
+	if err := (tools.Tools{}).PrintPDFPages(pages, opt.TemplatePath, opt.FileType, w, opt.Layout); err != nil {
+		return result, err
+	}
+
+	var manifest *csv.Writer
+	if opt.Manifest != nil {
+		manifest = csv.NewWriter(opt.Manifest)
+		manifest.Write(batchManifestHeader)
+	}
+	for _, cert := range certs {
+		if err := logBatchDownload(ctx, store, eventID, cert); err != nil {
+			return result, err
+		}
+		result.Certificates++
+		if manifest != nil {
+			manifest.Write(cert.record())
+		}
+	}
+	if manifest != nil {
+		manifest.Flush()
+		return result, manifest.Error()
+	}
+	return result, nil
+}
+
+// renderBatchZIP renders the certificates with a pool of workers and writes
+// them to the archive in the order of the cursor. At most opt.Workers
+// certificates wait for the writer, so the archive is streamed.
+func renderBatchZIP(ctx context.Context, store Querier, eventID string, w io.Writer, opt BatchOptions, numberer *batchNumberer) (BatchResult, error) {
+	workers := opt.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	// the images rendered at once are bounded whatever the number of workers
+	if opt.Output.Raster() {
+		width, height, _ := opt.Layout.RasterSize(opt.Output)
+		if pixels := width * height; pixels > 0 && workers*pixels > maxBatchPixels {
+			workers = maxBatchPixels / pixels
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan *batchJob)
+	queue := make(chan *batchJob, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				var buf bytes.Buffer
+				err := tools.Tools{}.PrintCertificate(job.cert.Number, job.identity, 0, opt.TemplatePath, opt.FileType, &buf, opt.Layout, opt.Output)
+				job.done <- batchRendered{file: buf.Bytes(), err: err}
+			}
+		}()
+	}
+
+	var result BatchResult
+	archive := zip.NewWriter(w)
+	var manifest bytes.Buffer
+	manifestWriter := csv.NewWriter(&manifest)
+	manifestWriter.Write(batchManifestHeader)
+
+	written := make(chan error, 1)
+	go func() {
+		var err error
+		for job := range queue {
+			rendered := <-job.done
+			if err != nil {
+				continue
+			}
+			if err = rendered.err; err == nil {
+				err = writeBatchEntry(ctx, store, eventID, archive, job.cert, rendered.file)
+			}
+			if err != nil {
+				cancel()
+				continue
+			}
+			result.Certificates++
+			manifestWriter.Write(job.cert.record())
+		}
+		written <- err
+	}()
+
+	err := eachBatchQSO(ctx, store, eventID, opt, numberer, func(cert BatchCertificate, identity models.Identity) error {
+		cert.File = batchFileName(cert, opt.Output.Ext())
+		job := &batchJob{cert: cert, identity: identity, done: make(chan batchRendered, 1)}
+		select {
+		case queue <- job:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		select {
+		case jobs <- job:
+		case <-ctx.Done():
+			job.done <- batchRendered{err: ctx.Err()}
+			return ctx.Err()
+		}
+		return nil
+	})
+	close(jobs)
+	close(queue)
+	wg.Wait()
+	if errWrite := <-written; errWrite != nil {
+		return result, errWrite
+	}
+	if err != nil {
+		return result, err
+	}
+
+	manifestWriter.Flush()
+	entry, err := archive.Create(batchManifest)
+	if err == nil {
+		_, err = entry.Write(manifest.Bytes())
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	return result, err
+}
+
+// writeBatchEntry writes the certificate file of cert to the archive and logs it
+func writeBatchEntry(ctx context.Context, store Querier, eventID string, archive *zip.Writer, cert BatchCertificate, file []byte) error {
+	entry, err := archive.CreateHeader(&zip.FileHeader{Name: cert.File, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	if _, err := entry.Write(file); err != nil {
+		return err
+	}
+	return logBatchDownload(ctx, store, eventID, cert)
+}
@@ -0,0 +1,202 @@
+package gomongo
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agustadewa/gomongo/tools"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// batchDate is the date the QSOs of the batch tests are worked at
+var batchDate = time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+
+// batchOptions returns the options of a ZIP of the 40m QSOs numbered on 3
+// digits
+func batchOptions(t *testing.T) BatchOptions {
+	return BatchOptions{
+		Filter: ExportFilter{Bands: []string{"40m"}},
+		Layout: tools.CertLayout{Name: "batch", Fields: []tools.CertField{
+			{Source: tools.FieldCallSign, FontName: "Helvetica", FontSize: 20},
+			{Source: tools.FieldCertNumber, FontName: "Helvetica", FontSize: 10},
+		}},
+		TemplatePath: writeTemplate(t),
+		FileType:     "PNG",
+		Numbers:      SequenceFormat{Digits: 3},
+		Workers:      2,
+	}
+}
+
+// batchStore returns a store with the fixture QSOs and a dupe of YB1BBB
+// flagged
+func batchStore(t *testing.T) *MemoryAdaptor {
+	t.Helper()
+	store := NewMemoryAdaptor()
+	store.SetDupeChecker(NewDupeChecker(DupeRule{ByMode: true, Action: DupeFlag}))
+	importQSOs(t, store, "event", append(workedQSOs(batchDate), QSO{CallSign: "YB1BBB", Frequency: "7.135", Mode: "SSB", Date: batchDate.Add(time.Minute)})...)
+	return store
+}
+
+func TestRenderBatch(t *testing.T) {
+	ctx := context.Background()
+	store := batchStore(t)
+
+	var archive bytes.Buffer
+	result, err := RenderBatch(ctx, store, "event", &archive, batchOptions(t))
+	// the YB1BBB dupe and the 20m QSO are left out
+	if err != nil || result.Certificates != 2 || result.Issued != 2 {
+		t.Fatalf("got %+v (%v), want 2 certificates issued", result, err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil || len(reader.File) != 3 {
+		t.Fatalf("got %v (%v), want 2 certificates and the manifest", reader, err)
+	}
+	for i, want := range []string{"W1AW_001.pdf", "YB1BBB_001.pdf", "manifest.csv"} {
+		if got := reader.File[i].Name; got != want {
+			t.Errorf("entry %d: got %s, want %s", i, got, want)
+		}
+	}
+
+	entry, err := reader.File[2].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(entry).ReadAll()
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("got %v (%v), want the header and 2 certificates", rows, err)
+	}
+	if number, callSign := rows[2][1], rows[2][2]; number != "001" || callSign != "YB1BBB" {
+		t.Errorf("got %v, want 001 of YB1BBB", rows[2])
+	}
+	if logs, err := store.QueryCount(ctx, models.CollCertificateDownloadLog, bson.M{"event_id": "event"}); err != nil || logs != 2 {
+		t.Errorf("got %d download logs (%v), want 2", logs, err)
+	}
+}
+
+func TestRenderBatchAgain(t *testing.T) {
+	ctx := context.Background()
+	store := batchStore(t)
+	opt := batchOptions(t)
+	if _, err := RenderBatch(ctx, store, "event", io.Discard, opt); err != nil {
+		t.Fatal(err)
+	}
+
+	// printing again keeps the numbers
+	var pdf, manifest bytes.Buffer
+	opt.Format, opt.Manifest = BatchPDF, &manifest
+	result, err := RenderBatch(ctx, store, "event", &pdf, opt)
+	if err != nil || result.Certificates != 2 || result.Issued != 0 {
+		t.Fatalf("got %+v (%v), want 2 certificates and no number issued", result, err)
+	}
+	if !bytes.HasPrefix(pdf.Bytes(), []byte("%PDF")) {
+		t.Errorf("got %d bytes without the %%PDF header, want a PDF", pdf.Len())
+	}
+	if !strings.Contains(manifest.String(), "page 2,001,YB1BBB") {
+		t.Errorf("got manifest %q, want YB1BBB on page 2", manifest.String())
+	}
+}
+
+// rasterOptions returns the options of a ZIP of JPEG QSL cards at 50 dpi
+func rasterOptions(t *testing.T) BatchOptions {
+	layout := tools.CertLayout{Name: "raster", Page: tools.PageSetup{Size: tools.PageQSL}, Fields: []tools.CertField{
+		{Source: tools.FieldCallSign, FontName: "Helvetica", FontSize: 40},
+	}}
+	return BatchOptions{
+		Filter:       ExportFilter{Bands: []string{"40m"}},
+		Layout:       layout,
+		TemplatePath: writeTemplate(t),
+		FileType:     "PNG",
+		Output:       tools.CertOutput{Format: tools.CertJPEG, DPI: 50},
+	}
+}
+
+func TestRenderBatchRaster(t *testing.T) {
+	store := NewMemoryAdaptor()
+	importQSOs(t, store, "event", workedQSOs(batchDate)...)
+
+	var archive bytes.Buffer
+	if result, err := RenderBatch(context.Background(), store, "event", &archive, rasterOptions(t)); err != nil || result.Certificates != 2 {
+		t.Fatalf("got %+v (%v), want 2 certificates", result, err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil || len(reader.File) != 3 {
+		t.Fatalf("got %v (%v), want 2 certificates and the manifest", reader, err)
+	}
+	for i, want := range []string{"W1AW_0001.jpg", "YB1BBB_0001.jpg"} {
+		if got := reader.File[i].Name; got != want {
+			t.Errorf("entry %d: got %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestRenderBatchRasterRejected(t *testing.T) {
+	store := NewMemoryAdaptor()
+	importQSOs(t, store, "event", workedQSOs(batchDate)...)
+
+	for _, c := range []struct {
+		name   string
+		change func(opt *BatchOptions)
+	}{
+		{"single PDF of images", func(opt *BatchOptions) { opt.Format = BatchPDF }},
+		{"1000 mm page at 1200 dpi", func(opt *BatchOptions) {
+			opt.Layout.Page, opt.Output.DPI = tools.PageSetup{Width: 1000, Height: 1000}, 1200
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			opt := rasterOptions(t)
+			c.change(&opt)
+			if _, err := RenderBatch(context.Background(), store, "event", io.Discard, opt); !errors.Is(err, ErrValidation) {
+				t.Fatalf("got %v, want ErrValidation", err)
+			}
+		})
+	}
+}
+
+// writeTemplate writes a blank certificate template image and returns its path
+func writeTemplate(t *testing.T) string {
+	t.Helper()
+	template := filepath.Join(t.TempDir(), "template.png")
+	file, err := os.Create(template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, image.NewRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	return template
+}
+
+func TestBatchNumbererNumberedMeanwhile(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	date := time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+	importQSOs(t, store, "event", QSO{CallSign: "YB1BBB", Frequency: "7.135", Mode: "SSB", Date: date})
+	qso := batchQSO{CallSign: "YB1BBB", Frequency: "7.135", Mode: "SSB", Date: millisString(date)}
+
+	// another batch numbers the QSO after this one read it
+	filter := bson.M{"event_id": "event", "call_sign": "YB1BBB", "attributes.date": qso.Date}
+	if err := store.QueryUpdateOne(ctx, models.CollIdentity, nil, filter, bson.M{"$set": bson.M{"attributes.$.counter": 7}}, &mongo.UpdateResult{}); err != nil {
+		t.Fatal(err)
+	}
+	numberer := &batchNumberer{store: store, eventID: "event", format: SequenceFormat{Digits: 4}, sequences: map[string]*Sequence{}}
+	number, err := numberer.number(ctx, qso)
+	if err != nil || number != "0007" || numberer.issued != 0 {
+		t.Fatalf("got %s (%v) and %d issued, want the stored 0007 kept", number, err, numberer.issued)
+	}
+}
@@ -0,0 +1,77 @@
+package gomongo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// QueryBulkWrite method sends every write model in one round trip
+func (adaptor *Adaptor) QueryBulkWrite(ctx context.Context, collName string, writeModels []mongo.WriteModel, bulkWriteOptions *options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	collection := adaptor.Client.Database(adaptor.DBName).Collection(collName)
+	result, err := collection.BulkWrite(ctx, writeModels, bulkWriteOptions)
+	return result, wrapError("QueryBulkWrite", err)
+}
+
+// QueryBulkWrite method applies the write models in order and stops at the
+// first error, like an ordered bulk write
+func (m *MemoryAdaptor) QueryBulkWrite(ctx context.Context, collName string, writeModels []mongo.WriteModel, bulkWriteOptions *options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	unlock := m.lock(ctx)
+	defer unlock()
+
+	result := &mongo.BulkWriteResult{UpsertedIDs: map[int64]interface{}{}}
+	for i, writeModel := range writeModels {
+		if err := m.bulkWrite(collName, int64(i), writeModel, result); err != nil {
+			return result, wrapError("QueryBulkWrite", err)
+		}
+	}
+	return result, nil
+}
+
+// bulkWrite applies one write model, the caller holds the lock
+func (m *MemoryAdaptor) bulkWrite(collName string, index int64, writeModel mongo.WriteModel, result *mongo.BulkWriteResult) error {
+	var updateResult *mongo.UpdateResult
+	var err error
+
+	switch model := writeModel.(type) {
+	case *mongo.InsertOneModel:
+		doc, err := toDoc(model.Document)
+		if err != nil {
+			return err
+		}
+		if _, err = m.insert(collName, doc); err != nil {
+			return err
+		}
+		result.InsertedCount++
+		return nil
+	case *mongo.UpdateOneModel:
+		upsert := model.Upsert != nil && *model.Upsert
+		updateResult, _, _, err = m.update(collName, model.Filter, model.Update, upsert, false)
+	case *mongo.UpdateManyModel:
+		upsert := model.Upsert != nil && *model.Upsert
+		updateResult, _, _, err = m.update(collName, model.Filter, model.Update, upsert, true)
+	case *mongo.DeleteOneModel:
+		deleted, err := m.remove(collName, model.Filter, false)
+		result.DeletedCount += deleted
+		return err
+	case *mongo.DeleteManyModel:
+		deleted, err := m.remove(collName, model.Filter, true)
+		result.DeletedCount += deleted
+		return err
+	default:
+		return fmt.Errorf("unsupported write model %T", writeModel)
+	}
+	if err != nil {
+		return err
+	}
+
+	result.MatchedCount += updateResult.MatchedCount
+	result.ModifiedCount += updateResult.ModifiedCount
+	result.UpsertedCount += updateResult.UpsertedCount
+	if updateResult.UpsertedID != nil {
+		result.UpsertedIDs[index] = updateResult.UpsertedID
+	}
+	return nil
+}
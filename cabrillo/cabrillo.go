@@ -0,0 +1,273 @@
+// Package cabrillo reads and writes Cabrillo 3.0 contest logs.
+package cabrillo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Version written on the START-OF-LOG line
+const Version = "3.0"
+
+// timeLayout is the date and time of a QSO line
+const timeLayout = "2006-01-02 1504"
+
+// Tag type is one header line, e.g. CATEGORY-MODE: SSB
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// QSO type is one QSO: line. Frequency is in kHz, or a band designator such
+// as 50, 144 or 1.2G above 30 MHz. Mode is CW, PH, FM, RY or DG.
+type QSO struct {
+	Frequency    string
+	Mode         string
+	Time         time.Time
+	SentCall     string
+	SentExch     []string
+	ReceivedCall string
+	ReceivedExch []string
+	Transmitter  string
+	Line         int
+}
+
+// Log type is the content of a Cabrillo file. QSOs holds the lines that could
+// be read, Errors one *ParseError per line that could not.
+type Log struct {
+	Tags   []Tag
+	QSOs   []QSO
+	Errors []error
+}
+
+// Tag method returns the value of the first tag called name, or ""
+func (log *Log) Tag(name string) string {
+	for _, tag := range log.Tags {
+		if strings.EqualFold(tag.Name, name) {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// CallSign method
+func (log *Log) CallSign() string {
+	return log.Tag("CALLSIGN")
+}
+
+// Contest method
+func (log *Log) Contest() string {
+	return log.Tag("CONTEST")
+}
+
+// Categories method returns the CATEGORY-* tags by name
+func (log *Log) Categories() map[string]string {
+	categories := map[string]string{}
+	for _, tag := range log.Tags {
+		if strings.HasPrefix(tag.Name, "CATEGORY-") {
+			categories[tag.Name] = tag.Value
+		}
+	}
+	return categories
+}
+
+// ParseError type
+type ParseError struct {
+	Line  int
+	Token string
+	Err   error
+}
+
+// Error method
+func (e *ParseError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %q: %v", e.Line, e.Token, e.Err)
+}
+
+// Unwrap method
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Parse errors
+var (
+	ErrNotCabrillo = errors.New("missing START-OF-LOG")
+	ErrMalformed   = errors.New("malformed line")
+	ErrBadDate     = errors.New("bad date or time")
+	ErrMissingQSO  = errors.New("not enough fields in QSO line")
+)
+
+// Options type
+type Options struct {
+	// SentExchFields is the number of exchange fields sent in each QSO line,
+	// the contest rules define it. 0 splits the fields after the sent call
+	// evenly between sent and received, with a trailing transmitter id when
+	// the count is even.
+	SentExchFields int
+}
+
+// Read function reads a whole Cabrillo log. A malformed line is skipped and
+// reported in Log.Errors, the returned error is only set when r fails or the
+// file is not a Cabrillo log.
+func Read(r io.Reader, opt Options) (*Log, error) {
+	log := &Log{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	line := 0
+	started := false
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		colon := strings.IndexByte(text, ':')
+		if colon < 0 {
+			log.Errors = append(log.Errors, &ParseError{Line: line, Token: text, Err: ErrMalformed})
+			continue
+		}
+		name := strings.ToUpper(strings.TrimSpace(text[:colon]))
+		value := strings.TrimSpace(text[colon+1:])
+
+		if !started {
+			if name != "START-OF-LOG" {
+				return nil, &ParseError{Line: line, Token: name, Err: ErrNotCabrillo}
+			}
+			started = true
+			continue
+		}
+
+		switch name {
+		case "END-OF-LOG":
+			return log, scanner.Err()
+		case "QSO", "X-QSO":
+			qso, err := parseQSO(line, value, opt)
+			if err != nil {
+				log.Errors = append(log.Errors, err)
+				continue
+			}
+			if name == "QSO" {
+				log.QSOs = append(log.QSOs, qso)
+			}
+		default:
+			log.Tags = append(log.Tags, Tag{Name: name, Value: value})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !started {
+		return nil, &ParseError{Line: line, Err: ErrNotCabrillo}
+	}
+	return log, nil
+}
+
+// parseQSO parses the value of a QSO: line
+func parseQSO(line int, value string, opt Options) (QSO, error) {
+	tokens := strings.Fields(value)
+	if len(tokens) < 6 {
+		return QSO{}, &ParseError{Line: line, Token: value, Err: ErrMissingQSO}
+	}
+
+	qso := QSO{
+		Frequency: tokens[0],
+		Mode:      strings.ToUpper(tokens[1]),
+		SentCall:  strings.ToUpper(tokens[4]),
+		Line:      line,
+	}
+
+	when, err := time.Parse(timeLayout, tokens[2]+" "+tokens[3])
+	if err != nil {
+		token := tokens[2]
+		if _, dateErr := time.Parse("2006-01-02", tokens[2]); dateErr == nil {
+			token = tokens[3]
+		}
+		return QSO{}, &ParseError{Line: line, Token: token, Err: ErrBadDate}
+	}
+	qso.Time = when
+
+	rest := tokens[5:]
+	sent := opt.SentExchFields
+	if sent == 0 {
+		sent = (len(rest) - 1) / 2
+	}
+	if sent < 0 || len(rest) < sent+1 {
+		return QSO{}, &ParseError{Line: line, Token: value, Err: ErrMissingQSO}
+	}
+
+	qso.SentExch = append([]string{}, rest[:sent]...)
+	qso.ReceivedCall = strings.ToUpper(rest[sent])
+	received := rest[sent+1:]
+	if opt.SentExchFields == 0 && len(received) > sent {
+		qso.Transmitter = received[len(received)-1]
+		received = received[:len(received)-1]
+	}
+	qso.ReceivedExch = append([]string{}, received...)
+
+	return qso, nil
+}
+
+// Writer type writes a Cabrillo log, WriteHeader first, then the QSOs, then Close
+type Writer struct {
+	buf  *bufio.Writer
+	qsos int64
+}
+
+// NewWriter function
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{buf: bufio.NewWriter(w)}
+}
+
+// WriteHeader method writes START-OF-LOG and the tags
+func (writer *Writer) WriteHeader(tags ...Tag) error {
+	writer.buf.WriteString("START-OF-LOG: " + Version + "\n")
+	for _, tag := range tags {
+		if tag.Value == "" {
+			continue
+		}
+		writer.buf.WriteString(strings.ToUpper(tag.Name) + ": " + tag.Value + "\n")
+	}
+	return nil
+}
+
+// WriteQSO method writes one QSO: line
+func (writer *Writer) WriteQSO(qso QSO) error {
+	fields := []string{
+		fmt.Sprintf("%5s", qso.Frequency),
+		qso.Mode,
+		qso.Time.UTC().Format(timeLayout),
+		fmt.Sprintf("%-13s", qso.SentCall),
+	}
+	fields = append(fields, qso.SentExch...)
+	fields = append(fields, fmt.Sprintf("%-13s", qso.ReceivedCall))
+	fields = append(fields, qso.ReceivedExch...)
+	if qso.Transmitter != "" {
+		fields = append(fields, qso.Transmitter)
+	}
+
+	if _, err := writer.buf.WriteString("QSO: " + strings.TrimRight(strings.Join(fields, " "), " ") + "\n"); err != nil {
+		return err
+	}
+	writer.qsos++
+	return nil
+}
+
+// Close method writes END-OF-LOG and flushes, it does not close the io.Writer
+func (writer *Writer) Close() error {
+	writer.buf.WriteString("END-OF-LOG:\n")
+	return writer.buf.Flush()
+}
+
+// QSOs method returns the number of QSO lines written
+func (writer *Writer) QSOs() int64 {
+	return writer.qsos
+}
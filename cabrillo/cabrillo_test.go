@@ -0,0 +1,141 @@
+package cabrillo
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testLog = "START-OF-LOG: 3.0\n" +
+	"CALLSIGN: YB0XYZ\n" +
+	"CONTEST: YB-DX\n" +
+	"CATEGORY-MODE: MIXED\n" +
+	"CATEGORY-POWER: LOW\n" +
+	"QSO:  7135 PH 2026-03-01 1230 YB0XYZ        59  001    YB0AAA        59  014\n" +
+	"X-QSO: 7135 PH 2026-03-01 1231 YB0XYZ       59  002    YB0AAA        59  015\n" +
+	"QSO: 14200 CW 2026-03-01 25:01 YB0XYZ       599 003    YC2CCC        599 001\n" +
+	"QSO: 14200 CW 2026-03-01 1301 YB0XYZ\n" +
+	"QSO: 14200 CW 2026-03-01 1302 YB0XYZ        599 004    YB1BBB        599 007   1\n" +
+	"END-OF-LOG:\n"
+
+func TestRead(t *testing.T) {
+	log, err := Read(strings.NewReader(testLog), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name, got, want string
+	}{
+		{"call sign", log.CallSign(), "YB0XYZ"},
+		{"contest", log.Contest(), "YB-DX"},
+		{"mode category", log.Categories()["CATEGORY-MODE"], "MIXED"},
+		{"power category", log.Categories()["CATEGORY-POWER"], "LOW"},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestReadQSOs(t *testing.T) {
+	log, err := Read(strings.NewReader(testLog), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// X-QSO is left out, the lines 8 and 9 are errors
+	want := []QSO{
+		{
+			Frequency: "7135", Mode: "PH", Time: time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC),
+			SentCall: "YB0XYZ", SentExch: []string{"59", "001"},
+			ReceivedCall: "YB0AAA", ReceivedExch: []string{"59", "014"},
+			Line: 6,
+		},
+		{
+			Frequency: "14200", Mode: "CW", Time: time.Date(2026, 3, 1, 13, 2, 0, 0, time.UTC),
+			SentCall: "YB0XYZ", SentExch: []string{"599", "004"},
+			ReceivedCall: "YB1BBB", ReceivedExch: []string{"599", "007"},
+			Transmitter: "1", Line: 10,
+		},
+	}
+	if !reflect.DeepEqual(log.QSOs, want) {
+		t.Fatalf("got %+v, want %+v", log.QSOs, want)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	log, err := Read(strings.NewReader(testLog), Options{})
+	if err != nil || len(log.Errors) != 2 {
+		t.Fatalf("got %+v (%v), want 2 errors", log, err)
+	}
+	for i, want := range []struct {
+		line  int
+		token string
+		err   error
+	}{
+		{8, "25:01", ErrBadDate},
+		{9, "", ErrMissingQSO},
+	} {
+		var parseErr *ParseError
+		if !errors.As(log.Errors[i], &parseErr) || !errors.Is(parseErr, want.err) {
+			t.Errorf("got %v, want %v", log.Errors[i], want.err)
+			continue
+		}
+		if parseErr.Line != want.line || (want.token != "" && parseErr.Token != want.token) {
+			t.Errorf("got line %d token %q, want line %d token %q", parseErr.Line, parseErr.Token, want.line, want.token)
+		}
+	}
+}
+
+func TestReadSentExchFields(t *testing.T) {
+	data := "START-OF-LOG: 3.0\nQSO: 14200 CW 2026-03-01 1302 YB0XYZ 599 YB1BBB 599 OC\nEND-OF-LOG:\n"
+	log, err := Read(strings.NewReader(data), Options{SentExchFields: 1})
+	if err != nil || len(log.QSOs) != 1 {
+		t.Fatalf("got %+v (%v), want 1 QSO", log, err)
+	}
+	if qso := log.QSOs[0]; qso.ReceivedCall != "YB1BBB" || strings.Join(qso.ReceivedExch, " ") != "599 OC" || qso.Transmitter != "" {
+		t.Fatalf("got %+v, want 2 received exchange fields", qso)
+	}
+}
+
+func TestReadNotCabrillo(t *testing.T) {
+	if _, err := Read(strings.NewReader("<CALL:6>YB0AAA <EOR>\n"), Options{}); !errors.Is(err, ErrNotCabrillo) {
+		t.Fatalf("got %v, want ErrNotCabrillo", err)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer := NewWriter(&out)
+	writer.WriteHeader(Tag{Name: "callsign", Value: "YB0XYZ"}, Tag{Name: "CLUB"})
+	qso := QSO{
+		Frequency:    "7135",
+		Mode:         "PH",
+		Time:         time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC),
+		SentCall:     "YB0XYZ",
+		SentExch:     []string{"59", "001"},
+		ReceivedCall: "YB0AAA",
+		ReceivedExch: []string{"59", "014"},
+	}
+	if err := writer.WriteQSO(qso); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "START-OF-LOG: 3.0\n" +
+		"CALLSIGN: YB0XYZ\n" +
+		"QSO:  7135 PH 2026-03-01 1230 YB0XYZ        59 001 YB0AAA        59 014\n" +
+		"END-OF-LOG:\n"
+	if out.String() != want || writer.QSOs() != 1 {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+
+	log, err := Read(&out, Options{})
+	if err != nil || len(log.QSOs) != 1 || log.QSOs[0].ReceivedCall != "YB0AAA" {
+		t.Fatalf("got %+v (%v), want the QSO back", log, err)
+	}
+}
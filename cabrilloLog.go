@@ -0,0 +1,255 @@
+package gomongo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/gomongo/bandplan"
+	"github.com/agustadewa/gomongo/cabrillo"
+	"github.com/agustadewa/gomongo/callsign"
+	"github.com/agustadewa/gomongo/modes"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollCabrilloLog holds the header of every Cabrillo log imported into an
+// event, keyed by event id and call sign
+const CollCabrilloLog = "cabrillo_log"
+
+// CabrilloLog type is the header of a Cabrillo log imported into an event
+type CabrilloLog struct {
+	ID         string            `json:"-" bson:"_id"`
+	EventID    string            `json:"event_id" bson:"event_id"`
+	CallSign   string            `json:"call_sign" bson:"call_sign"`
+	Contest    string            `json:"contest" bson:"contest"`
+	Categories map[string]string `json:"categories,omitempty" bson:"categories,omitempty"`
+	ImportedAt time.Time         `json:"imported_at" bson:"imported_at"`
+}
+
+// cabrilloBands maps the band designators used above 30 MHz to bands
+var cabrilloBands = map[string]string{
+	"50":   "6m",
+	"70":   "4m",
+	"144":  "2m",
+	"222":  "1.25m",
+	"432":  "70cm",
+	"902":  "33cm",
+	"1.2G": "23cm",
+	"2.3G": "13cm",
+}
+
+// rstPattern matches a signal report, 59 or 599
+var rstPattern = regexp.MustCompile(`^[1-5][1-9][1-9]?$`)
+
+// QSOFromCabrillo function maps a QSO: line onto a QSO with the received
+// call, logged by the sent call. The mode is normalized, PH is SSB, RY is
+// RTTY and DG is DATA. Band designators above 30 MHz become the band, any
+// other number is a frequency in kHz, 136 and 472 included, converted to
+// MHz. The RST is the first sent exchange field when it looks like one.
+func QSOFromCabrillo(line cabrillo.QSO) QSO {
+	qso := QSO{
+		CallSign: line.ReceivedCall,
+		Station:  line.SentCall,
+		Mode:     line.Mode,
+		Date:     line.Time,
+		Line:     line.Line,
+	}
+	if mode, err := modes.Specific(line.Mode); err == nil {
+		qso.Mode = mode
+	}
+
+	if band, ok := cabrilloBands[strings.ToUpper(line.Frequency)]; ok {
+		qso.Band = band
+	} else if khz, err := strconv.ParseFloat(line.Frequency, 64); err == nil {
+		qso.Frequency = bandplan.Frequency(khz * float64(bandplan.KHz)).String()
+	}
+
+	if len(line.SentExch) > 0 && rstPattern.MatchString(line.SentExch[0]) {
+		qso.RST = line.SentExch[0]
+	}
+	return qso
+}
+
+// ImportCabrillo function reads a Cabrillo log and imports its QSOs into
+// eventID, see ImportQSOs. The log must name the station in its CALLSIGN
+// tag, a QSO line sent by another station is not imported. The errors of
+// lines that could not be read are part of the result errors, with their
+// line and token. The CALLSIGN, CONTEST and CATEGORY-* tags are stored in
+// CollCabrilloLog, see LoadCabrilloLogs.
+func ImportCabrillo(ctx context.Context, store Querier, eventID string, r io.Reader, cabrilloOptions cabrillo.Options, opt ImportOptions) (ImportResult, error) {
+	log, err := cabrillo.Read(r, cabrilloOptions)
+	if err != nil {
+		return ImportResult{DryRun: opt.DryRun}, validationError("ImportCabrillo", "%v", err)
+	}
+	if log.CallSign() == "" {
+		return ImportResult{DryRun: opt.DryRun}, validationError("ImportCabrillo", "the CALLSIGN tag is missing")
+	}
+	station, err := callsign.Parse(log.CallSign())
+	if err != nil {
+		return ImportResult{DryRun: opt.DryRun}, &Error{Op: "ImportCabrillo", Kind: ErrValidation, Err: err}
+	}
+
+	var stationErrors []error
+	qsos := make([]QSO, 0, len(log.QSOs))
+	for _, line := range log.QSOs {
+		if sent, err := callsign.Normalize(line.SentCall); err != nil || sent != station.Base {
+			stationErrors = append(stationErrors, &ImportError{Line: line.Line, CallSign: line.ReceivedCall, Err: fmt.Errorf("sent call %s is not the CALLSIGN %s", line.SentCall, station)})
+			continue
+		}
+		qsos = append(qsos, QSOFromCabrillo(line))
+	}
+
+	result, err := ImportQSOs(ctx, store, eventID, qsos, opt)
+	result.Records += len(log.Errors) + len(stationErrors)
+	result.Errors = append(append(append([]error{}, log.Errors...), stationErrors...), result.Errors...)
+	if err != nil || opt.DryRun {
+		return result, err
+	}
+
+	header := bson.M{
+		"event_id":    eventID,
+		"call_sign":   station.String(),
+		"contest":     log.Contest(),
+		"categories":  log.Categories(),
+		"imported_at": time.Now().UTC(),
+	}
+	upsert := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var saved CabrilloLog
+	return result, store.QueryFindAndUpdateV2(ctx, CollCabrilloLog, upsert, bson.M{"_id": eventID + "|" + station.String()}, bson.M{"$set": header}, &saved)
+}
+
+// LoadCabrilloLogs function returns the headers of the Cabrillo logs
+// imported into eventID, by call sign
+func LoadCabrilloLogs(ctx context.Context, store Querier, eventID string) ([]CabrilloLog, error) {
+	logs := []CabrilloLog{}
+	opt := options.Find().SetSort(bson.D{{Key: "call_sign", Value: 1}})
+	err := store.QueryFindManyV2(ctx, CollCabrilloLog, opt, bson.M{"event_id": eventID}, &logs)
+	return logs, err
+}
+
+// ExportCabrillo function writes the QSOs of eventID matching filter to w as
+// a Cabrillo log worked by the CALLSIGN tag of tags. The received exchange is
+// not stored in identities, each line holds the sent RST and the received
+// call only, read it back with cabrillo.Options{SentExchFields: 1}. A QSO
+// stored with its band only is written on the band, see cabrilloFrequency.
+func ExportCabrillo(ctx context.Context, store Querier, eventID string, tags []cabrillo.Tag, filter ExportFilter, w io.Writer) (int64, error) {
+	var event models.Event
+	OID, err := objectID("ExportCabrillo", eventID)
+	if err != nil {
+		return 0, err
+	}
+	if err := store.QueryFindV2(ctx, models.CollEvent, &options.FindOneOptions{}, bson.M{"_id": OID}, &event); err != nil {
+		return 0, err
+	}
+
+	callSign := ""
+	hasContest := false
+	for _, tag := range tags {
+		switch strings.ToUpper(tag.Name) {
+		case "CALLSIGN":
+			callSign = strings.ToUpper(tag.Value)
+		case "CONTEST":
+			hasContest = true
+		}
+	}
+	if callSign == "" {
+		return 0, validationError("ExportCabrillo", "the CALLSIGN tag is missing")
+	}
+	if !hasContest {
+		tags = append(tags, cabrillo.Tag{Name: "CONTEST", Value: event.Name})
+	}
+
+	writer := cabrillo.NewWriter(w)
+	if err := writer.WriteHeader(tags...); err != nil {
+		return 0, err
+	}
+
+	pipeline := eventQSOPipeline(eventID, event.Name).
+		Match(filter.match()).
+		Sort(SortKey{Key: "date", Order: 1}, SortKey{Key: "call_sign", Order: 1})
+
+	err = store.QueryAggregateEach(ctx, models.CollIdentity, options.Aggregate().SetAllowDiskUse(true), pipeline.Stages(), func(raw bson.Raw) error {
+		var qso exportedQSO
+		if err := bson.Unmarshal(raw, &qso); err != nil {
+			return wrapError("ExportCabrillo", err)
+		}
+		line, err := qso.cabrilloQSO(callSign)
+		if err != nil {
+			return validationError("ExportCabrillo", "%s: bad date %q", qso.CallSign, qso.Date)
+		}
+		return writer.WriteQSO(line)
+	})
+	if err != nil {
+		return writer.QSOs(), err
+	}
+
+	return writer.QSOs(), writer.Close()
+}
+
+// cabrilloMode returns the Cabrillo mode of a stored mode, CW, PH, FM, RY
+// or DG by its group
+func cabrilloMode(mode string) string {
+	canonical := modes.Canonical(mode)
+	switch canonical {
+	case "CW", "FM":
+		return canonical
+	case "RTTY":
+		return "RY"
+	}
+
+	group, err := modes.GroupOf(canonical)
+	switch {
+	case err != nil:
+		return canonical
+	case group == modes.Phone:
+		return "PH"
+	}
+	return "DG"
+}
+
+// cabrilloQSO returns the QSO line of the QSO worked by callSign
+func (qso exportedQSO) cabrilloQSO(callSign string) (cabrillo.QSO, error) {
+	millis, err := strconv.ParseInt(qso.Date, 10, 64)
+	if err != nil {
+		return cabrillo.QSO{}, err
+	}
+
+	line := cabrillo.QSO{
+		Frequency:    cabrilloFrequency(qso.Frequency, qso.Band),
+		Mode:         cabrilloMode(qso.Mode),
+		Time:         timeFromMillis(millis),
+		SentCall:     callSign,
+		ReceivedCall: qso.loggedCall(),
+	}
+	if qso.RST != "" {
+		line.SentExch = []string{qso.RST}
+	}
+	return line, nil
+}
+
+// cabrilloFrequency returns the freq column of a QSO: the frequency in kHz,
+// else the lower edge of the band in kHz below 30 MHz and the band
+// designator above, "7000" for 40m and "144" for 2m
+func cabrilloFrequency(frequency, band string) string {
+	if f, err := bandplan.Parse(frequency); err == nil {
+		return strconv.FormatFloat(math.Round(f.In(bandplan.KHz)), 'f', 0, 64)
+	}
+
+	name := bandplan.NormalizeBand(band)
+	for designator, designated := range cabrilloBands {
+		if designated == name {
+			return designator
+		}
+	}
+	if edges, err := bandplan.LookupBand(name, bandplan.AnyRegion); err == nil {
+		return edges.Lower.Format(bandplan.KHz)
+	}
+	return band
+}
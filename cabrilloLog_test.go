@@ -0,0 +1,154 @@
+package gomongo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/agustadewa/gomongo/cabrillo"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// cabrilloLog is sent by YB0XYZ: 2 QSOs, a bad time on line 8 and a QSO sent
+// by another station on line 9
+const cabrilloLog = "START-OF-LOG: 3.0\n" +
+	"CALLSIGN: YB0XYZ\n" +
+	"CONTEST: YB-DX\n" +
+	"CATEGORY-MODE: MIXED\n" +
+	"CATEGORY-POWER: LOW\n" +
+	"QSO:  7135 PH 2026-03-01 1230 YB0XYZ        59  001    YB0AAA        59  014\n" +
+	"QSO: 14200 CW 2026-03-01 1301 YB0XYZ        599 002    YB1BBB        599 007\n" +
+	"QSO: 14200 CW 2026-03-01 25:01 YB0XYZ        599 003    YC2CCC        599 001\n" +
+	"QSO:   144 PH 2026-03-01 1400 YB9ZZZ        59  004    YC3DDD        59  002\n" +
+	"END-OF-LOG:\n"
+
+// cabrilloEvent stores an event, imports log into it and returns the event
+// id with the result of the import
+func cabrilloEvent(t *testing.T, store Querier, log string) (string, ImportResult) {
+	t.Helper()
+	ctx := context.Background()
+	eventID := primitive.NewObjectID()
+	if _, err := store.QueryInsertV3(ctx, models.CollEvent, bson.M{"_id": eventID, "name": "YB DX Contest"}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := ImportCabrillo(ctx, store, eventID.Hex(), strings.NewReader(log), cabrillo.Options{}, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return eventID.Hex(), result
+}
+
+func TestImportCabrillo(t *testing.T) {
+	_, result := cabrilloEvent(t, NewMemoryAdaptor(), cabrilloLog)
+	if result.Imported != 2 || len(result.Errors) != 2 {
+		t.Fatalf("got %+v, want 2 QSOs and 2 errors", result)
+	}
+
+	var parseErr *cabrillo.ParseError
+	if !errors.As(result.Errors[0], &parseErr) || parseErr.Line != 8 || parseErr.Token != "25:01" {
+		t.Errorf("got %v, want the time of line 8", result.Errors[0])
+	}
+	var importErr *ImportError
+	if !errors.As(result.Errors[1], &importErr) || importErr.Line != 9 {
+		t.Errorf("got %v, want the QSO sent by YB9ZZZ on line 9", result.Errors[1])
+	}
+}
+
+func TestImportCabrilloHeadless(t *testing.T) {
+	headless := "START-OF-LOG: 3.0\nQSO:  7135 PH 2026-03-01 1230 YB0XYZ 59 001 YB0AAA 59 014\nEND-OF-LOG:\n"
+	if _, err := ImportCabrillo(context.Background(), NewMemoryAdaptor(), "event", strings.NewReader(headless), cabrillo.Options{}, ImportOptions{}); !errors.Is(err, ErrValidation) {
+		t.Fatalf("got %v, want a log without CALLSIGN rejected", err)
+	}
+}
+
+func TestLoadCabrilloLogs(t *testing.T) {
+	store := NewMemoryAdaptor()
+	eventID, _ := cabrilloEvent(t, store, cabrilloLog)
+
+	logs, err := LoadCabrilloLogs(context.Background(), store, eventID)
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("got %+v (%v), want 1 log", logs, err)
+	}
+	for _, c := range []struct {
+		name, got, want string
+	}{
+		{"call sign", logs[0].CallSign, "YB0XYZ"},
+		{"contest", logs[0].Contest, "YB-DX"},
+		{"category", logs[0].Categories["CATEGORY-POWER"], "LOW"},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestExportCabrillo(t *testing.T) {
+	store := NewMemoryAdaptor()
+	eventID, _ := cabrilloEvent(t, store, cabrilloLog)
+
+	var out bytes.Buffer
+	tags := []cabrillo.Tag{{Name: "CALLSIGN", Value: "YB0XYZ"}}
+	if written, err := ExportCabrillo(context.Background(), store, eventID, tags, ExportFilter{}, &out); err != nil || written != 2 {
+		t.Fatalf("got %d QSOs (%v), want 2", written, err)
+	}
+	exported, err := cabrillo.Read(&out, cabrillo.Options{SentExchFields: 1})
+	if err != nil || len(exported.QSOs) != 2 {
+		t.Fatalf("got %+v (%v), want the 2 QSOs back", exported, err)
+	}
+	// the 40m SSB QSO with YB0AAA comes first
+	qso := exported.QSOs[0]
+	for _, c := range []struct {
+		name, got, want string
+	}{
+		{"contest", exported.Contest(), "YB DX Contest"},
+		{"frequency", qso.Frequency, "7135"},
+		{"mode", qso.Mode, "PH"},
+		{"received call", qso.ReceivedCall, "YB0AAA"},
+		{"sent exchange", strings.Join(qso.SentExch, " "), "59"},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestCabrilloFrequency(t *testing.T) {
+	for _, c := range []struct {
+		frequency, band, want string
+	}{
+		{"7.135", "40m", "7135"},
+		{"145.240", "2m", "145240"},
+		{"", "40m", "7000"},
+		{"", "160 M", "1800"},
+		{"", "2m", "144"},
+		{"", "23cm", "1.2G"},
+		{"", "", ""},
+	} {
+		if got := cabrilloFrequency(c.frequency, c.band); got != c.want {
+			t.Errorf("%q %q: got %q, want %q", c.frequency, c.band, got, c.want)
+		}
+	}
+}
+
+func TestQSOFromCabrilloFrequency(t *testing.T) {
+	for _, c := range []struct {
+		freq, frequency, band string
+	}{
+		{"7135", "7.135", ""},
+		{"136", "0.136", ""},
+		{"475", "0.475", ""},
+		{"50", "", "6m"},
+		{"144", "", "2m"},
+		{"1.2G", "", "23cm"},
+		{"LIGHT", "", ""},
+	} {
+		qso := QSOFromCabrillo(cabrillo.QSO{Frequency: c.freq, Mode: "CW"})
+		if qso.Frequency != c.frequency || qso.Band != c.band {
+			t.Errorf("%s: got %q %q, want %q %q", c.freq, qso.Frequency, qso.Band, c.frequency, c.band)
+		}
+	}
+}
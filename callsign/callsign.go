@@ -0,0 +1,200 @@
+// Package callsign parses amateur radio call signs and resolves the DXCC
+// entity, continent and zones of a call sign from a country file in the
+// cty.dat format.
+package callsign
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Errors
+var (
+	ErrInvalid  = errors.New("invalid call sign")
+	ErrNoEntity = errors.New("no DXCC entity")
+)
+
+// basePattern matches a station call sign: a prefix of letters and digits
+// holding at least one letter, the call area digits and the suffix letters,
+// YB0AAA, 4X1AB, 3DA0XX, E51ABC or GB100RSGB
+var basePattern = regexp.MustCompile(`^([0-9]?[A-Z]{1,2}|[A-Z][0-9])[0-9]{1,4}[A-Z]{1,4}$`)
+
+// partPattern matches the parts written around the station call sign
+var partPattern = regexp.MustCompile(`^[A-Z0-9]{1,4}$`)
+
+// modifiers are the suffixes that tell how the station works, not where
+var modifiers = map[string]bool{
+	"P":    true,
+	"M":    true,
+	"MM":   true,
+	"AM":   true,
+	"A":    true,
+	"QRP":  true,
+	"QRPP": true,
+	"LH":   true,
+}
+
+// Call type is a call sign split around the station call sign
+type Call struct {
+	// Prefix is written before the station, VK2 of VK2/YB0AAA
+	Prefix string
+	// Base is the station call sign, YB0AAA
+	Base string
+	// Suffix is written after the station, P of YB0AAA/P or VE3 of W1AW/VE3
+	Suffix string
+}
+
+// Parse function validates a call sign and splits it, in upper case
+func Parse(s string) (Call, error) {
+	text := strings.ToUpper(strings.TrimSpace(s))
+	parts := strings.Split(text, "/")
+	if text == "" || len(parts) > 3 {
+		return Call{}, fmt.Errorf("%w %q", ErrInvalid, s)
+	}
+
+	// the station is the longest part shaped like one
+	base := -1
+	for i, part := range parts {
+		if basePattern.MatchString(part) && (base < 0 || len(part) > len(parts[base])) {
+			base = i
+		}
+	}
+	if base < 0 || base > 1 {
+		return Call{}, fmt.Errorf("%w %q", ErrInvalid, s)
+	}
+
+	call := Call{Base: parts[base]}
+	for i, part := range parts {
+		if i == base {
+			continue
+		}
+		if !partPattern.MatchString(part) {
+			return Call{}, fmt.Errorf("%w %q", ErrInvalid, s)
+		}
+		if i < base {
+			call.Prefix = part
+		} else if call.Suffix == "" {
+			call.Suffix = part
+		} else {
+			call.Suffix += "/" + part
+		}
+	}
+	return call, nil
+}
+
+// Normalize function returns the station call sign of s, YB0AAA for
+// yb0aaa/p or VK2/YB0AAA, so portable operation keys on the same station
+func Normalize(s string) (string, error) {
+	call, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return call.Base, nil
+}
+
+// String method
+func (call Call) String() string {
+	parts := make([]string, 0, 3)
+	if call.Prefix != "" {
+		parts = append(parts, call.Prefix)
+	}
+	parts = append(parts, call.Base)
+	if call.Suffix != "" {
+		parts = append(parts, call.Suffix)
+	}
+	return strings.Join(parts, "/")
+}
+
+// suffixes returns the parts of the suffix
+func (call Call) suffixes() []string {
+	if call.Suffix == "" {
+		return nil
+	}
+	return strings.Split(call.Suffix, "/")
+}
+
+// Portable method reports whether a suffix tells the station is portable,
+// mobile or low power
+func (call Call) Portable() bool {
+	for _, suffix := range call.suffixes() {
+		if modifiers[suffix] {
+			return true
+		}
+	}
+	return false
+}
+
+// MaritimeMobile method reports whether the station works from a ship or an
+// aircraft, which is in no DXCC entity
+func (call Call) MaritimeMobile() bool {
+	for _, suffix := range call.suffixes() {
+		if suffix == "MM" || suffix == "AM" {
+			return true
+		}
+	}
+	return false
+}
+
+// Location method returns the call sign the DXCC entity is looked up by: the
+// prefix, a suffix that is not a modifier, the station with its call area
+// replaced for a single digit suffix, or the station
+func (call Call) Location() string {
+	if call.Prefix != "" {
+		return call.Prefix
+	}
+	for _, suffix := range call.suffixes() {
+		switch {
+		case modifiers[suffix]:
+		case isDigit(suffix):
+			head, _, letters := call.split()
+			return head + suffix + letters
+		default:
+			return suffix
+		}
+	}
+	return call.Base
+}
+
+// WPX method returns the prefix of the call sign by the CQ WPX rules,
+// YB0 for YB0AAA, VK2 for VK2/YB0AAA, W4 for W1AW/4 and GB100 for GB100RSGB
+func (call Call) WPX() string {
+	head, digits, _ := call.split()
+	location := call.Prefix
+	for _, suffix := range call.suffixes() {
+		switch {
+		case modifiers[suffix]:
+		case isDigit(suffix):
+			digits = suffix
+		case location == "":
+			location = suffix
+		}
+	}
+
+	if location == "" {
+		return head + digits
+	}
+	if isDigit(location[len(location)-1:]) {
+		return location
+	}
+	return location + "0"
+}
+
+// isDigit reports whether s is a single digit
+func isDigit(s string) bool {
+	return len(s) == 1 && s[0] >= '0' && s[0] <= '9'
+}
+
+// split returns the prefix letters, the call area digits and the suffix
+// letters of the station
+func (call Call) split() (head, digits, letters string) {
+	match := basePattern.FindStringSubmatch(call.Base)
+	if match == nil {
+		return call.Base, "", ""
+	}
+	head = match[1]
+	rest := call.Base[len(head):]
+	end := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+	return head, rest[:end], rest[end:]
+}
@@ -0,0 +1,100 @@
+package callsign
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct {
+		in                     string
+		prefix, base, suffix   string
+		location, wpx, written string
+	}{
+		{"yb0aaa", "", "YB0AAA", "", "YB0AAA", "YB0", "YB0AAA"},
+		{"YB0AAA/P", "", "YB0AAA", "P", "YB0AAA", "YB0", "YB0AAA/P"},
+		{"VK2/YB0AAA", "VK2", "YB0AAA", "", "VK2", "VK2", "VK2/YB0AAA"},
+		{"W1AW/4", "", "W1AW", "4", "W4AW", "W4", "W1AW/4"},
+		{"W1AW/VE3", "", "W1AW", "VE3", "VE3", "VE3", "W1AW/VE3"},
+		{"GB100RSGB", "", "GB100RSGB", "", "GB100RSGB", "GB100", "GB100RSGB"},
+		{"YB0AAA/MM", "", "YB0AAA", "MM", "YB0AAA", "YB0", "YB0AAA/MM"},
+	} {
+		call, err := Parse(c.in)
+		if err != nil {
+			t.Errorf("%q: %v", c.in, err)
+			continue
+		}
+		if call.Prefix != c.prefix || call.Base != c.base || call.Suffix != c.suffix {
+			t.Errorf("%q: got %+v, want %s %s %s", c.in, call, c.prefix, c.base, c.suffix)
+		}
+		if call.Location() != c.location || call.WPX() != c.wpx || call.String() != c.written {
+			t.Errorf("%q: got location %s, prefix %s, written %s", c.in, call.Location(), call.WPX(), call)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{"", "YB", "12345", "A/B/C/D", "YB0AAA/TOOLONG"} {
+		if _, err := Parse(in); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%q: got %v, want ErrInvalid", in, err)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if base, err := Normalize("vk2/yb0aaa/p"); err != nil || base != "YB0AAA" {
+		t.Errorf("got %q (%v), want YB0AAA", base, err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for in, want := range map[string]string{
+		"YB0AAA":     "Indonesia",
+		"YC2CCC/P":   "Indonesia",
+		"VK2/YB0AAA": "Australia",
+		"W1AW":       "United States",
+		"YB0AAA/VK6": "Australia",
+	} {
+		entity, err := Lookup(in)
+		if err != nil || entity.Name != want {
+			t.Errorf("%q: got %q (%v), want %q", in, entity.Name, err, want)
+		}
+	}
+
+	entity, err := Lookup("VK6ABC")
+	if err != nil || entity.CQZone != 29 || entity.ITUZone != 58 {
+		t.Errorf("got %+v (%v), want the zones of the VK6 override", entity, err)
+	}
+	if _, err := Lookup("YB0AAA/MM"); !errors.Is(err, ErrNoEntity) {
+		t.Errorf("got %v, want no entity for maritime mobile", err)
+	}
+}
+
+func TestReadDB(t *testing.T) {
+	db, err := ReadDB(strings.NewReader("Testland: 1: 2: EU: 50.00: -10.00: -1.0: *TL:\n    TL,=TL1XX(5)[6]{AF};\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testland := Entity{Name: "Testland", Prefix: "TL", Continent: "EU", CQZone: 1, ITUZone: 2, Latitude: 50, Longitude: 10, UTCOffset: 1, WAE: true}
+	overridden := testland
+	overridden.CQZone, overridden.ITUZone, overridden.Continent = 5, 6, "AF"
+	for _, c := range []struct {
+		call string
+		want Entity
+	}{
+		{"TL2AB", testland},
+		// the exact call carries its own zones and continent
+		{"TL1XX", overridden},
+	} {
+		if got, err := db.Lookup(c.call); err != nil || got != c.want {
+			t.Errorf("%s: got %+v (%v), want %+v", c.call, got, err, c.want)
+		}
+	}
+}
+
+func TestReadDBInvalid(t *testing.T) {
+	if _, err := ReadDB(strings.NewReader("not a country file;")); !errors.Is(err, ErrCountryFile) {
+		t.Fatalf("got %v, want ErrCountryFile", err)
+	}
+}
@@ -0,0 +1,276 @@
+package callsign
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrCountryFile is returned for a country file that is not in the cty.dat format
+var ErrCountryFile = errors.New("invalid country file")
+
+// bundled is a short country file with the entities the service sees most,
+// load the full cty.dat of country-files.com with Default.LoadFile
+//
+//go:embed cty.dat
+var bundled []byte
+
+// Default database, loaded from the bundled country file
+var Default = mustDB(bundled)
+
+// Entity type is a DXCC entity, or the zones of a call sign within one
+type Entity struct {
+	Name      string  `json:"name" bson:"name"`
+	Prefix    string  `json:"prefix" bson:"prefix"`
+	Continent string  `json:"continent" bson:"continent"`
+	CQZone    int     `json:"cq_zone" bson:"cq_zone"`
+	ITUZone   int     `json:"itu_zone" bson:"itu_zone"`
+	Latitude  float64 `json:"latitude" bson:"latitude"`
+	// Longitude is east positive, the opposite of cty.dat
+	Longitude float64 `json:"longitude" bson:"longitude"`
+	// UTCOffset is the local time offset in hours, east positive
+	UTCOffset float64 `json:"utc_offset" bson:"utc_offset"`
+	// WAE marks the entities of the Worked All Europe list only, *TA1 in cty.dat
+	WAE bool `json:"wae,omitempty" bson:"wae,omitempty"`
+}
+
+// DB type resolves call signs to entities, it is safe for concurrent use and
+// can be reloaded while in use
+type DB struct {
+	mu       sync.RWMutex
+	entities []Entity
+	prefixes map[string]Entity
+	exact    map[string]Entity
+	longest  int
+}
+
+// NewDB function returns an empty database
+func NewDB() *DB {
+	return &DB{prefixes: map[string]Entity{}, exact: map[string]Entity{}}
+}
+
+// ReadDB function reads a country file in the cty.dat format
+func ReadDB(r io.Reader) (*DB, error) {
+	db := NewDB()
+	if err := db.Load(r); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// mustDB reads the bundled country file
+func mustDB(data []byte) *DB {
+	db, err := ReadDB(bytes.NewReader(data))
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// LoadFile method replaces the content of the database with a country file
+func (db *DB) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return db.Load(file)
+}
+
+// Load method replaces the content of the database with a country file in
+// the cty.dat format. The database is left as it was when the file is invalid.
+//
+// Each entity is a line of eight colon terminated fields, name, CQ zone, ITU
+// zone, continent, latitude, longitude west positive, UTC offset west
+// positive and primary prefix, followed by its comma separated prefixes up to
+// a semicolon. A prefix may override the zones with (CQ) and [ITU], the
+// position with <lat/long>, the continent with {EU} and the offset with
+// ~offset~. A prefix starting with = is a whole call sign.
+func (db *DB) Load(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	loaded := NewDB()
+	for n, record := range strings.Split(string(data), ";") {
+		record = strings.TrimSpace(record)
+		if record == "" {
+			continue
+		}
+		if err := loaded.add(record); err != nil {
+			return fmt.Errorf("%w: record %d: %v", ErrCountryFile, n+1, err)
+		}
+	}
+	if len(loaded.entities) == 0 {
+		return fmt.Errorf("%w: no entity", ErrCountryFile)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.entities, db.prefixes, db.exact, db.longest = loaded.entities, loaded.prefixes, loaded.exact, loaded.longest
+	return nil
+}
+
+// add reads one entity record, the caller owns db
+func (db *DB) add(record string) error {
+	fields := strings.SplitN(record, ":", 9)
+	if len(fields) != 9 {
+		return errors.New("want 8 fields before the prefixes")
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	entity := Entity{Name: fields[0], Continent: fields[3], Prefix: fields[7]}
+	var err error
+	if entity.CQZone, err = strconv.Atoi(fields[1]); err != nil {
+		return fmt.Errorf("CQ zone %q", fields[1])
+	}
+	if entity.ITUZone, err = strconv.Atoi(fields[2]); err != nil {
+		return fmt.Errorf("ITU zone %q", fields[2])
+	}
+	if entity.Latitude, err = strconv.ParseFloat(fields[4], 64); err != nil {
+		return fmt.Errorf("latitude %q", fields[4])
+	}
+	if entity.Longitude, err = strconv.ParseFloat(fields[5], 64); err != nil {
+		return fmt.Errorf("longitude %q", fields[5])
+	}
+	if entity.UTCOffset, err = strconv.ParseFloat(fields[6], 64); err != nil {
+		return fmt.Errorf("UTC offset %q", fields[6])
+	}
+	entity.Longitude, entity.UTCOffset = -entity.Longitude, -entity.UTCOffset
+	if strings.HasPrefix(entity.Prefix, "*") {
+		entity.Prefix, entity.WAE = entity.Prefix[1:], true
+	}
+	db.entities = append(db.entities, entity)
+
+	for _, alias := range strings.Split(fields[8], ",") {
+		alias = strings.Join(strings.Fields(alias), "")
+		if alias == "" {
+			continue
+		}
+		if err := db.addAlias(alias, entity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addAlias reads one prefix of entity with its overrides
+func (db *DB) addAlias(alias string, entity Entity) error {
+	exact := strings.HasPrefix(alias, "=")
+	alias = strings.TrimPrefix(alias, "=")
+
+	end := strings.IndexAny(alias, "([<{~")
+	if end < 0 {
+		end = len(alias)
+	}
+	prefix, overrides := strings.ToUpper(alias[:end]), alias[end:]
+	if prefix == "" {
+		return fmt.Errorf("empty prefix %q", alias)
+	}
+
+	for overrides != "" {
+		closing := map[byte]byte{'(': ')', '[': ']', '<': '>', '{': '}', '~': '~'}[overrides[0]]
+		stop := strings.IndexByte(overrides[1:], closing)
+		if closing == 0 || stop < 0 {
+			return fmt.Errorf("prefix %q: bad override", alias)
+		}
+		value := overrides[1 : stop+1]
+
+		var err error
+		switch overrides[0] {
+		case '(':
+			entity.CQZone, err = strconv.Atoi(value)
+		case '[':
+			entity.ITUZone, err = strconv.Atoi(value)
+		case '{':
+			entity.Continent = value
+		case '~':
+			entity.UTCOffset, err = strconv.ParseFloat(value, 64)
+			entity.UTCOffset = -entity.UTCOffset
+		case '<':
+			position := strings.SplitN(value, "/", 2)
+			if len(position) != 2 {
+				return fmt.Errorf("prefix %q: bad position", alias)
+			}
+			if entity.Latitude, err = strconv.ParseFloat(position[0], 64); err == nil {
+				entity.Longitude, err = strconv.ParseFloat(position[1], 64)
+				entity.Longitude = -entity.Longitude
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("prefix %q: bad override %q", alias, value)
+		}
+		overrides = overrides[stop+2:]
+	}
+
+	if exact {
+		db.exact[prefix] = entity
+		return nil
+	}
+	db.prefixes[prefix] = entity
+	if len(prefix) > db.longest {
+		db.longest = len(prefix)
+	}
+	return nil
+}
+
+// Entities method returns the entities in file order
+func (db *DB) Entities() []Entity {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return append([]Entity{}, db.entities...)
+}
+
+// Lookup method parses s and returns its entity, see LookupCall
+func (db *DB) Lookup(s string) (Entity, error) {
+	call, err := Parse(s)
+	if err != nil {
+		return Entity{}, err
+	}
+	return db.LookupCall(call)
+}
+
+// LookupCall method returns the entity of call: the entity of the whole call
+// sign when the country file lists it, else the entity of the longest prefix
+// of its location, see Call.Location. Maritime and aeronautical mobile
+// stations are in no entity.
+func (db *DB) LookupCall(call Call) (Entity, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if entity, ok := db.exact[call.String()]; ok {
+		return entity, nil
+	}
+	if call.MaritimeMobile() {
+		return Entity{}, fmt.Errorf("%w: %s is maritime mobile", ErrNoEntity, call)
+	}
+	location := call.Location()
+	if entity, ok := db.exact[location]; ok {
+		return entity, nil
+	}
+
+	length := len(location)
+	if length > db.longest {
+		length = db.longest
+	}
+	for i := length; i > 0; i-- {
+		if entity, ok := db.prefixes[location[:i]]; ok {
+			return entity, nil
+		}
+	}
+	return Entity{}, fmt.Errorf("%w: %s", ErrNoEntity, call)
+}
+
+// Lookup function, see DB.Lookup of the Default database
+func Lookup(s string) (Entity, error) {
+	return Default.Lookup(s)
+}
@@ -0,0 +1,112 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/agustadewa/gomongo/tools"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollCertLayout collection of stored certificate layouts, keyed by name
+const CollCertLayout = "cert_layout"
+
+// CollCertLayoutRef collection naming the stored layout a template type is
+// rendered with
+const CollCertLayoutRef = "cert_layout_ref"
+
+// QueryCertLayout method
+func (adaptor *Adaptor) QueryCertLayout(ctx context.Context, name string, result *tools.CertLayout) error {
+	return adaptor.QueryFindV2(ctx, CollCertLayout, &options.FindOneOptions{}, bson.M{"name": name}, result)
+}
+
+// QuerySaveCertLayout method, see SaveCertLayout
+func (adaptor *Adaptor) QuerySaveCertLayout(ctx context.Context, layout tools.CertLayout) error {
+	return SaveCertLayout(ctx, adaptor, layout)
+}
+
+// SaveCertLayout function inserts or replaces the layout with the same name
+func SaveCertLayout(ctx context.Context, store Querier, layout tools.CertLayout) error {
+	if err := layout.Validate(); err != nil {
+		return &Error{Op: "SaveCertLayout", Kind: ErrValidation, Err: err}
+	}
+
+	update := bson.M{"$set": bson.M{"fields": layout.Fields, "page": layout.Page}}
+	return store.QueryUpdateOne(ctx, CollCertLayout, options.Update().SetUpsert(true), bson.M{"name": layout.Name}, update, nil)
+}
+
+// UseCertLayout method, see UseCertLayout
+func (adaptor *Adaptor) UseCertLayout(ctx context.Context, templateType, name string) error {
+	return UseCertLayout(ctx, adaptor, templateType, name)
+}
+
+// UseCertLayout function makes the templates of templateType render with the
+// layout stored under name, ErrNotFound when there is none
+func UseCertLayout(ctx context.Context, store Querier, templateType, name string) error {
+	var layout tools.CertLayout
+	if err := store.QueryFindV2(ctx, CollCertLayout, nil, bson.M{"name": name}, &layout); err != nil {
+		return err
+	}
+
+	update := bson.M{"$set": bson.M{"layout": name}}
+	return store.QueryUpdateOne(ctx, CollCertLayoutRef, options.Update().SetUpsert(true), bson.M{"template_type": templateType}, update, nil)
+}
+
+// CertLayoutFor method, see CertLayoutFor
+func (adaptor *Adaptor) CertLayoutFor(ctx context.Context, imageCertTemplate models.ImageCertTemplate) (tools.CertLayout, error) {
+	return CertLayoutFor(ctx, adaptor, imageCertTemplate)
+}
+
+// CertLayoutFor function returns the layout the template type was pointed at
+// by UseCertLayout, else the layout stored under the template type, falling
+// back to the built in TYPE 1 … TYPE 5 layouts
+func CertLayoutFor(ctx context.Context, store Querier, imageCertTemplate models.ImageCertTemplate) (tools.CertLayout, error) {
+	var layout tools.CertLayout
+	templateType := imageCertTemplate.TemplateProperties.TemplateType
+
+	name := templateType
+	var ref struct {
+		Layout string `bson:"layout"`
+	}
+	err := store.QueryFindV2(ctx, CollCertLayoutRef, nil, bson.M{"template_type": templateType}, &ref)
+	if err == nil {
+		name = ref.Layout
+	} else if !errors.Is(err, ErrNotFound) {
+		return layout, err
+	}
+
+	err = store.QueryFindV2(ctx, CollCertLayout, nil, bson.M{"name": name}, &layout)
+	if err == nil {
+		return layout, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return layout, err
+	}
+
+	return tools.LegacyLayout(imageCertTemplate)
+}
+
+// MigrateCertLayout method, see MigrateCertLayout
+func (adaptor *Adaptor) MigrateCertLayout(ctx context.Context, name string, imageCertTemplate models.ImageCertTemplate) (tools.CertLayout, error) {
+	return MigrateCertLayout(ctx, adaptor, name, imageCertTemplate)
+}
+
+// MigrateCertLayout function stores the built in layout of a TYPE 1 … TYPE 5
+// template under name and points the template type at it, so the event keeps
+// rendering once the layout is edited
+func MigrateCertLayout(ctx context.Context, store Querier, name string, imageCertTemplate models.ImageCertTemplate) (tools.CertLayout, error) {
+	layout, err := tools.LegacyLayout(imageCertTemplate)
+	if err != nil {
+		return layout, err
+	}
+	layout.Name = name
+
+	return layout, store.WithTransaction(ctx, func(txCtx context.Context) error {
+		if err := SaveCertLayout(txCtx, store, layout); err != nil {
+			return err
+		}
+		return UseCertLayout(txCtx, store, imageCertTemplate.TemplateProperties.TemplateType, name)
+	})
+}
@@ -0,0 +1,43 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+)
+
+func TestMigrateCertLayout(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	var template models.ImageCertTemplate
+	template.TemplateProperties.TemplateType = "TYPE 1"
+	template.TemplateProperties.CallSign.FontName = "Helvetica"
+	template.TemplateProperties.IdentityName.FontName = "Helvetica"
+	template.TemplateProperties.Frequency.FontName = "Helvetica"
+
+	builtIn, err := CertLayoutFor(ctx, store, template)
+	if err != nil || len(builtIn.Fields) < 2 {
+		t.Fatalf("got %+v (%v), want the built in TYPE 1 layout", builtIn, err)
+	}
+
+	layout, err := MigrateCertLayout(ctx, store, "field-day", template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the layout is edited under its own name after the migration
+	layout.Fields = layout.Fields[:1]
+	if err := SaveCertLayout(ctx, store, layout); err != nil {
+		t.Fatal(err)
+	}
+	got, err := CertLayoutFor(ctx, store, template)
+	if err != nil || got.Name != "field-day" || len(got.Fields) != 1 {
+		t.Fatalf("got %+v (%v), want the edited field-day layout", got, err)
+	}
+
+	if err := UseCertLayout(ctx, store, "TYPE 2", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound for an unknown layout", err)
+	}
+}
@@ -0,0 +1,13 @@
+package gomongo_test
+
+import (
+	"testing"
+
+	"github.com/agustadewa/gomongo/gomongotest"
+)
+
+func TestQuerier(t *testing.T) {
+	for name, newStore := range gomongotest.Backends(t) {
+		t.Run(name, func(t *testing.T) { gomongotest.Run(t, newStore) })
+	}
+}
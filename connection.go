@@ -0,0 +1,245 @@
+package gomongo
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// defaultDegradedLatency is the primary ping latency above which Health reports HealthDegraded
+const defaultDegradedLatency = 500 * time.Millisecond
+
+// defaultHealthTimeout bounds each ping of Health
+const defaultHealthTimeout = 2 * time.Second
+
+// connectConfig is built by the Option functions passed to NewAdaptor
+type connectConfig struct {
+	client          *options.ClientOptions
+	degradedLatency time.Duration
+	healthTimeout   time.Duration
+	filters         *FilterParser
+	dupes           *DupeChecker
+	geoIndex        bool
+}
+
+// Option configures NewAdaptor
+type Option func(*connectConfig)
+
+// WithMaxPoolSize option
+func WithMaxPoolSize(size uint64) Option {
+	return func(cfg *connectConfig) { cfg.client.SetMaxPoolSize(size) }
+}
+
+// WithMinPoolSize option
+func WithMinPoolSize(size uint64) Option {
+	return func(cfg *connectConfig) { cfg.client.SetMinPoolSize(size) }
+}
+
+// WithServerSelectionTimeout option, how long an operation waits for a
+// suitable server before failing with ErrTimeout
+func WithServerSelectionTimeout(timeout time.Duration) Option {
+	return func(cfg *connectConfig) { cfg.client.SetServerSelectionTimeout(timeout) }
+}
+
+// WithConnectTimeout option
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(cfg *connectConfig) { cfg.client.SetConnectTimeout(timeout) }
+}
+
+// WithHeartbeatInterval option, how often the driver checks the servers and
+// reconnects to the ones that came back
+func WithHeartbeatInterval(interval time.Duration) Option {
+	return func(cfg *connectConfig) { cfg.client.SetHeartbeatInterval(interval) }
+}
+
+// WithRetryWrites option
+func WithRetryWrites(retry bool) Option {
+	return func(cfg *connectConfig) { cfg.client.SetRetryWrites(retry) }
+}
+
+// WithRetryReads option
+func WithRetryReads(retry bool) Option {
+	return func(cfg *connectConfig) { cfg.client.SetRetryReads(retry) }
+}
+
+// WithAppName option, shown in the server logs and currentOp
+func WithAppName(name string) Option {
+	return func(cfg *connectConfig) { cfg.client.SetAppName(name) }
+}
+
+// WithCompressors option, e.g. "zstd", "zlib", "snappy"
+func WithCompressors(compressors ...string) Option {
+	return func(cfg *connectConfig) { cfg.client.SetCompressors(compressors) }
+}
+
+// WithTLSConfig option
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(cfg *connectConfig) { cfg.client.SetTLSConfig(tlsConfig) }
+}
+
+// WithDegradedLatency option, the primary ping latency above which Health
+// reports HealthDegraded
+func WithDegradedLatency(latency time.Duration) Option {
+	return func(cfg *connectConfig) { cfg.degradedLatency = latency }
+}
+
+// WithHealthTimeout option, how long each ping of Health waits for an
+// answer, 2s when not set
+func WithHealthTimeout(timeout time.Duration) Option {
+	return func(cfg *connectConfig) { cfg.healthTimeout = timeout }
+}
+
+// WithFilterParser option, the parser QueryFind and QueryFindMany check
+// client filters with
+func WithFilterParser(parser *FilterParser) Option {
+	return func(cfg *connectConfig) { cfg.filters = parser }
+}
+
+// WithDupeChecker option, the dupe rules of the events ImportQSOs and
+// RecordQSO apply
+func WithDupeChecker(checker *DupeChecker) Option {
+	return func(cfg *connectConfig) { cfg.dupes = checker }
+}
+
+// WithGeoIndex option, NewAdaptor creates the 2dsphere index the geo
+// queries need, see Adaptor.EnsureGeoIndex
+func WithGeoIndex() Option {
+	return func(cfg *connectConfig) { cfg.geoIndex = true }
+}
+
+// NewAdaptor function connects to uri and returns an Adaptor working on dbName
+func NewAdaptor(ctx context.Context, uri, dbName string, opts ...Option) (*Adaptor, error) {
+	cfg := connectConfig{
+		client:          options.Client().ApplyURI(uri),
+		degradedLatency: defaultDegradedLatency,
+		healthTimeout:   defaultHealthTimeout,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	Client, err := mongo.Connect(ctx, cfg.client)
+	if err != nil {
+		return nil, wrapError("NewAdaptor", err)
+	}
+
+	adaptor := &Adaptor{
+		Client:          *Client,
+		DBName:          dbName,
+		degradedLatency: cfg.degradedLatency,
+		healthTimeout:   cfg.healthTimeout,
+		filters:         cfg.filters,
+		dupes:           cfg.dupes,
+	}
+	if cfg.geoIndex {
+		if err := adaptor.EnsureGeoIndex(ctx); err != nil {
+			_ = adaptor.Disconnect(ctx)
+			return nil, err
+		}
+	}
+	return adaptor, nil
+}
+
+// Disconnect method closes every pooled connection
+func (adaptor *Adaptor) Disconnect(ctx context.Context) error {
+	return wrapError("Disconnect", adaptor.Client.Disconnect(ctx))
+}
+
+// Close method is Disconnect with a background context
+func (adaptor *Adaptor) Close() error {
+	return adaptor.Disconnect(context.Background())
+}
+
+// Ping method checks the primary is reachable
+func (adaptor *Adaptor) Ping(ctx context.Context) error {
+	return wrapError("Ping", adaptor.Client.Ping(ctx, readpref.Primary()))
+}
+
+// HealthStatus type
+type HealthStatus string
+
+// Health statuses
+const (
+	// HealthUp the primary answers in time
+	HealthUp HealthStatus = "up"
+	// HealthDegraded the primary answers but slower than the degraded latency
+	HealthDegraded HealthStatus = "degraded"
+	// HealthPrimaryUnreachable only secondaries answer, reads may work but writes fail
+	HealthPrimaryUnreachable HealthStatus = "primary_unreachable"
+	// HealthDown no server answers
+	HealthDown HealthStatus = "down"
+)
+
+// Health type is the result of Adaptor.Health
+type Health struct {
+	Status    HealthStatus `json:"status"`
+	LatencyMS int64        `json:"latency_ms"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// Ready method reports whether the service can serve writes
+func (health Health) Ready() bool {
+	return health.Status == HealthUp || health.Status == HealthDegraded
+}
+
+// HTTPStatus method maps the status to 200 or 503
+func (health Health) HTTPStatus() int {
+	if health.Ready() {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
+
+// Health method pings the primary and, at the same time, the nearest member,
+// which tells whether any member answers when the primary is unreachable.
+// Each ping waits at most the health timeout, see WithHealthTimeout.
+func (adaptor *Adaptor) Health(ctx context.Context) Health {
+	timeout := adaptor.healthTimeout
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
+	}
+	ping := func(pref *readpref.ReadPref) (time.Duration, error) {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		start := time.Now()
+		err := adaptor.Client.Ping(pingCtx, pref)
+		return time.Since(start), err
+	}
+
+	errNearest := make(chan error, 1)
+	go func() {
+		_, err := ping(readpref.Nearest())
+		errNearest <- err
+	}()
+	latency, errPrimary := ping(readpref.Primary())
+
+	if errPrimary == nil {
+		health := Health{Status: HealthUp, LatencyMS: latency.Milliseconds()}
+		degradedLatency := adaptor.degradedLatency
+		if degradedLatency == 0 {
+			degradedLatency = defaultDegradedLatency
+		}
+		if latency > degradedLatency {
+			health.Status = HealthDegraded
+		}
+		return health
+	}
+
+	health := Health{Status: HealthDown, Error: errPrimary.Error()}
+	if <-errNearest == nil {
+		health.Status = HealthPrimaryUnreachable
+	}
+	return health
+}
+
+// HealthHandler method is a gin handler for a /healthz route
+func (adaptor *Adaptor) HealthHandler(c *gin.Context) {
+	health := adaptor.Health(c.Request.Context())
+	c.JSON(health.HTTPStatus(), health)
+}
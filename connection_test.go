@@ -0,0 +1,85 @@
+package gomongo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestOptions(t *testing.T) {
+	parser := NewFilterParser(FilterPolicy{})
+	checker := NewDupeChecker(DupeRule{})
+	cfg := connectConfig{client: options.Client()}
+	for _, opt := range []Option{
+		WithMaxPoolSize(20),
+		WithMinPoolSize(2),
+		WithServerSelectionTimeout(3 * time.Second),
+		WithConnectTimeout(4 * time.Second),
+		WithRetryWrites(false),
+		WithAppName("qsl"),
+		WithDegradedLatency(time.Second),
+		WithHealthTimeout(time.Second),
+		WithFilterParser(parser),
+		WithDupeChecker(checker),
+		WithGeoIndex(),
+	} {
+		opt(&cfg)
+	}
+
+	client := cfg.client
+	if *client.MaxPoolSize != 20 || *client.MinPoolSize != 2 || *client.ServerSelectionTimeout != 3*time.Second || *client.ConnectTimeout != 4*time.Second {
+		t.Fatalf("got %+v, want the pool and timeouts set", client)
+	}
+	if *client.RetryWrites || *client.AppName != "qsl" {
+		t.Fatalf("got %+v, want retry writes off and the app name set", client)
+	}
+	if cfg.degradedLatency != time.Second || cfg.healthTimeout != time.Second || cfg.filters != parser || cfg.dupes != checker || !cfg.geoIndex {
+		t.Fatalf("got %+v, want the adaptor settings", cfg)
+	}
+}
+
+func TestHealthHTTPStatus(t *testing.T) {
+	for status, want := range map[HealthStatus]int{
+		HealthUp:                 http.StatusOK,
+		HealthDegraded:           http.StatusOK,
+		HealthPrimaryUnreachable: http.StatusServiceUnavailable,
+		HealthDown:               http.StatusServiceUnavailable,
+	} {
+		if got := (Health{Status: status}).HTTPStatus(); got != want {
+			t.Errorf("%s: got %d, want %d", status, got, want)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	// nothing listens on port 1, the server selection would wait a minute
+	adaptor, err := NewAdaptor(context.Background(), "mongodb://127.0.0.1:1", "test", WithServerSelectionTimeout(time.Minute), WithHealthTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adaptor.Close()
+
+	start := time.Now()
+	if health := adaptor.Health(context.Background()); health.Status != HealthDown || health.Error == "" {
+		t.Fatalf("got %+v, want the server down", health)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("took %v, want the pings bounded by the health timeout", elapsed)
+	}
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	adaptor.HealthHandler(c)
+	var health Health
+	if err := json.Unmarshal(recorder.Body.Bytes(), &health); err != nil || recorder.Code != http.StatusServiceUnavailable || health.Status != HealthDown {
+		t.Fatalf("got %d %s (%v), want 503 and the server down", recorder.Code, recorder.Body, err)
+	}
+}
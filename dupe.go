@@ -0,0 +1,276 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/agustadewa/gomongo/bandplan"
+	"github.com/agustadewa/gomongo/modes"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DupeAction type is what happens to a QSO found to be a duplicate
+type DupeAction string
+
+// Dupe actions
+const (
+	// DupeAllow stores duplicates like any other QSO, so does the zero action
+	DupeAllow DupeAction = "allow"
+	// DupeReject refuses the duplicate with ErrDupe
+	DupeReject DupeAction = "reject"
+	// DupeFlag stores the duplicate with dupe: true on its attribute, flagged
+	// attributes get no certificate number
+	DupeFlag DupeAction = "flag"
+	// DupeMerge folds the duplicate into the first QSO, it is not stored and
+	// no error is reported
+	DupeMerge DupeAction = "merge"
+)
+
+// DupeRule type tells when a QSO repeats an earlier one: same call sign and
+// band, same mode when ByMode is set, and less than Window apart, a Window of
+// 0 spans the whole event
+type DupeRule struct {
+	ByMode bool          `json:"by_mode" bson:"by_mode"`
+	Window time.Duration `json:"window" bson:"window"`
+	Action DupeAction    `json:"action" bson:"action"`
+}
+
+// dupeContact is a QSO as far as the dupe rule is concerned
+type dupeContact struct {
+	band    string
+	mode    string
+	date    time.Time
+	flagged bool
+}
+
+// newDupeContact returns the contact of an attribute, the band is derived
+// from the frequency when it is missing
+func newDupeContact(frequency, band, mode string, date time.Time) dupeContact {
+	if band == "" {
+		if f, err := bandplan.Parse(frequency); err == nil {
+			if found, err := bandplan.BandOf(f, bandplan.AnyRegion); err == nil {
+				band = found.Name
+			}
+		}
+	}
+	if canonical := bandplan.NormalizeBand(band); canonical != "" {
+		band = canonical
+	} else if band == "" {
+		band = frequency
+	}
+	return dupeContact{band: band, mode: modes.Canonical(mode), date: date}
+}
+
+// attributeContact returns the contact of a stored attribute
+func attributeContact(attribute bson.M) dupeContact {
+	frequency, _ := attribute["frequency"].(string)
+	band, _ := attribute["band"].(string)
+	mode, _ := attribute["mode"].(string)
+	date, _ := attribute["date"].(string)
+	millis, _ := strconv.ParseInt(date, 10, 64)
+
+	contact := newDupeContact(frequency, band, mode, timeFromMillis(millis))
+	contact.flagged, _ = attribute["dupe"].(bool)
+	return contact
+}
+
+// repeats reports whether contact repeats earlier under the rule
+func (rule DupeRule) repeats(earlier, contact dupeContact) bool {
+	if earlier.flagged || earlier.band != contact.band || (rule.ByMode && earlier.mode != contact.mode) {
+		return false
+	}
+	if rule.Window <= 0 {
+		return true
+	}
+	apart := contact.date.Sub(earlier.date)
+	if apart < 0 {
+		apart = -apart
+	}
+	return apart < rule.Window
+}
+
+// first returns the first of worked contact repeats, false when it is new
+func (rule DupeRule) first(worked []dupeContact, contact dupeContact) (dupeContact, bool) {
+	if rule.Action == DupeAllow || rule.Action == "" {
+		return dupeContact{}, false
+	}
+	for _, earlier := range worked {
+		if rule.repeats(earlier, contact) {
+			return earlier, true
+		}
+	}
+	return dupeContact{}, false
+}
+
+// DupeChecker type holds the dupe rule of each event
+type DupeChecker struct {
+	mu       sync.RWMutex
+	fallback DupeRule
+	rules    map[string]DupeRule
+}
+
+// NewDupeChecker function, fallback applies to events without their own rule
+func NewDupeChecker(fallback DupeRule) *DupeChecker {
+	return &DupeChecker{fallback: fallback, rules: map[string]DupeRule{}}
+}
+
+// defaultDupeChecker is used by adaptors without a checker of their own, it
+// stores every QSO as before the dupe rules, flagging is set per adaptor with
+// SetDupeChecker. ScanDupes still reports the same call sign on the same band
+// and mode.
+var defaultDupeChecker = NewDupeChecker(DupeRule{ByMode: true, Action: DupeAllow})
+
+// Set method sets the rule of eventID
+func (checker *DupeChecker) Set(eventID string, rule DupeRule) *DupeChecker {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+	checker.rules[eventID] = rule
+	return checker
+}
+
+// Rule method returns the rule applied to eventID
+func (checker *DupeChecker) Rule(eventID string) DupeRule {
+	checker.mu.RLock()
+	defer checker.mu.RUnlock()
+	if rule, ok := checker.rules[eventID]; ok {
+		return rule
+	}
+	return checker.fallback
+}
+
+// SetDupeChecker method sets the checker ImportQSOs and RecordQSO apply
+func (adaptor *Adaptor) SetDupeChecker(checker *DupeChecker) {
+	adaptor.dupes = checker
+}
+
+// dupeChecker returns the checker of the adaptor or the default one
+func (adaptor *Adaptor) dupeChecker() *DupeChecker {
+	if adaptor.dupes == nil {
+		return defaultDupeChecker
+	}
+	return adaptor.dupes
+}
+
+// SetDupeChecker method sets the checker ImportQSOs and RecordQSO apply
+func (m *MemoryAdaptor) SetDupeChecker(checker *DupeChecker) {
+	m.dupes = checker
+}
+
+// dupeChecker returns the checker of the adaptor or the default one
+func (m *MemoryAdaptor) dupeChecker() *DupeChecker {
+	if m.dupes == nil {
+		return defaultDupeChecker
+	}
+	return m.dupes
+}
+
+// dupeRule returns the rule store applies to eventID
+func dupeRule(store Querier, eventID string) DupeRule {
+	if holder, ok := store.(interface{ dupeChecker() *DupeChecker }); ok {
+		return holder.dupeChecker().Rule(eventID)
+	}
+	return defaultDupeChecker.Rule(eventID)
+}
+
+// RecordQSO method stores one QSO of eventID, see RecordQSO
+func (adaptor *Adaptor) RecordQSO(ctx context.Context, eventID string, qso QSO, region bandplan.Region) (ImportResult, error) {
+	return RecordQSO(ctx, adaptor, eventID, qso, region)
+}
+
+// RecordQSO function stores one QSO of eventID with the checks of
+// ImportQSOs. An invalid QSO fails with ErrValidation and a duplicate the
+// dupe rule of the event rejects fails with ErrDupe.
+func RecordQSO(ctx context.Context, store Querier, eventID string, qso QSO, region bandplan.Region) (ImportResult, error) {
+	result, err := ImportQSOs(ctx, store, eventID, []QSO{qso}, ImportOptions{Region: region})
+	if err != nil {
+		return result, err
+	}
+	return result, importFailure("RecordQSO", result)
+}
+
+// importFailure returns the first error of result, ErrDupe when the dupe
+// rule rejected the QSO, else ErrValidation, nil when there is none
+func importFailure(op string, result ImportResult) error {
+	if len(result.Errors) == 0 {
+		return nil
+	}
+	kind := ErrValidation
+	if errors.Is(result.Errors[0], ErrDupe) {
+		kind = ErrDupe
+	}
+	return &Error{Op: op, Kind: kind, Err: result.Errors[0]}
+}
+
+// Dupe type is a duplicate found by ScanDupes
+type Dupe struct {
+	CallSign string    `json:"call_sign"`
+	Band     string    `json:"band"`
+	Mode     string    `json:"mode"`
+	Date     time.Time `json:"date"`
+	// First is the date of the QSO it repeats
+	First time.Time `json:"first"`
+	// Flagged is set when the attribute is already flagged as a dupe
+	Flagged bool `json:"flagged"`
+}
+
+// ScanDupes method reports the duplicates of eventID under its dupe rule
+func (adaptor *Adaptor) ScanDupes(ctx context.Context, eventID string) ([]Dupe, error) {
+	return ScanDupes(ctx, adaptor, eventID, adaptor.dupeChecker().Rule(eventID))
+}
+
+// ScanDupes function reports the duplicates stored in eventID under rule,
+// whatever its action, reading one identity at a time. The QSOs of each
+// call sign are taken in date order, the first one stands.
+func ScanDupes(ctx context.Context, store Querier, eventID string, rule DupeRule) ([]Dupe, error) {
+	if rule.Action == DupeAllow || rule.Action == "" {
+		rule.Action = DupeFlag
+	}
+
+	var dupes []Dupe
+	findOptions := options.Find().
+		SetProjection(bson.M{"call_sign": 1, "attributes": 1}).
+		SetSort(bson.D{{Key: "call_sign", Value: 1}})
+	err := store.QueryEach(ctx, models.CollIdentity, findOptions, bson.M{"event_id": eventID}, func(raw bson.Raw) error {
+		var identity struct {
+			CallSign   string   `bson:"call_sign"`
+			Attributes []bson.M `bson:"attributes"`
+		}
+		if err := bson.Unmarshal(raw, &identity); err != nil {
+			return wrapError("ScanDupes", err)
+		}
+
+		contacts := make([]dupeContact, len(identity.Attributes))
+		for i, attribute := range identity.Attributes {
+			contacts[i] = attributeContact(attribute)
+		}
+		sort.SliceStable(contacts, func(i, j int) bool {
+			return contacts[i].date.Before(contacts[j].date)
+		})
+
+		var worked []dupeContact
+		for _, contact := range contacts {
+			flagged := contact.flagged
+			contact.flagged = false
+			if first, ok := rule.first(worked, contact); ok {
+				dupes = append(dupes, Dupe{
+					CallSign: identity.CallSign,
+					Band:     contact.band,
+					Mode:     contact.mode,
+					Date:     contact.date,
+					First:    first.date,
+					Flagged:  flagged,
+				})
+				continue
+			}
+			worked = append(worked, contact)
+		}
+		return nil
+	})
+	return dupes, err
+}
@@ -0,0 +1,121 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/agustadewa/gomongo/bandplan"
+)
+
+// dupeDate is the date of the first QSO of the dupe tests
+var dupeDate = time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+
+// dupeQSOs are 2 SSB QSOs and a CW QSO of YB0AAA on 40m
+var dupeQSOs = []QSO{
+	{CallSign: "YB0AAA", Frequency: "7.135", Mode: "SSB", Date: dupeDate},
+	{CallSign: "YB0AAA", Frequency: "7.140", Mode: "USB", Date: dupeDate.Add(time.Hour)},
+	{CallSign: "YB0AAA", Frequency: "7.020", Mode: "CW", Date: dupeDate.Add(time.Hour)},
+}
+
+func TestImportQSOsDupes(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		rule     *DupeRule
+		imported int
+		dupes    int
+		errors   int
+	}{
+		{"without a checker", nil, 3, 0, 0},
+		{"flagged by mode", &DupeRule{ByMode: true, Action: DupeFlag}, 3, 1, 0},
+		{"flagged by band", &DupeRule{Action: DupeFlag}, 3, 2, 0},
+		{"rejected by mode", &DupeRule{ByMode: true, Action: DupeReject}, 2, 1, 1},
+		{"merged by mode", &DupeRule{ByMode: true, Action: DupeMerge}, 2, 1, 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			store := NewMemoryAdaptor()
+			if c.rule != nil {
+				store.SetDupeChecker(NewDupeChecker(*c.rule))
+			}
+			result, err := ImportQSOs(context.Background(), store, "event", dupeQSOs, ImportOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Imported != c.imported || result.Dupes != c.dupes || len(result.Errors) != c.errors {
+				t.Fatalf("got %+v, want %d imported, %d dupes and %d errors", result, c.imported, c.dupes, c.errors)
+			}
+		})
+	}
+}
+
+func TestScanDupes(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+	store.SetDupeChecker(NewDupeChecker(DupeRule{ByMode: true, Action: DupeFlag}))
+	importQSOs(t, store, "event", dupeQSOs...)
+
+	for _, c := range []struct {
+		name  string
+		rule  DupeRule
+		dupes int
+	}{
+		{"by mode", DupeRule{ByMode: true}, 1},
+		{"on 40m whatever the mode", DupeRule{}, 2},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dupes, err := ScanDupes(ctx, store, "event", c.rule)
+			if err != nil || len(dupes) != c.dupes {
+				t.Fatalf("got %+v (%v), want %d dupes", dupes, err, c.dupes)
+			}
+			// the SSB QSO flagged by the import
+			if got := dupes[0]; !got.Flagged || got.Band != "40m" || !got.First.Equal(dupeDate) {
+				t.Fatalf("got %+v, want the flagged 40m dupe of the first QSO", got)
+			}
+		})
+	}
+}
+
+func TestRecordQSODupe(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+	importQSOs(t, store, "event", dupeQSOs...)
+	store.SetDupeChecker(NewDupeChecker(DupeRule{Window: 90 * time.Minute, Action: DupeReject}))
+
+	for _, c := range []struct {
+		name string
+		qso  QSO
+		err  error
+	}{
+		{"inside the window", QSO{CallSign: "YB0AAA/P", Frequency: "7.1", Mode: "FM", Date: dupeDate.Add(time.Hour)}, ErrDupe},
+		{"outside the window", QSO{CallSign: "YB0AAA", Frequency: "7.1", Mode: "FM", Date: dupeDate.Add(3 * time.Hour)}, nil},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := RecordQSO(ctx, store, "event", c.qso, bandplan.AnyRegion); !errors.Is(err, c.err) {
+				t.Fatalf("got %v, want %v", err, c.err)
+			}
+		})
+	}
+}
+
+func TestImportQSOsDupesDateOrder(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+	store.SetDupeChecker(NewDupeChecker(DupeRule{Window: 90 * time.Minute, Action: DupeFlag}))
+
+	// logged out of order, the 13:00 QSO is the dupe of the 12:00 one
+	date := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	qsos := []QSO{
+		{CallSign: "YB0AAA", Frequency: "7.135", Mode: "SSB", Date: date.Add(time.Hour)},
+		{CallSign: "YB0AAA", Frequency: "7.135", Mode: "SSB", Date: date.Add(2 * time.Hour)},
+		{CallSign: "YB0AAA", Frequency: "7.135", Mode: "SSB", Date: date},
+	}
+	result, err := ImportQSOs(ctx, store, "event", qsos, ImportOptions{})
+	if err != nil || result.Imported != 3 || result.Dupes != 1 {
+		t.Fatalf("got %+v (%v), want 1 dupe of 3", result, err)
+	}
+	dupes, err := ScanDupes(ctx, store, "event", DupeRule{Window: 90 * time.Minute})
+	if err != nil || len(dupes) != 1 || !dupes[0].Flagged || !dupes[0].Date.Equal(date.Add(time.Hour)) {
+		t.Fatalf("got %+v (%v), want the flagged 13:00 QSO, as the import did", dupes, err)
+	}
+}
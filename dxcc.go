@@ -0,0 +1,84 @@
+package gomongo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/agustadewa/gomongo/callsign"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// EntityCount type is one row of the DXCC entity report, the call signs
+// found in no entity are counted under an empty Entity
+type EntityCount struct {
+	Entity    string `json:"entity" bson:"entity"`
+	Prefix    string `json:"prefix" bson:"prefix"`
+	Continent string `json:"continent" bson:"continent"`
+	CallSigns int    `json:"call_signs" bson:"call_signs"`
+	QSOs      int    `json:"qsos" bson:"qsos"`
+}
+
+// callSignQSOs is one row of the QSOs per call sign aggregation, the
+// station and the call it was logged as
+type callSignQSOs struct {
+	ID struct {
+		CallSign   string `bson:"call_sign"`
+		LoggedCall string `bson:"logged_call"`
+	} `bson:"_id"`
+	QSOs int `bson:"qsos"`
+}
+
+// EntityReport method counts the stations and QSOs of eventID per DXCC
+// entity of the callsign.Default database
+func (adaptor *Adaptor) EntityReport(ctx context.Context, eventID string) ([]EntityCount, error) {
+	return EntityReport(ctx, adaptor, eventID, callsign.Default)
+}
+
+// EntityReport function counts the stations and QSOs of eventID per DXCC
+// entity of db, the most worked first. The entity of a QSO is the one of the
+// call sign it was logged as, VK2/YB0AAA is in Australia, a station worked
+// from several entities counts in each. The QSOs are counted per call sign by
+// the store, the entities are resolved here, the country file cannot be
+// expressed as an aggregation.
+func EntityReport(ctx context.Context, store Querier, eventID string, db *callsign.DB) ([]EntityCount, error) {
+	pipeline := eventQSOPipeline(eventID, "").
+		Match(bson.M{"date": bson.M{"$exists": true}}).
+		Group(bson.M{
+			"call_sign":   "$call_sign",
+			"logged_call": bson.M{"$ifNull": bson.A{"$logged_call", "$call_sign"}},
+		}, bson.D{{Key: "qsos", Value: Sum(1)}})
+
+	rows, err := Aggregate[callSignQSOs](ctx, store, models.CollIdentity, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	byEntity := map[string]*EntityCount{}
+	stations := map[string]bool{}
+	for _, row := range rows {
+		entity, _ := db.Lookup(row.ID.LoggedCall)
+		count, ok := byEntity[entity.Name]
+		if !ok {
+			count = &EntityCount{Entity: entity.Name, Prefix: entity.Prefix, Continent: entity.Continent}
+			byEntity[entity.Name] = count
+		}
+		if key := entity.Name + "|" + row.ID.CallSign; !stations[key] {
+			stations[key] = true
+			count.CallSigns++
+		}
+		count.QSOs += row.QSOs
+	}
+
+	report := make([]EntityCount, 0, len(byEntity))
+	for _, count := range byEntity {
+		report = append(report, *count)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].QSOs != report[j].QSOs {
+			return report[i].QSOs > report[j].QSOs
+		}
+		return report[i].Entity < report[j].Entity
+	})
+	return report, nil
+}
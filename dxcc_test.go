@@ -0,0 +1,170 @@
+package gomongo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agustadewa/gomongo/bandplan"
+	"github.com/agustadewa/gomongo/cabrillo"
+	"github.com/agustadewa/gomongo/callsign"
+	"github.com/agustadewa/gomongo/tools"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestEntityReport(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	date := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	qsos := []QSO{
+		{CallSign: "yb0aaa/p", Frequency: "7.135", Mode: "SSB", Date: date},
+		{CallSign: "VK2/YB0AAA", Frequency: "14.2", Mode: "SSB", Date: date},
+		{CallSign: "W1AW", Frequency: "14.2", Mode: "CW", Date: date},
+		{CallSign: "YB0/", Frequency: "14.2", Mode: "CW", Date: date},
+	}
+	result, err := ImportQSOs(ctx, store, "event", qsos, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Imported != 3 || result.NewIdentities != 2 {
+		t.Errorf("got %+v, want 3 QSOs of YB0AAA and W1AW", result)
+	}
+	if len(result.Errors) != 1 || !errors.Is(result.Errors[0], callsign.ErrInvalid) {
+		t.Errorf("got %v, want YB0/ rejected", result.Errors)
+	}
+
+	var identity bson.M
+	if err := store.QueryFindV2(ctx, models.CollIdentity, nil, bson.M{"call_sign": "YB0AAA"}, &identity); err != nil {
+		t.Fatal(err)
+	}
+	attributes, _ := identity["attributes"].(bson.A)
+	if len(attributes) != 2 || attributes[1].(bson.M)["logged_call"] != "VK2/YB0AAA" {
+		t.Fatalf("got %v, want the call as logged kept", attributes)
+	}
+
+	// a legacy identity without logged_call is looked up by its call sign
+	legacy := bson.M{"event_id": "event", "call_sign": "JA1ABC", "attributes": bson.A{bson.M{"frequency": "7.135", "date": millisString(date)}}}
+	if _, err := store.QueryInsertV3(ctx, models.CollIdentity, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := EntityReport(ctx, store, "event", callsign.Default)
+	if err != nil || len(report) != 4 {
+		t.Fatalf("got %+v (%v), want 4 entities", report, err)
+	}
+	byEntity := map[string]EntityCount{}
+	for _, count := range report {
+		byEntity[count.Prefix] = count
+	}
+	for _, c := range []struct {
+		prefix          string
+		qsos, callSigns int
+	}{
+		{"YB", 1, 1},
+		// the VK2/YB0AAA QSO is in Australia
+		{"VK", 1, 1},
+		{"K", 1, 1},
+		{"JA", 1, 1},
+	} {
+		if got := byEntity[c.prefix]; got.QSOs != c.qsos || got.CallSigns != c.callSigns {
+			t.Errorf("%s: got %+v, want %d QSOs of %d call signs", c.prefix, got, c.qsos, c.callSigns)
+		}
+	}
+}
+
+func TestInsertIdentity(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+	store.SetDupeChecker(NewDupeChecker(DupeRule{Action: DupeReject}))
+
+	date := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	if _, err := ImportQSOs(ctx, store, "event", []QSO{{CallSign: "YB0AAA", Frequency: "7.135", Mode: "SSB", Date: date}}, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	identity := func(frequency string) models.Identity {
+		return models.Identity{EventID: "event", CallSign: "vk2/yb0aaa", Attributes: []models.IdentityAttribute{{Frequency: frequency, Mode: "SSB", Date: millisString(date.Add(time.Hour))}}}
+	}
+
+	if _, err := InsertIdentity(ctx, store, identity("14.2"), bandplan.AnyRegion); err != nil {
+		t.Fatal(err)
+	}
+	var identities []models.Identity
+	if err := store.QueryFindManyV2(ctx, models.CollIdentity, nil, bson.M{"event_id": "event"}, &identities); err != nil || len(identities) != 1 || len(identities[0].Attributes) != 2 {
+		t.Fatalf("got %+v (%v), want the 20m QSO added to the YB0AAA identity", identities, err)
+	}
+	if n, err := store.QueryCount(ctx, models.CollIdentity, bson.M{"attributes.logged_call": "VK2/YB0AAA", "attributes.band": "20m"}); err != nil || n != 1 {
+		t.Fatalf("got %d (%v), want the 20m QSO logged as VK2/YB0AAA", n, err)
+	}
+
+	if _, err := InsertIdentity(ctx, store, identity("7.1"), bandplan.AnyRegion); !errors.Is(err, ErrDupe) {
+		t.Fatalf("got %v, want the second 40m QSO rejected as a dupe", err)
+	}
+	if _, err := InsertIdentity(ctx, store, models.Identity{EventID: "event", CallSign: "YB0AAA"}, bandplan.AnyRegion); !errors.Is(err, ErrValidation) {
+		t.Fatalf("got %v, want ErrValidation without attributes", err)
+	}
+}
+
+func TestExportLoggedCall(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	eventID := primitive.NewObjectID()
+	if _, err := store.QueryInsertV3(ctx, models.CollEvent, bson.M{"_id": eventID, "name": "Field Day"}); err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	if _, err := ImportQSOs(ctx, store, eventID.Hex(), []QSO{{CallSign: "VK2/YB0AAA", Frequency: "7.135", Mode: "SSB", Date: date}}, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// a legacy identity without logged_call is exported by its call sign
+	legacy := bson.M{"event_id": eventID.Hex(), "call_sign": "JA1ABC", "attributes": bson.A{bson.M{"frequency": "7.135", "band": "40m", "mode": "SSB", "date": millisString(date.Add(time.Minute))}}}
+	if _, err := store.QueryInsertV3(ctx, models.CollIdentity, legacy); err != nil {
+		t.Fatal(err)
+	}
+	template := writeTemplate(t)
+
+	for _, c := range []struct {
+		name   string
+		export func(w io.Writer) error
+		want   []string
+	}{
+		{"ADIF", func(w io.Writer) error {
+			_, err := ExportADIF(ctx, store, eventID.Hex(), ExportFilter{}, w)
+			return err
+		}, []string{"<CALL:10>VK2/YB0AAA", "<CALL:6>JA1ABC"}},
+		{"Cabrillo", func(w io.Writer) error {
+			_, err := ExportCabrillo(ctx, store, eventID.Hex(), []cabrillo.Tag{{Name: "CALLSIGN", Value: "YB0XYZ"}}, ExportFilter{}, w)
+			return err
+		}, []string{"VK2/YB0AAA", "JA1ABC"}},
+		{"BatchManifest", func(w io.Writer) error {
+			opt := BatchOptions{
+				Format:       BatchPDF,
+				Layout:       tools.CertLayout{Name: "batch", Fields: []tools.CertField{{Source: tools.FieldCallSign, FontName: "Helvetica", FontSize: 20}}},
+				TemplatePath: template,
+				FileType:     "PNG",
+				Manifest:     w,
+			}
+			_, err := RenderBatch(ctx, store, eventID.Hex(), io.Discard, opt)
+			return err
+		}, []string{",VK2/YB0AAA,", ",JA1ABC,"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := c.export(&out); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range c.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("got %s, want %s", out.String(), want)
+				}
+			}
+		})
+	}
+}
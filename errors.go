@@ -0,0 +1,95 @@
+package gomongo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Error kinds returned by the Adaptor, check them with errors.Is
+var (
+	ErrNotFound     = errors.New("document not found")
+	ErrDuplicateKey = errors.New("duplicate key")
+	ErrInvalidID    = errors.New("invalid id")
+	ErrTimeout      = errors.New("operation timed out")
+	ErrValidation   = errors.New("validation failed")
+	ErrDupe         = errors.New("duplicate QSO")
+)
+
+// Error type wraps the driver error of a failed Adaptor operation.
+// errors.Is matches both the Kind and the wrapped driver error.
+type Error struct {
+	Op   string
+	Kind error
+	Err  error
+}
+
+// Error method
+func (e *Error) Error() string {
+	if e.Kind == nil {
+		return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	}
+	if e.Err == nil {
+		return fmt.Sprintf("%s: %v", e.Op, e.Kind)
+	}
+	return fmt.Sprintf("%s: %v: %v", e.Op, e.Kind, e.Err)
+}
+
+// Unwrap method
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is method
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// wrapError classifies err and wraps it in *Error, nil stays nil
+func wrapError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var adaptorErr *Error
+	if errors.As(err, &adaptorErr) {
+		return err
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	var kind error
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		kind = ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		kind = ErrDuplicateKey
+	case mongo.IsTimeout(err), errors.Is(err, context.DeadlineExceeded):
+		kind = ErrTimeout
+	case errors.Is(err, primitive.ErrInvalidHex):
+		kind = ErrInvalidID
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		kind = ErrValidation
+	}
+
+	return &Error{Op: op, Kind: kind, Err: err}
+}
+
+// validationError returns an ErrValidation error with a message
+func validationError(op, format string, args ...interface{}) error {
+	return &Error{Op: op, Kind: ErrValidation, Err: fmt.Errorf(format, args...)}
+}
+
+// objectID parses a hex id, failing with ErrInvalidID
+func objectID(op, id string) (primitive.ObjectID, error) {
+	OID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return OID, &Error{Op: op, Kind: ErrInvalidID, Err: err}
+	}
+	return OID, nil
+}
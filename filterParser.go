@@ -0,0 +1,397 @@
+package gomongo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultFilterOperators are the operators a client filter may use when the
+// policy does not list its own. $where, $function, $expr and friends are left
+// out on purpose, they run code or scan without indexes.
+var DefaultFilterOperators = []string{
+	"$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$in", "$nin",
+	"$exists", "$not", "$elemMatch", "$size", "$regex", "$options",
+	"$and", "$or", "$nor",
+}
+
+// defaultFilterMaxDepth bounds the nesting of $and/$or/$elemMatch
+const defaultFilterMaxDepth = 8
+
+// FilterPolicy type, what a client filter on one collection may contain.
+// Fields lists the allowed field paths, a path also allows everything below
+// it and array indexes are ignored, so "attributes" allows
+// "attributes.0.frequency". A nil Fields allows any field.
+type FilterPolicy struct {
+	Operators []string
+	Fields    []string
+	MaxDepth  int
+}
+
+// FilterError type points at the part of the filter that was rejected
+type FilterError struct {
+	Path   string
+	Reason string
+}
+
+// Error method
+func (e *FilterError) Error() string {
+	if e.Path == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Reason)
+}
+
+// FilterParser type turns the JSON filter sent by an API client into a bson.M
+// that is safe to pass to Find.
+//
+// The syntax is MongoDB's query syntax in extended JSON, e.g.
+//
+//	{"call_sign": "YB0AAA", "attributes": {"$elemMatch": {"frequency": "7.100"}}}
+//	{"_id": {"$oid": "5f1d7f3e2b1c4a0001a1b2c3"}}
+//	{"$or": [{"mode": "SSB"}, {"date": {"$gte": {"$date": "2026-01-01T00:00:00Z"}}}]}
+type FilterParser struct {
+	fallback FilterPolicy
+	policies map[string]FilterPolicy
+}
+
+// NewFilterParser function, fallback applies to collections without their own policy
+func NewFilterParser(fallback FilterPolicy) *FilterParser {
+	return &FilterParser{fallback: fallback, policies: map[string]FilterPolicy{}}
+}
+
+// defaultFilterParser is used by adaptors without a parser of their own
+var defaultFilterParser = NewFilterParser(FilterPolicy{})
+
+// Allow method sets the policy of collName
+func (parser *FilterParser) Allow(collName string, policy FilterPolicy) *FilterParser {
+	parser.policies[collName] = policy
+	return parser
+}
+
+// Policy method returns the policy applied to collName
+func (parser *FilterParser) Policy(collName string) FilterPolicy {
+	if policy, ok := parser.policies[collName]; ok {
+		return policy
+	}
+	return parser.fallback
+}
+
+// Parse method decodes data and checks it against the policy of collName,
+// errors are ErrValidation wrapping a *FilterError
+func (parser *FilterParser) Parse(collName string, data []byte) (bson.M, error) {
+	filter := bson.M{}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return filter, nil
+	}
+
+	if err := bson.UnmarshalExtJSON(data, false, &filter); err != nil {
+		return nil, &Error{Op: "ParseFilter", Kind: ErrValidation, Err: &FilterError{Reason: err.Error()}}
+	}
+
+	if err := parser.Check(collName, filter); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// Check method checks an already decoded filter against the policy of collName
+func (parser *FilterParser) Check(collName string, filter bson.M) error {
+	policy := parser.Policy(collName)
+	operators := policy.Operators
+	if operators == nil {
+		operators = DefaultFilterOperators
+	}
+
+	checker := filterChecker{
+		operators: map[string]bool{},
+		fields:    policy.Fields,
+		maxDepth:  policy.MaxDepth,
+	}
+	for _, operator := range operators {
+		checker.operators[operator] = true
+	}
+	if checker.maxDepth == 0 {
+		checker.maxDepth = defaultFilterMaxDepth
+	}
+
+	if err := checker.document("", filter, 0); err != nil {
+		return &Error{Op: "ParseFilter", Kind: ErrValidation, Err: err}
+	}
+	return nil
+}
+
+// filterChecker walks a decoded filter
+type filterChecker struct {
+	operators map[string]bool
+	fields    []string
+	maxDepth  int
+}
+
+// filterPath appends key to path
+func filterPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// document checks a filter document whose fields are relative to prefix
+func (checker filterChecker) document(prefix string, value interface{}, depth int) error {
+	if depth > checker.maxDepth {
+		return &FilterError{Path: prefix, Reason: fmt.Sprintf("nested deeper than %d levels", checker.maxDepth)}
+	}
+
+	doc, ok := filterDocument(value)
+	if !ok {
+		return &FilterError{Path: prefix, Reason: "expected an object"}
+	}
+
+	for _, elem := range doc {
+		if !strings.HasPrefix(elem.Key, "$") {
+			if err := checker.field(filterPath(prefix, elem.Key), elem.Value, depth); err != nil {
+				return err
+			}
+			continue
+		}
+
+		path := filterPath(prefix, elem.Key)
+		if err := checker.operator(path, elem.Key); err != nil {
+			return err
+		}
+		switch elem.Key {
+		case "$and", "$or", "$nor":
+			clauses, ok := elem.Value.(primitive.A)
+			if !ok || len(clauses) == 0 {
+				return &FilterError{Path: path, Reason: "expected a non empty array"}
+			}
+			for i, clause := range clauses {
+				if err := checker.document(prefix, clause, depth+1); err != nil {
+					if filterErr, ok := err.(*FilterError); ok && filterErr.Path == prefix {
+						filterErr.Path = filterPath(path, strconv.Itoa(i))
+					}
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// field checks the condition on one field path
+func (checker filterChecker) field(path string, value interface{}, depth int) error {
+	if depth > checker.maxDepth {
+		return &FilterError{Path: path, Reason: fmt.Sprintf("nested deeper than %d levels", checker.maxDepth)}
+	}
+	if err := checker.allowedField(path); err != nil {
+		return err
+	}
+
+	doc, ok := filterDocument(value)
+	if !ok {
+		if _, isRegex := value.(primitive.Regex); isRegex {
+			return checker.operator(path, "$regex")
+		}
+		return nil
+	}
+	if !isOperatorDocument(doc) {
+		// equality with an embedded document, no operator may hide inside it
+		return checker.literal(path, doc)
+	}
+
+	for _, elem := range doc {
+		opPath := filterPath(path, elem.Key)
+		if !strings.HasPrefix(elem.Key, "$") {
+			return &FilterError{Path: opPath, Reason: "cannot mix operators and fields"}
+		}
+		if err := checker.operator(opPath, elem.Key); err != nil {
+			return err
+		}
+
+		switch elem.Key {
+		case "$in", "$nin":
+			values, ok := elem.Value.(primitive.A)
+			if !ok {
+				return &FilterError{Path: opPath, Reason: "expected an array"}
+			}
+			for i, item := range values {
+				if err := checker.literal(filterPath(opPath, strconv.Itoa(i)), item); err != nil {
+					return err
+				}
+			}
+		case "$exists":
+			if _, ok := elem.Value.(bool); !ok {
+				return &FilterError{Path: opPath, Reason: "expected a boolean"}
+			}
+		case "$size":
+			if _, ok := toInt64(elem.Value); !ok {
+				return &FilterError{Path: opPath, Reason: "expected a number"}
+			}
+		case "$regex":
+			switch elem.Value.(type) {
+			case string, primitive.Regex:
+			default:
+				return &FilterError{Path: opPath, Reason: "expected a string"}
+			}
+		case "$options":
+			if _, ok := elem.Value.(string); !ok {
+				return &FilterError{Path: opPath, Reason: "expected a string"}
+			}
+			if !hasKey(doc, "$regex") {
+				return &FilterError{Path: opPath, Reason: "$options needs $regex"}
+			}
+		case "$not":
+			if _, isRegex := elem.Value.(primitive.Regex); isRegex {
+				continue
+			}
+			notDoc, ok := filterDocument(elem.Value)
+			if !ok || !isOperatorDocument(notDoc) {
+				return &FilterError{Path: opPath, Reason: "expected an operator object or a regular expression"}
+			}
+			if err := checker.field(path, elem.Value, depth+1); err != nil {
+				return err
+			}
+		case "$elemMatch":
+			elemDoc, ok := filterDocument(elem.Value)
+			if !ok {
+				return &FilterError{Path: opPath, Reason: "expected an object"}
+			}
+			var err error
+			if isOperatorDocument(elemDoc) {
+				err = checker.field(path, elem.Value, depth+1)
+			} else {
+				err = checker.document(path, elem.Value, depth+1)
+			}
+			if err != nil {
+				return err
+			}
+		case "$and", "$or", "$nor":
+			return &FilterError{Path: opPath, Reason: "operator is only allowed at the top level"}
+		default:
+			if err := checker.literal(opPath, elem.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// literal rejects operators inside a value compared as is
+func (checker filterChecker) literal(path string, value interface{}) error {
+	switch typed := value.(type) {
+	case bson.M, bson.D:
+		doc, _ := filterDocument(typed)
+		for _, elem := range doc {
+			if strings.HasPrefix(elem.Key, "$") {
+				return &FilterError{Path: filterPath(path, elem.Key), Reason: "operator is not allowed inside a value"}
+			}
+			if err := checker.literal(filterPath(path, elem.Key), elem.Value); err != nil {
+				return err
+			}
+		}
+	case primitive.A:
+		for i, item := range typed {
+			if err := checker.literal(filterPath(path, strconv.Itoa(i)), item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// operator checks operator is whitelisted
+func (checker filterChecker) operator(path, operator string) error {
+	if !checker.operators[operator] {
+		return &FilterError{Path: path, Reason: fmt.Sprintf("operator %s is not allowed", operator)}
+	}
+	return nil
+}
+
+// allowedField checks path is in the policy fields
+func (checker filterChecker) allowedField(path string) error {
+	if strings.Contains(path, "$") {
+		return &FilterError{Path: path, Reason: "field names cannot contain $"}
+	}
+	if checker.fields == nil {
+		return nil
+	}
+
+	segments := make([]string, 0)
+	for _, segment := range strings.Split(path, ".") {
+		if _, err := strconv.Atoi(segment); err != nil {
+			segments = append(segments, segment)
+		}
+	}
+	plain := strings.Join(segments, ".")
+
+	for _, field := range checker.fields {
+		if plain == field || strings.HasPrefix(plain, field+".") {
+			return nil
+		}
+	}
+	return &FilterError{Path: path, Reason: "field is not allowed"}
+}
+
+// filterDocument returns value as an ordered document
+func filterDocument(value interface{}) (bson.D, bool) {
+	switch typed := value.(type) {
+	case bson.D:
+		return typed, true
+	case bson.M:
+		doc := make(bson.D, 0, len(typed))
+		for key, val := range typed {
+			doc = append(doc, bson.E{Key: key, Value: val})
+		}
+		return doc, true
+	}
+	return nil, false
+}
+
+// hasKey reports whether doc has key
+func hasKey(doc bson.D, key string) bool {
+	for _, elem := range doc {
+		if elem.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// isOperatorDocument reports whether any key of doc is an operator
+func isOperatorDocument(doc bson.D) bool {
+	for _, elem := range doc {
+		if strings.HasPrefix(elem.Key, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// SetFilterParser method sets the parser QueryFind and QueryFindMany check client filters with
+func (adaptor *Adaptor) SetFilterParser(parser *FilterParser) {
+	adaptor.filters = parser
+}
+
+// filterParser returns the parser of the adaptor or the default one
+func (adaptor *Adaptor) filterParser() *FilterParser {
+	if adaptor.filters == nil {
+		return defaultFilterParser
+	}
+	return adaptor.filters
+}
+
+// SetFilterParser method sets the parser QueryFind and QueryFindMany check client filters with
+func (m *MemoryAdaptor) SetFilterParser(parser *FilterParser) {
+	m.filters = parser
+}
+
+// filterParser returns the parser of the adaptor or the default one
+func (m *MemoryAdaptor) filterParser() *FilterParser {
+	if m.filters == nil {
+		return defaultFilterParser
+	}
+	return m.filters
+}
@@ -0,0 +1,32 @@
+package gomongo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFilterParserMaxDepth(t *testing.T) {
+	parser := NewFilterParser(FilterPolicy{MaxDepth: 2})
+	for _, c := range []struct {
+		name   string
+		filter string
+		ok     bool
+	}{
+		{"$not at the limit", `{"date": {"$not": {"$not": {"$gt": 1}}}}`, true},
+		{"$not past the limit", `{"date": {"$not": {"$not": {"$not": {"$gt": 1}}}}}`, false},
+		{"$elemMatch at the limit", `{"attributes": {"$elemMatch": {"$elemMatch": {"$gt": 1}}}}`, true},
+		{"$elemMatch past the limit", `{"attributes": {"$elemMatch": {"$elemMatch": {"$elemMatch": {"$gt": 1}}}}}`, false},
+		{"$or past the limit", `{"$or": [{"$or": [{"$or": [{"mode": "SSB"}]}]}]}`, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := parser.Parse("identity", []byte(c.filter))
+			var filterErr *FilterError
+			if c.ok && err != nil {
+				t.Fatalf("got %v, want the filter allowed", err)
+			}
+			if !c.ok && (!errors.Is(err, ErrValidation) || !errors.As(err, &filterErr)) {
+				t.Fatalf("got %v, want a FilterError", err)
+			}
+		})
+	}
+}
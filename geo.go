@@ -0,0 +1,318 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/agustadewa/gomongo/maidenhead"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollEventStation holds the station running each event, keyed by event id
+const CollEventStation = "event_station"
+
+// GeoIndexKey is the path of the GeoJSON point of the attributes, see
+// Adaptor.EnsureGeoIndex
+const GeoIndexKey = "attributes.location"
+
+// geoIndexName is the name of the 2dsphere index on GeoIndexKey
+const geoIndexName = "attributes_location_2dsphere"
+
+// EventStation type is the station running an event, the distances and
+// bearings of the contacts are measured from the center of its grid
+type EventStation struct {
+	EventID  string `json:"event_id" bson:"_id"`
+	CallSign string `json:"call_sign" bson:"call_sign"`
+	Grid     string `json:"grid" bson:"grid"`
+}
+
+// geoJSONPoint returns p as a GeoJSON point, longitude first
+func geoJSONPoint(p maidenhead.Point) bson.M {
+	return bson.M{"type": "Point", "coordinates": bson.A{p.Longitude, p.Latitude}}
+}
+
+// gridLocation returns grid normalized and the GeoJSON point of its center
+func gridLocation(grid string) (string, bson.M, error) {
+	grid, err := maidenhead.Normalize(grid)
+	if err != nil {
+		return "", nil, err
+	}
+	center, err := maidenhead.ToPoint(grid)
+	if err != nil {
+		return "", nil, err
+	}
+	return grid, geoJSONPoint(center), nil
+}
+
+// SaveEventStation function inserts or replaces the station of station.EventID
+func SaveEventStation(ctx context.Context, store Querier, station EventStation) error {
+	if station.EventID == "" {
+		return validationError("SaveEventStation", "the event id is missing")
+	}
+	grid, err := maidenhead.Normalize(station.Grid)
+	if err != nil {
+		return &Error{Op: "SaveEventStation", Kind: ErrValidation, Err: err}
+	}
+
+	opt := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	update := bson.M{"$set": bson.M{"call_sign": station.CallSign, "grid": grid}}
+	var saved EventStation
+	return store.QueryFindAndUpdateV2(ctx, CollEventStation, opt, bson.M{"_id": station.EventID}, update, &saved)
+}
+
+// LoadEventStation function returns the station of eventID, ErrNotFound when there is none
+func LoadEventStation(ctx context.Context, store Querier, eventID string) (EventStation, error) {
+	var station EventStation
+	err := store.QueryFindV2(ctx, CollEventStation, &options.FindOneOptions{}, bson.M{"_id": eventID}, &station)
+	return station, err
+}
+
+// EnsureGeoIndex method creates the 2dsphere index on GeoIndexKey the geo
+// queries of MongoDB need, creating it again is a no-op. NewAdaptor creates
+// it when given WithGeoIndex.
+func (adaptor *Adaptor) EnsureGeoIndex(ctx context.Context) error {
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: GeoIndexKey, Value: "2dsphere"}},
+		Options: options.Index().SetName(geoIndexName),
+	}
+	_, err := adaptor.Client.Database(adaptor.DBName).Collection(models.CollIdentity).Indexes().CreateOne(ctx, index)
+	return wrapError("EnsureGeoIndex", err)
+}
+
+// SetAttributeGrid method, see SetAttributeGrid
+func (adaptor *Adaptor) SetAttributeGrid(ctx context.Context, eventID, callSign string, date time.Time, grid string) error {
+	return SetAttributeGrid(ctx, adaptor, eventID, callSign, date, grid)
+}
+
+// SetAttributeGrid function sets the grid of the QSO of callSign made at
+// date, with its GeoJSON point. It fails with ErrNotFound when there is no
+// such QSO.
+func SetAttributeGrid(ctx context.Context, store Querier, eventID, callSign string, date time.Time, grid string) error {
+	grid, location, err := gridLocation(grid)
+	if err != nil {
+		return &Error{Op: "SetAttributeGrid", Kind: ErrValidation, Err: err}
+	}
+
+	filter := bson.M{
+		"event_id":   eventID,
+		"call_sign":  callSign,
+		"attributes": bson.M{"$elemMatch": bson.M{"date": millisString(date)}},
+	}
+	update := bson.M{"$set": bson.M{"attributes.$.grid": grid, "attributes.$.location": location}}
+	result := mongo.UpdateResult{}
+	if err := store.QueryUpdateOne(ctx, models.CollIdentity, nil, filter, update, &result); err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return &Error{Op: "SetAttributeGrid", Kind: ErrNotFound}
+	}
+	return nil
+}
+
+// GridContact type is a QSO with a grid, Distance in km and Bearing in
+// degrees are measured from the reference point of the query
+type GridContact struct {
+	CallSign string           `json:"call_sign"`
+	Name     string           `json:"name"`
+	Grid     string           `json:"grid"`
+	Band     string           `json:"band"`
+	Mode     string           `json:"mode"`
+	Date     time.Time        `json:"date"`
+	Point    maidenhead.Point `json:"point"`
+	Distance float64          `json:"distance"`
+	Bearing  float64          `json:"bearing"`
+}
+
+// gridQSO is a QSO with a grid as stored in the attributes
+type gridQSO struct {
+	CallSign string `bson:"call_sign"`
+	Name     string `bson:"name"`
+	Grid     string `bson:"grid"`
+	Band     string `bson:"band"`
+	Mode     string `bson:"mode"`
+	Date     string `bson:"date"`
+}
+
+// contact returns the contact of the QSO measured from from, nil leaves
+// the distance and bearing at 0
+func (qso gridQSO) contact(from *maidenhead.Point) (GridContact, bool) {
+	point, err := maidenhead.ToPoint(qso.Grid)
+	if err != nil {
+		return GridContact{}, false
+	}
+	millis, _ := strconv.ParseInt(qso.Date, 10, 64)
+
+	contact := GridContact{
+		CallSign: qso.CallSign,
+		Name:     qso.Name,
+		Grid:     qso.Grid,
+		Band:     qso.Band,
+		Mode:     qso.Mode,
+		Date:     timeFromMillis(millis),
+		Point:    point,
+	}
+	if from != nil {
+		contact.Distance = maidenhead.Distance(*from, point)
+		contact.Bearing = maidenhead.Bearing(*from, point)
+	}
+	return contact, true
+}
+
+// stationPoint returns the center of the grid of the station of eventID,
+// nil when the event has no station
+func stationPoint(ctx context.Context, store Querier, eventID string) (*maidenhead.Point, error) {
+	station, err := LoadEventStation(ctx, store, eventID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	point, err := maidenhead.ToPoint(station.Grid)
+	if err != nil {
+		return nil, &Error{Op: "stationPoint", Kind: ErrValidation, Err: err}
+	}
+	return &point, nil
+}
+
+// gridContacts runs pipeline over the QSOs and measures them from from
+func gridContacts(ctx context.Context, store Querier, op string, pipeline Pipeline, from *maidenhead.Point) ([]GridContact, error) {
+	var contacts []GridContact
+	err := store.QueryAggregateEach(ctx, models.CollIdentity, options.Aggregate().SetAllowDiskUse(true), pipeline.Stages(), func(raw bson.Raw) error {
+		var qso gridQSO
+		if err := bson.Unmarshal(raw, &qso); err != nil {
+			return wrapError(op, err)
+		}
+		if contact, ok := qso.contact(from); ok {
+			contacts = append(contacts, contact)
+		}
+		return nil
+	})
+	return contacts, err
+}
+
+// GridReport method, see GridReport
+func (adaptor *Adaptor) GridReport(ctx context.Context, eventID string) ([]GridContact, error) {
+	return GridReport(ctx, adaptor, eventID)
+}
+
+// GridReport function returns the QSOs of eventID with a grid, measured
+// from the event station, the farthest first. Without an event station the
+// distances are 0 and the QSOs are in call sign order.
+func GridReport(ctx context.Context, store Querier, eventID string) ([]GridContact, error) {
+	from, err := stationPoint(ctx, store, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := eventQSOPipeline(eventID, "").
+		Match(bson.M{"grid": bson.M{"$exists": true}, "dupe": bson.M{"$ne": true}}).
+		Sort(SortKey{Key: "call_sign", Order: 1}, SortKey{Key: "date", Order: 1})
+	contacts, err := gridContacts(ctx, store, "GridReport", pipeline, from)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(contacts, func(i, j int) bool {
+		return contacts[i].Distance > contacts[j].Distance
+	})
+	return contacts, nil
+}
+
+// NearContacts method, see NearContacts
+func (adaptor *Adaptor) NearContacts(ctx context.Context, eventID string, center maidenhead.Point, maxKm float64) ([]GridContact, error) {
+	return NearContacts(ctx, adaptor, eventID, center, maxKm)
+}
+
+// NearContacts function returns the QSOs of eventID whose grid center is
+// at most maxKm from center, measured from center, the nearest first. The
+// identities are found with $near, MongoDB needs the index of EnsureGeoIndex.
+func NearContacts(ctx context.Context, store Querier, eventID string, center maidenhead.Point, maxKm float64) ([]GridContact, error) {
+	if !center.Valid() || maxKm <= 0 {
+		return nil, validationError("NearContacts", "bad center %s or distance %g", center, maxKm)
+	}
+
+	filter := bson.M{
+		"event_id": eventID,
+		GeoIndexKey: bson.M{"$near": bson.M{
+			"$geometry":    geoJSONPoint(center),
+			"$maxDistance": maxKm * 1000,
+		}},
+	}
+	findOptions := options.Find().SetProjection(bson.M{"call_sign": 1, "name": 1, "attributes": 1})
+
+	var contacts []GridContact
+	err := store.QueryEach(ctx, models.CollIdentity, findOptions, filter, func(raw bson.Raw) error {
+		var identity struct {
+			CallSign   string    `bson:"call_sign"`
+			Name       string    `bson:"name"`
+			Attributes []gridQSO `bson:"attributes"`
+		}
+		if err := bson.Unmarshal(raw, &identity); err != nil {
+			return wrapError("NearContacts", err)
+		}
+		for _, qso := range identity.Attributes {
+			qso.CallSign, qso.Name = identity.CallSign, identity.Name
+			if contact, ok := qso.contact(&center); ok && contact.Distance <= maxKm {
+				contacts = append(contacts, contact)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(contacts, func(i, j int) bool {
+		return contacts[i].Distance < contacts[j].Distance
+	})
+	return contacts, nil
+}
+
+// ContactsWithin method, see ContactsWithin
+func (adaptor *Adaptor) ContactsWithin(ctx context.Context, eventID string, polygon []maidenhead.Point) ([]GridContact, error) {
+	return ContactsWithin(ctx, adaptor, eventID, polygon)
+}
+
+// ContactsWithin function returns the QSOs of eventID whose grid center is
+// inside polygon, measured from the event station, in call sign and date
+// order. The polygon needs 3 corners or more, it is closed when its last
+// corner is not its first.
+func ContactsWithin(ctx context.Context, store Querier, eventID string, polygon []maidenhead.Point) ([]GridContact, error) {
+	if len(polygon) > 1 && polygon[0] == polygon[len(polygon)-1] {
+		polygon = polygon[:len(polygon)-1]
+	}
+	if len(polygon) < 3 {
+		return nil, validationError("ContactsWithin", "a polygon needs 3 corners, got %d", len(polygon))
+	}
+
+	ring := bson.A{}
+	for i := 0; i <= len(polygon); i++ {
+		corner := polygon[i%len(polygon)]
+		if !corner.Valid() {
+			return nil, validationError("ContactsWithin", "bad corner %s", corner)
+		}
+		ring = append(ring, bson.A{corner.Longitude, corner.Latitude})
+	}
+
+	from, err := stationPoint(ctx, store, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := eventQSOPipeline(eventID, "").
+		Match(bson.M{
+			"location": bson.M{"$geoWithin": bson.M{
+				"$geometry": bson.M{"type": "Polygon", "coordinates": bson.A{ring}},
+			}},
+			"dupe": bson.M{"$ne": true},
+		}).
+		Sort(SortKey{Key: "call_sign", Order: 1}, SortKey{Key: "date", Order: 1})
+	return gridContacts(ctx, store, "ContactsWithin", pipeline, from)
+}
@@ -0,0 +1,115 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/agustadewa/gomongo/maidenhead"
+)
+
+// geoDate is the date the QSOs of the geo tests are worked at
+var geoDate = time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// gridStore returns a store with the fixture QSOs in grids OI33jt, PM95 and
+// FN31pr, the last one set after the import, and the event station in OI33
+func gridStore(t *testing.T) *MemoryAdaptor {
+	t.Helper()
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	qsos := workedQSOs(geoDate)
+	qsos[0].Grid, qsos[1].Grid = "oi33jt", "PM95"
+	importQSOs(t, store, "event", qsos...)
+	if err := SetAttributeGrid(ctx, store, "event", "W1AW", geoDate, "FN31pr"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveEventStation(ctx, store, EventStation{EventID: "event", CallSign: "YB0AAA", Grid: "OI33"}); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestImportQSOsGrid(t *testing.T) {
+	qsos := append(workedQSOs(geoDate), QSO{CallSign: "VK2AAA", Frequency: "14.2", Mode: "SSB", Date: geoDate, Grid: "ZZ99"})
+	result, err := ImportQSOs(context.Background(), NewMemoryAdaptor(), "event", qsos, ImportOptions{})
+	if err != nil || result.Imported != 3 || len(result.Errors) != 1 || !errors.Is(result.Errors[0], maidenhead.ErrInvalid) {
+		t.Fatalf("got %+v (%v), want 3 imported and the bad grid rejected", result, err)
+	}
+}
+
+func TestSetAttributeGrid(t *testing.T) {
+	store := gridStore(t)
+	for _, c := range []struct {
+		name string
+		date time.Time
+		grid string
+		err  error
+	}{
+		{"stored QSO", geoDate, "FN31", nil},
+		{"unknown QSO", geoDate.Add(time.Hour), "FN31", ErrNotFound},
+		{"bad grid", geoDate, "ZZ99", ErrValidation},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if err := SetAttributeGrid(context.Background(), store, "event", "W1AW", c.date, c.grid); !errors.Is(err, c.err) {
+				t.Fatalf("got %v, want %v", err, c.err)
+			}
+		})
+	}
+}
+
+func TestGridReport(t *testing.T) {
+	report, err := GridReport(context.Background(), gridStore(t), "event")
+	if err != nil || len(report) != 3 {
+		t.Fatalf("got %+v (%v), want 3 contacts", report, err)
+	}
+	// farthest first, from the event station in OI33
+	for i, want := range []struct {
+		callSign                 string
+		minDistance, maxDistance float64
+	}{
+		{"W1AW", 15000, 17000},
+		{"JA1AAA", 5000, 6500},
+		{"YB1BBB", 0, 100},
+	} {
+		if got := report[i]; got.CallSign != want.callSign || got.Distance < want.minDistance || got.Distance > want.maxDistance {
+			t.Errorf("%d: got %s at %.0f km, want %s at %.0f to %.0f km", i, got.CallSign, got.Distance, want.callSign, want.minDistance, want.maxDistance)
+		}
+	}
+	if japan := report[1]; japan.Bearing < 0 || japan.Bearing > 90 {
+		t.Errorf("got %+v, want JA1AAA to the north east", japan)
+	}
+}
+
+func TestContactsNear(t *testing.T) {
+	store := gridStore(t)
+	japan := []maidenhead.Point{{Latitude: 30, Longitude: 125}, {Latitude: 46, Longitude: 125}, {Latitude: 46, Longitude: 150}, {Latitude: 30, Longitude: 150}}
+
+	for _, c := range []struct {
+		name  string
+		query func(ctx context.Context) ([]GridContact, error)
+		want  string
+		err   error
+	}{
+		{"100 km of Jakarta", func(ctx context.Context) ([]GridContact, error) {
+			return NearContacts(ctx, store, "event", maidenhead.Point{Latitude: -6.2, Longitude: 106.8}, 100)
+		}, "YB1BBB", nil},
+		{"within Japan", func(ctx context.Context) ([]GridContact, error) {
+			return ContactsWithin(ctx, store, "event", japan)
+		}, "JA1AAA", nil},
+		{"polygon of 2 corners", func(ctx context.Context) ([]GridContact, error) {
+			return ContactsWithin(ctx, store, "event", japan[:2])
+		}, "", ErrValidation},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			contacts, err := c.query(context.Background())
+			if !errors.Is(err, c.err) {
+				t.Fatalf("got %v, want %v", err, c.err)
+			}
+			if c.err == nil && (len(contacts) != 1 || contacts[0].CallSign != c.want) {
+				t.Fatalf("got %+v, want %s only", contacts, c.want)
+			}
+		})
+	}
+}
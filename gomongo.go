@@ -3,18 +3,17 @@ package gomongo
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/agustadewa/gomongo/bandplan"
+	"github.com/agustadewa/gomongo/modes"
 	"github.com/agustadewa/gomongo/tools"
 	"github.com/gin-gonic/gin"
 	"gitlab.com/yosiaagustadewa/qsl-service/models"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -23,42 +22,42 @@ import (
 type Adaptor struct {
 	Client mongo.Client
 	DBName string
+
+	degradedLatency time.Duration
+	healthTimeout   time.Duration
+	filters         *FilterParser
+	dupes           *DupeChecker
 }
 
-// Connect method
-func (adaptor *Adaptor) Connect(ctx context.Context, uri string) {
-	Client, err := mongo.NewClient(options.Client().ApplyURI(uri))
+// Connect method, use NewAdaptor to configure the pool and timeouts
+func (adaptor *Adaptor) Connect(ctx context.Context, uri string) error {
+	Client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	if err != nil {
-		log.Fatal(err)
+		return wrapError("Connect", err)
 	}
 
 	adaptor.Client = *Client
 
-	err = adaptor.Client.Connect(ctx)
-	if err != nil {
-		log.Fatal(err)
-	}
-
+	return nil
 }
 
 // QueryUpdateDocument method
 func (adaptor *Adaptor) QueryUpdateMany(ctx context.Context, collName string, filterQuery bson.M, updateQuery bson.M) error {
 	var err error
-	fmt.Println("filterQuery", filterQuery)
-	fmt.Println("updateQuery", updateQuery)
-
 	Collection := adaptor.Client.Database(adaptor.DBName).Collection(collName)
 	_, err = Collection.UpdateMany(ctx, filterQuery, updateQuery)
 
-	return err
+	return wrapError("QueryUpdateMany", err)
 }
 
 // QueryUpdateOne method
 func (adaptor *Adaptor) QueryUpdateOne(ctx context.Context, collName string, updateOpt *options.UpdateOptions, filterQuery bson.M, updateQuery bson.M, result *mongo.UpdateResult) error {
-	var err error
-	result, err = adaptor.Client.Database(adaptor.DBName).Collection(collName).UpdateOne(ctx, filterQuery, updateQuery, updateOpt)
+	updateResult, err := adaptor.Client.Database(adaptor.DBName).Collection(collName).UpdateOne(ctx, filterQuery, updateQuery, updateOpt)
 	if err != nil {
-		return err
+		return wrapError("QueryUpdateOne", err)
+	}
+	if result != nil {
+		*result = *updateResult
 	}
 	return nil
 }
@@ -66,7 +65,7 @@ func (adaptor *Adaptor) QueryUpdateOne(ctx context.Context, collName string, upd
 // QueryCreateCollection create collection in mongodb
 func (adaptor *Adaptor) QueryCreateCollection(ctx context.Context, collName string) error {
 	errCreateCollection := adaptor.Client.Database(adaptor.DBName).CreateCollection(ctx, collName)
-	return errCreateCollection
+	return wrapError("QueryCreateCollection", errCreateCollection)
 }
 
 // QueryInsert Query Insert to mongodb
@@ -77,13 +76,13 @@ func (adaptor *Adaptor) QueryInsert(ctx context.Context, collName string, byteQu
 	var query bson.M
 	err := json.Unmarshal(byteQuery, &query)
 	if err != nil {
-		return nil, err
+		return nil, wrapError("QueryInsert", err)
 	}
 
 	collection := adaptor.Client.Database(adaptor.DBName).Collection(collName)
 	insertResult, errorInserting = collection.InsertOne(ctx, query)
 
-	return insertResult, errorInserting
+	return insertResult, wrapError("QueryInsert", errorInserting)
 }
 
 // QueryInsertV2 Query Insert to mongodb
@@ -93,11 +92,7 @@ func (adaptor *Adaptor) QueryInsertV2(ctx context.Context, collName string, quer
 		Collection(collName).
 		InsertOne(ctx, query)
 
-	if errorInserting != nil {
-		log.Println(errorInserting)
-	}
-
-	return errorInserting
+	return wrapError("QueryInsertV2", errorInserting)
 }
 
 // QueryInsertV2 Query Insert to mongodb
@@ -107,17 +102,22 @@ func (adaptor *Adaptor) QueryInsertV3(ctx context.Context, collName string, quer
 		Collection(collName).
 		InsertOne(ctx, query)
 
-	if errorInserting != nil {
-		log.Println(errorInserting)
-	}
+	return result, wrapError("QueryInsertV3", errorInserting)
+}
+
+// QueryInsertMany Query Insert many to mongodb
+func (adaptor *Adaptor) QueryInsertMany(ctx context.Context, collName string, documents []interface{}) (*mongo.InsertManyResult, error) {
+	result, errorInserting := adaptor.Client.
+		Database(adaptor.DBName).
+		Collection(collName).
+		InsertMany(ctx, documents)
 
-	return result, errorInserting
+	return result, wrapError("QueryInsertMany", errorInserting)
 }
 
 // QueryFind query find to mongodb
 func (adaptor *Adaptor) QueryFind(ctx context.Context, collName string, byteQuery []byte) ([]byte, error) {
-	var query bson.M
-	err := json.Unmarshal(byteQuery, &query)
+	query, err := adaptor.filterParser().Parse(collName, byteQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -125,36 +125,42 @@ func (adaptor *Adaptor) QueryFind(ctx context.Context, collName string, byteQuer
 	var received bson.M
 	collection := adaptor.Client.Database(adaptor.DBName).Collection(collName)
 	errFinding := collection.FindOne(ctx, query).Decode(&received)
-	jsonBytes, _ := json.Marshal(&received)
+	if errFinding != nil {
+		return nil, wrapError("QueryFind", errFinding)
+	}
 
-	return jsonBytes, errFinding
+	jsonBytes, err := json.Marshal(&received)
+
+	return jsonBytes, wrapError("QueryFind", err)
 }
 
 // QueryFindV2 query find to mongodb
 func (adaptor *Adaptor) QueryFindV2(ctx context.Context, collName string, findOneOptions *options.FindOneOptions, query interface{}, result interface{}) error {
 	collection := adaptor.Client.Database(adaptor.DBName).Collection(collName)
-	return collection.FindOne(ctx, query, findOneOptions).Decode(result)
+	return wrapError("QueryFindV2", collection.FindOne(ctx, query, findOneOptions).Decode(result))
 }
 
 // QueryFindMany query find many to mongodb
 func (adaptor *Adaptor) QueryFindMany(ctx context.Context, collName string, byteQuery []byte, findOptions *options.FindOptions) ([]byte, error) {
-	var query bson.M
-	err := json.Unmarshal(byteQuery, &query)
+	query, err := adaptor.filterParser().Parse(collName, byteQuery)
 	if err != nil {
 		return nil, err
 	}
 
 	collection := adaptor.Client.Database(adaptor.DBName).Collection(collName)
-	cursor, _ := collection.Find(ctx, query, findOptions)
+	cursor, err := collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, wrapError("QueryFindMany", err)
+	}
 
 	var received []bson.M
 	if err = cursor.All(ctx, &received); err != nil {
-		log.Fatal(err)
+		return nil, wrapError("QueryFindMany", err)
 	}
 
 	results, err := json.Marshal(received)
 
-	return results, err
+	return results, wrapError("QueryFindMany", err)
 }
 
 // QueryFindManyV2 query find many to mongodb
@@ -162,15 +168,15 @@ func (adaptor *Adaptor) QueryFindManyV2(ctx context.Context, collName string, fi
 	collection := adaptor.Client.Database(adaptor.DBName).Collection(collName)
 	cursor, err := collection.Find(ctx, query, findOptions)
 	if err != nil {
-		return err
+		return wrapError("QueryFindManyV2", err)
 	}
 
 	err = cursor.All(ctx, result)
 	if err != nil {
-		return err
+		return wrapError("QueryFindManyV2", err)
 	}
 
-	return err
+	return nil
 }
 
 // QueryCount query find to mongodb
@@ -180,7 +186,20 @@ func (adaptor *Adaptor) QueryCount(ctx context.Context, collName string, query b
 		Collection(collName).
 		CountDocuments(ctx, query)
 
-	return Count, err
+	return Count, wrapError("QueryCount", err)
+}
+
+// QueryAggregate query aggregate to mongodb
+func (adaptor *Adaptor) QueryAggregate(ctx context.Context, collName string, aggregateOptions *options.AggregateOptions, pipeline interface{}, result interface{}) error {
+	cursor, err := adaptor.Client.
+		Database(adaptor.DBName).
+		Collection(collName).
+		Aggregate(ctx, pipeline, aggregateOptions)
+	if err != nil {
+		return wrapError("QueryAggregate", err)
+	}
+
+	return wrapError("QueryAggregate", cursor.All(ctx, result))
 }
 
 // QueryFindAndUpdate method
@@ -197,9 +216,9 @@ func (adaptor *Adaptor) QueryFindAndUpdate(ctx context.Context, collName string,
 	updateOptions.SetReturnDocument(1)
 	updateOptions.SetUpsert(true)
 
-	_ = adaptor.Client.Database(adaptor.DBName).Collection(collName).FindOneAndUpdate(ctx, queryFilter, updateQuery, &updateOptions)
+	err = adaptor.Client.Database(adaptor.DBName).Collection(collName).FindOneAndUpdate(ctx, queryFilter, updateQuery, &updateOptions).Err()
 
-	return count, err
+	return count, wrapError("QueryFindAndUpdate", err)
 }
 
 // QueryFindAndUpdateV2 method
@@ -214,7 +233,7 @@ func (adaptor *Adaptor) QueryFindAndUpdateV2(ctx context.Context, collName strin
 		FindOneAndUpdate(ctx, filterQuery, updateQuery, findAndUpdateOpt).
 		Decode(result)
 
-	return err
+	return wrapError("QueryFindAndUpdateV2", err)
 }
 
 // QueryRemoveOne method
@@ -224,7 +243,7 @@ func (adaptor *Adaptor) QueryRemoveOne(ctx context.Context, collName string, que
 		Collection(collName).
 		DeleteOne(ctx, queryFilter)
 	if err != nil {
-		return 0, err
+		return 0, wrapError("QueryRemoveOne", err)
 	}
 
 	return delResult.DeletedCount, err
@@ -237,14 +256,14 @@ func (adaptor *Adaptor) QueryRemoveMany(ctx context.Context, collName string, qu
 		Collection(collName).
 		DeleteMany(ctx, queryFilter)
 	if err != nil {
-		return 0, err
+		return 0, wrapError("QueryRemoveMany", err)
 
 	}
 	return delResult.DeletedCount, err
 }
 
 // QueryConfirm method
-func (adaptor *Adaptor) QueryConfirm(ctx context.Context, collName, key, value string) bool {
+func (adaptor *Adaptor) QueryConfirm(ctx context.Context, collName, key, value string) (bool, error) {
 	queryResult := bson.M{}
 	errFindKey := adaptor.Client.
 		Database(adaptor.DBName).
@@ -252,22 +271,26 @@ func (adaptor *Adaptor) QueryConfirm(ctx context.Context, collName, key, value s
 		FindOne(ctx, bson.M{"key": key}).
 		Decode(&queryResult)
 	if errFindKey != nil {
-		panic(errFindKey)
+		return false, wrapError("QueryConfirm", errFindKey)
 	}
 
-	if queryResult["value"].(string) == value {
-		return true
-	} else {
-		return false
+	storedValue, ok := queryResult["value"].(string)
+	if !ok {
+		return false, validationError("QueryConfirm", "value of key %q is not a string", key)
 	}
+
+	return storedValue == value, nil
 }
 
+// QuerySetIdentityCounter method stores count on the attribute of callSign
+// worked on frequency and mode, attributes flagged as dupes are skipped. It
+// returns false when no attribute matched.
 func (adaptor *Adaptor) QuerySetIdentityCounter(ctx context.Context, count int, callSign, frequency string, mode ...string) (bool, error) {
 
-	attributeElem := bson.M{"frequency": frequency}
+	attributeElem := bson.M{"frequency": frequency, "dupe": bson.M{"$ne": true}}
 	if len(mode) != 0 {
 		if mode[0] != "" {
-			attributeElem["mode"] = mode
+			attributeElem["mode"] = modes.Filter(mode[0])
 		}
 	}
 
@@ -292,11 +315,11 @@ func (adaptor *Adaptor) QuerySetIdentityCounter(ctx context.Context, count int,
 		return false, err
 	}
 
-	return true, nil
+	return updatedIdentity.MatchedCount > 0, nil
 }
 
 func (adaptor *Adaptor) QueryIncreaseEventCounter(ctx context.Context, id, frequency string) (bool, error) {
-	OID, err := primitive.ObjectIDFromHex(id)
+	OID, err := objectID("QueryIncreaseEventCounter", id)
 	if err != nil {
 		return false, err
 	}
@@ -328,7 +351,7 @@ func (adaptor *Adaptor) QueryIncreaseEventCounter(ctx context.Context, id, frequ
 }
 
 func (adaptor *Adaptor) QueryEventCounterValue(ctx context.Context, id, frequency string, countResult *int) error {
-	OID, err := primitive.ObjectIDFromHex(id)
+	OID, err := objectID("QueryEventCounterValue", id)
 	if err != nil {
 		return err
 	}
@@ -352,6 +375,10 @@ func (adaptor *Adaptor) QueryEventCounterValue(ctx context.Context, id, frequenc
 		return err
 	}
 
+	if len(eventResult.Attributes) == 0 {
+		return &Error{Op: "QueryEventCounterValue", Kind: ErrNotFound, Err: fmt.Errorf("frequency %q not found", frequency)}
+	}
+
 	*countResult = eventResult.Attributes[0].Counter
 
 	return nil
@@ -360,11 +387,12 @@ func (adaptor *Adaptor) QueryEventCounterValue(ctx context.Context, id, frequenc
 // /////////// PAYLOAD FILTER /////////////
 
 // ParsePayload method
-func (adaptor *Adaptor) ParsePayload(jsonByte []byte, out interface{}, c *gin.Context) {
+func (adaptor *Adaptor) ParsePayload(jsonByte []byte, out interface{}, c *gin.Context) error {
 	if isErr := json.Unmarshal(jsonByte, out); isErr != nil {
 		c.JSON(400, c.Error(isErr))
-		return
+		return &Error{Op: "ParsePayload", Kind: ErrValidation, Err: isErr}
 	}
+	return nil
 }
 
 // Modeling filler
@@ -373,15 +401,19 @@ func (adaptor *Adaptor) Modeling(jsonByte *[]byte, collName string) error {
 
 	if collName == "identity" {
 		identity := models.Identity{}
-		err = json.Unmarshal(*jsonByte, &identity)
+		if err = json.Unmarshal(*jsonByte, &identity); err != nil {
+			return &Error{Op: "Modeling", Kind: ErrValidation, Err: err}
+		}
 		*jsonByte, err = json.Marshal(&identity)
 
 	} else if collName == "event" {
 		event := models.EventCallSign{}
-		err = json.Unmarshal(*jsonByte, &event)
+		if err = json.Unmarshal(*jsonByte, &event); err != nil {
+			return &Error{Op: "Modeling", Kind: ErrValidation, Err: err}
+		}
 		*jsonByte, err = json.Marshal(&event)
 	}
-	return err
+	return wrapError("Modeling", err)
 }
 
 // ParseOptions method
@@ -423,11 +455,15 @@ func (adaptor *Adaptor) ParseCertificateFormat(certificateAttribute *models.Cert
 	certNumberGenerator.SetNDigit(4)
 	certNumberGenerator.SetCounter(certificateAttribute.Number)
 
-	certificateAttribute.Frequency = strings.ReplaceAll(certificateAttribute.Frequency, " MHz", "")
+	if frequency, err := bandplan.Parse(certificateAttribute.Frequency); err == nil {
+		certificateAttribute.Frequency = frequency.String()
+	} else {
+		certificateAttribute.Frequency = strings.ReplaceAll(certificateAttribute.Frequency, " MHz", "")
+	}
 	certificateAttribute.Band = strings.ReplaceAll(certificateAttribute.Band, " M", "")
 	certificateAttribute.Format = strings.ReplaceAll(certificateAttribute.Format, "#NO#", certNumberGenerator.ValString(certificateAttribute.Number))
 	certificateAttribute.Format = strings.ReplaceAll(certificateAttribute.Format, "#FREQUENCY#", certificateAttribute.Frequency)
-	certificateAttribute.Format = strings.ReplaceAll(certificateAttribute.Format, "#MODE#", certificateAttribute.Mode)
+	certificateAttribute.Format = strings.ReplaceAll(certificateAttribute.Format, "#MODE#", modes.Label(certificateAttribute.Mode))
 	certificateAttribute.Format = strings.ReplaceAll(certificateAttribute.Format, "#STATION#", certificateAttribute.Station)
 	certificateAttribute.Format = strings.ReplaceAll(certificateAttribute.Format, "#BAND#", certificateAttribute.Band)
 }
@@ -438,7 +474,7 @@ func (adaptor *Adaptor) SetDownloadLog(ctx context.Context, downloadLogData mode
 		Collection(models.CollCertificateDownloadLog).
 		InsertOne(ctx, &downloadLogData)
 	if errSetLog != nil {
-		return errors.New("error inserting log: " + errSetLog.Error())
+		return wrapError("SetDownloadLog", errSetLog)
 	}
 	return nil
 }
@@ -501,66 +537,125 @@ func (adaptor *Adaptor) GetReportLog(ctx context.Context, request models.TReques
 		return err
 	}
 
-	limitValue := request.Limit
-	if limitValue > 2000 {
+	limitValue := int64(request.Limit)
+	if limitValue < 1 || limitValue > 2000 {
 		limitValue = 2000
 	}
 
-	var projections = make(bson.D, 0, len(request.Projections))
-	for _, prj := range request.Projections {
-		projections = append(projections, bson.E{Key: prj, Value: 1})
+	rows, err := Aggregate[models.TResponseCallSignReport](ctx, adaptor, models.CollIdentity, reportLogPipeline(request, eventName, limitValue))
+	if err != nil {
+		return err
 	}
 
-	var sorts = make(bson.D, 0, len(request.Sorts))
-	for _, s := range request.Sorts {
-		sorts = append(sorts, bson.E{Key: s.Key, Value: s.Value.GetVal()})
+	*results = rows
+	return nil
+}
+
+// eventQSOPipeline returns the pipeline flattening the identities of an
+// event into one document per QSO, with the name and call sign of the identity
+func eventQSOPipeline(eventID, eventName string) Pipeline {
+	return NewPipeline().
+		Match(bson.D{{Key: "event_id", Value: eventID}}).
+		Unwind("$attributes", true).
+		ReplaceRoot(MergeObjects(
+			bson.M{
+				"name":       Trim("$$ROOT.name"),
+				"call_sign":  "$$ROOT.call_sign",
+				"event_name": eventName,
+			},
+			"$$ROOT.attributes",
+		))
+}
+
+// reportLogPipeline returns the pipeline of the call sign report of an event,
+// a limit of 0 keeps every QSO
+func reportLogPipeline(request models.TRequestCallSignReport, eventName string, limit int64) Pipeline {
+	pipeline := eventQSOPipeline(request.EventID, eventName).
+		Sort(ReportSortKeys(request)...)
+
+	if limit > 0 {
+		pipeline = pipeline.Limit(limit)
+	}
+	if len(request.Projections) > 0 {
+		pipeline = pipeline.Project(Include(request.Projections...))
 	}
+	return pipeline
+}
 
-	pipeline := mongo.Pipeline{
-		bson.D{{Key: "$match", Value: bson.D{{Key: "event_id", Value: request.EventID}}}},
-		bson.D{{Key: "$unwind", Value: bson.D{
-			bson.E{Key: "path", Value: "$attributes"},
-			bson.E{Key: "preserveNullAndEmptyArrays", Value: true},
-		}}},
-		bson.D{{Key: "$replaceRoot", Value: bson.M{
-			"newRoot": bson.M{
-				"$mergeObjects": bson.A{
-					bson.M{
-						"name":       bson.M{"$trim": bson.M{"input": "$$ROOT.name"}},
-						"call_sign":  "$$ROOT.call_sign",
-						"event_name": eventName,
-					},
-					"$$ROOT.attributes",
-				},
+// reportKeysetPipeline returns the QSOs of the call sign report of an event
+// as eventQSOPipeline does, with the _id of their identity and their
+// qso_index in it to break the ties of a keyset page
+func reportKeysetPipeline(request models.TRequestCallSignReport, eventName string) Pipeline {
+	pipeline := NewPipeline().
+		Match(bson.D{{Key: "event_id", Value: request.EventID}}).
+		UnwindIndex("$attributes", "qso_index", true).
+		ReplaceRoot(MergeObjects(
+			bson.M{
+				"_id":        "$$ROOT._id",
+				"qso_index":  "$$ROOT.qso_index",
+				"name":       Trim("$$ROOT.name"),
+				"call_sign":  "$$ROOT.call_sign",
+				"event_name": eventName,
 			},
-		}}},
-		bson.D{{Key: "$sort", Value: sorts}},
-		bson.D{{Key: "$limit", Value: limitValue}},
-		bson.D{{Key: "$project", Value: projections}},
+			"$$ROOT.attributes",
+		))
+
+	if len(request.Projections) > 0 {
+		// the sort keys stay, the page tokens are read from them
+		fields := append([]string{}, request.Projections...)
+		for _, key := range reportKeysetSort(request) {
+			fields = append(fields, key.Key)
+		}
+		seen := map[string]bool{}
+		kept := fields[:0]
+		for _, field := range fields {
+			if !seen[field] {
+				seen[field] = true
+				kept = append(kept, field)
+			}
+		}
+		pipeline = pipeline.Project(Include(kept...))
 	}
+	return pipeline
+}
 
-	opt := options.AggregateOptions{}
+// reportKeysetSort returns the Sorts of request followed by the identity and
+// the index of the QSO in it, which are unique together
+func reportKeysetSort(request models.TRequestCallSignReport) []SortKey {
+	return append(ReportSortKeys(request), SortKey{Key: "_id", Order: 1}, SortKey{Key: "qso_index", Order: 1})
+}
 
-	cursor, err := adaptor.Client.
-		Database(adaptor.DBName).
-		Collection(models.CollIdentity).
-		Aggregate(ctx, pipeline, &opt)
+// ReportLogKeyset function returns a page of the report of GetReportLog in the
+// Sorts of request, request.Limit QSOs from 1 to 2000 after or before token.
+// An empty token asks for the first page, unlike GetReportLog every page of
+// a large event costs the same.
+func ReportLogKeyset(ctx context.Context, store Querier, request models.TRequestCallSignReport, token string) (KeysetPage[models.TResponseCallSignReport], error) {
+	OID, err := objectID("ReportLogKeyset", request.EventID)
 	if err != nil {
-		return err
+		return KeysetPage[models.TResponseCallSignReport]{}, err
+	}
+	var event models.Event
+	if err := store.QueryFindV2(ctx, models.CollEvent, &options.FindOneOptions{}, bson.M{"_id": OID}, &event); err != nil {
+		return KeysetPage[models.TResponseCallSignReport]{}, err
 	}
 
-	err = cursor.All(ctx, results)
-	if err != nil {
-		return err
+	limitValue := int64(request.Limit)
+	if limitValue < 1 || limitValue > 2000 {
+		limitValue = 2000
 	}
+	keyset := KeysetRequest{Sort: reportKeysetSort(request), Limit: limitValue, Token: token}
+	return AggregateKeyset[models.TResponseCallSignReport](ctx, store, models.CollIdentity, reportKeysetPipeline(request, event.Name), keyset)
+}
 
-	return nil
+// ReportLogKeyset method, see ReportLogKeyset function
+func (adaptor *Adaptor) ReportLogKeyset(ctx context.Context, request models.TRequestCallSignReport, token string) (KeysetPage[models.TResponseCallSignReport], error) {
+	return ReportLogKeyset(ctx, adaptor, request, token)
 }
 
 func (adaptor *Adaptor) GetEventName(ctx context.Context, ID string, result *string) error {
 	var event models.Event
 
-	OID, err := primitive.ObjectIDFromHex(ID)
+	OID, err := objectID("GetEventName", ID)
 	if err != nil {
 		return err
 	}
@@ -573,7 +668,7 @@ func (adaptor *Adaptor) GetEventName(ctx context.Context, ID string, result *str
 		}).Decode(&event)
 
 	if err != nil {
-		return err
+		return wrapError("GetEventName", err)
 	}
 
 	*result = event.Name
@@ -581,39 +676,13 @@ func (adaptor *Adaptor) GetEventName(ctx context.Context, ID string, result *str
 	return nil
 }
 
-func (adaptor *Adaptor) Pagination(docLimit, page, totalDoc int64, opt *options.FindOptions) int64 {
-
-	// PAGINATION
-	if docLimit < 1 {
-		docLimit = 1
-	}
-
-	if docLimit > totalDoc {
-		docLimit = totalDoc
-	}
-
-	if docLimit == 0 {
-		docLimit = 1
-	}
-
-	totalPage := totalDoc / docLimit
-
-	if totalDoc%docLimit != 0 {
-		totalPage++
-	}
-
-	if page < 1 {
-		page = 1
-	}
-	if page > totalPage {
-		page = totalPage
-	}
-
-	skipValue := (page - 1) * docLimit
-	opt.SetSkip(skipValue)
-	opt.SetLimit(docLimit)
+// Pagination method sets the skip and limit of page on opt
+func (adaptor *Adaptor) Pagination(docLimit, page, totalDoc int64, opt *options.FindOptions) PageInfo {
+	info := NewPageInfo(docLimit, page, totalDoc)
+	opt.SetSkip(info.Skip())
+	opt.SetLimit(info.Limit)
 
-	return totalPage
+	return info
 }
 
 // GetNameRecommendation method
@@ -623,7 +692,7 @@ func (adaptor *Adaptor) GetNameRecommendationByCallSign(ctx context.Context, cal
 	if err := adaptor.Client.Database(adaptor.DBName).Collection("callsign_name").FindOne(ctx, bson.M{
 		"call_sign": callSign,
 	}).Decode(&res); err != nil {
-		return "", err
+		return "", wrapError("GetNameRecommendationByCallSign", err)
 	}
 
 	return res.Name, nil
@@ -0,0 +1,32 @@
+package gomongo
+
+import (
+	"testing"
+
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+)
+
+func TestParseCertificateFormat(t *testing.T) {
+	for _, c := range []struct {
+		frequency, want string
+	}{
+		{"145.240", "145.240 MHz"},
+		{"145.24", "145.240 MHz"},
+		{"7.135 MHz", "7.135 MHz"},
+		{"1296.2", "1296.200 MHz"},
+	} {
+		attribute := models.CertificateAttribute{Number: 7, Frequency: c.frequency, Band: "2 M", Format: "#NO# #FREQUENCY# MHz"}
+		(&Adaptor{}).ParseCertificateFormat(&attribute)
+		if want := "0007 " + c.want; attribute.Format != want {
+			t.Errorf("%q: got %q, want %q", c.frequency, attribute.Format, want)
+		}
+	}
+
+	for mode, want := range map[string]string{"FT4": "FT4", "DMR": "DMR", "SSB": "SSB", "Phone": "Phone"} {
+		attribute := models.CertificateAttribute{Mode: mode, Format: "#MODE#"}
+		(&Adaptor{}).ParseCertificateFormat(&attribute)
+		if attribute.Format != want {
+			t.Errorf("%q: got %q, want %q", mode, attribute.Format, want)
+		}
+	}
+}
@@ -0,0 +1,550 @@
+// Package gomongotest holds the conformance suite every gomongo.Querier
+// implementation has to pass. Call Run from a _test.go file:
+//
+//	func TestQuerier(t *testing.T) {
+//		for name, newStore := range gomongotest.Backends(t) {
+//			t.Run(name, func(t *testing.T) { gomongotest.Run(t, newStore) })
+//		}
+//	}
+package gomongotest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/agustadewa/gomongo"
+	"github.com/agustadewa/gomongo/maidenhead"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnvURI names the environment variable holding the MongoDB server used by Backends
+const EnvURI = "GOMONGO_TEST_URI"
+
+// StoreFactory returns an empty store for one test
+type StoreFactory func(t *testing.T) gomongo.Querier
+
+// Backends returns the in-memory backend and, when EnvURI is set, a MongoDB
+// backed Adaptor working on a throwaway database dropped after the test
+func Backends(t *testing.T) map[string]StoreFactory {
+	backends := map[string]StoreFactory{
+		"memory": func(t *testing.T) gomongo.Querier {
+			return gomongo.NewMemoryAdaptor()
+		},
+	}
+
+	uri := os.Getenv(EnvURI)
+	if uri == "" {
+		t.Logf("%s is not set, skipping the MongoDB backend", EnvURI)
+		return backends
+	}
+
+	backends["mongodb"] = func(t *testing.T) gomongo.Querier {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		adaptor := &gomongo.Adaptor{DBName: fmt.Sprintf("gomongotest_%d", time.Now().UnixNano())}
+		if err := adaptor.Connect(ctx, uri); err != nil {
+			t.Fatalf("connect: %v", err)
+		}
+		t.Cleanup(func() {
+			_ = adaptor.Client.Database(adaptor.DBName).Drop(context.Background())
+			_ = adaptor.Close()
+		})
+		return adaptor
+	}
+	return backends
+}
+
+type attribute struct {
+	Frequency string `bson:"frequency"`
+	Mode      string `bson:"mode"`
+	Counter   int    `bson:"counter"`
+}
+
+type identity struct {
+	ID         string      `bson:"_id"`
+	CallSign   string      `bson:"call_sign"`
+	Name       string      `bson:"name,omitempty"`
+	Attributes []attribute `bson:"attributes,omitempty"`
+}
+
+func seed(t *testing.T, ctx context.Context, store gomongo.Querier, coll string) {
+	t.Helper()
+	docs := []interface{}{
+		identity{ID: "1", CallSign: "YB0AAA", Name: "Ani", Attributes: []attribute{{"7.135", "SSB", 1}, {"14.200", "CW", 2}}},
+		identity{ID: "2", CallSign: "YB1BBB", Name: "Budi", Attributes: []attribute{{"7.135", "CW", 3}}},
+		identity{ID: "3", CallSign: "YC2CCC", Name: "Citra", Attributes: []attribute{{"145.240", "FM", 4}}},
+	}
+	if _, err := store.QueryInsertMany(ctx, coll, docs); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+}
+
+// Run runs the conformance suite, newStore must return an empty store
+func Run(t *testing.T, newStore StoreFactory) {
+	ctx := context.Background()
+	const coll = "identity"
+
+	t.Run("FindOne", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		var result identity
+		if err := store.QueryFindV2(ctx, coll, nil, bson.M{"call_sign": "YB1BBB"}, &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.ID != "2" {
+			t.Fatalf("got _id %q, want 2", result.ID)
+		}
+
+		err := store.QueryFindV2(ctx, coll, nil, bson.M{"call_sign": "NONE"}, &result)
+		if !errors.Is(err, gomongo.ErrNotFound) {
+			t.Fatalf("got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("ClientFilter", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		found, err := store.QueryFindMany(ctx, coll, []byte(`{"attributes": {"$elemMatch": {"frequency": "7.135", "mode": {"$in": ["CW"]}}}}`), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var results []identity
+		if err := json.Unmarshal(found, &results); err != nil || len(results) != 1 {
+			t.Fatalf("got %d results (%v), want 1", len(results), err)
+		}
+
+		_, err = store.QueryFindMany(ctx, coll, []byte(`{"$or": [{"call_sign": "YB0AAA"}, {"$where": "sleep(1000)"}]}`), nil)
+		var filterErr *gomongo.FilterError
+		if !errors.Is(err, gomongo.ErrValidation) || !errors.As(err, &filterErr) || filterErr.Path != "$where" {
+			t.Fatalf("got %v, want $where rejected", err)
+		}
+	})
+
+	t.Run("DuplicateKey", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		_, err := store.QueryInsertV3(ctx, coll, identity{ID: "1", CallSign: "YB0AAA"})
+		if !errors.Is(err, gomongo.ErrDuplicateKey) {
+			t.Fatalf("got %v, want ErrDuplicateKey", err)
+		}
+	})
+
+	t.Run("ElemMatch", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		var results []identity
+		filter := bson.M{"attributes": bson.M{"$elemMatch": bson.M{"frequency": "7.135", "mode": "CW"}}}
+		if err := store.QueryFindManyV2(ctx, coll, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}), filter, &results); err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].ID != "2" {
+			t.Fatalf("got %+v, want identity 2", results)
+		}
+	})
+
+	t.Run("SortSkipLimitProjection", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		opt := options.Find().
+			SetSort(bson.D{{Key: "call_sign", Value: -1}}).
+			SetSkip(1).
+			SetLimit(1).
+			SetProjection(bson.M{"call_sign": 1})
+
+		var results []bson.M
+		if err := store.QueryFindManyV2(ctx, coll, opt, bson.M{}, &results); err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0]["call_sign"] != "YB1BBB" {
+			t.Fatalf("got %v, want YB1BBB", results)
+		}
+		if _, ok := results[0]["name"]; ok {
+			t.Fatalf("projection kept name: %v", results[0])
+		}
+	})
+
+	t.Run("ElemMatchProjection", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		var result identity
+		opt := options.FindOne().SetProjection(bson.M{"attributes": bson.M{"$elemMatch": bson.M{"frequency": "14.200"}}})
+		if err := store.QueryFindV2(ctx, coll, opt, bson.M{"_id": "1"}, &result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Attributes) != 1 || result.Attributes[0].Counter != 2 {
+			t.Fatalf("got %+v, want the 14.200 attribute", result.Attributes)
+		}
+	})
+
+	t.Run("PositionalSetAndInc", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		filter := bson.M{"_id": "1", "attributes": bson.M{"$elemMatch": bson.M{"frequency": "14.200"}}}
+		if err := store.QueryUpdateOne(ctx, coll, nil, filter, bson.M{"$set": bson.M{"attributes.$.mode": "SSB"}}, nil); err != nil {
+			t.Fatal(err)
+		}
+		result := mongo.UpdateResult{}
+		if err := store.QueryUpdateOne(ctx, coll, nil, filter, bson.M{"$inc": bson.M{"attributes.$.counter": 5}}, &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.MatchedCount != 1 || result.ModifiedCount != 1 {
+			t.Fatalf("got %+v, want one matched and modified", result)
+		}
+
+		var updated identity
+		if err := store.QueryFindV2(ctx, coll, nil, bson.M{"_id": "1"}, &updated); err != nil {
+			t.Fatal(err)
+		}
+		if got := updated.Attributes[1]; got.Mode != "SSB" || got.Counter != 7 {
+			t.Fatalf("got %+v, want SSB with counter 7", got)
+		}
+		if got := updated.Attributes[0]; got.Counter != 1 {
+			t.Fatalf("first attribute changed: %+v", got)
+		}
+	})
+
+	t.Run("MinMax", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		filter := bson.M{"_id": "1", "attributes": bson.M{"$elemMatch": bson.M{"frequency": "14.200"}}}
+		for _, c := range []struct {
+			op    string
+			value int
+			want  int
+		}{
+			{"$max", 1, 2},
+			{"$max", 5, 5},
+			{"$min", 6, 5},
+			{"$min", 3, 3},
+		} {
+			if err := store.QueryUpdateOne(ctx, coll, nil, filter, bson.M{c.op: bson.M{"attributes.$.counter": c.value}}, &mongo.UpdateResult{}); err != nil {
+				t.Fatal(err)
+			}
+			var updated identity
+			if err := store.QueryFindV2(ctx, coll, nil, bson.M{"_id": "1"}, &updated); err != nil {
+				t.Fatal(err)
+			}
+			if got := updated.Attributes[1].Counter; got != c.want {
+				t.Fatalf("%s %d: got counter %d, want %d", c.op, c.value, got, c.want)
+			}
+		}
+
+		// a missing field takes the value
+		if err := store.QueryUpdateOne(ctx, coll, nil, bson.M{"_id": "2"}, bson.M{"$max": bson.M{"best": 9}}, &mongo.UpdateResult{}); err != nil {
+			t.Fatal(err)
+		}
+		var updated struct {
+			Best int `bson:"best"`
+		}
+		if err := store.QueryFindV2(ctx, coll, nil, bson.M{"_id": "2"}, &updated); err != nil || updated.Best != 9 {
+			t.Fatalf("got %+v (%v), want best set to 9", updated, err)
+		}
+	})
+
+	t.Run("UpsertSetOnInsert", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		update := bson.M{
+			"$set":         bson.M{"name": "Dewi"},
+			"$setOnInsert": bson.M{"_id": "4"},
+		}
+		opt := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+		var inserted identity
+		if err := store.QueryFindAndUpdateV2(ctx, coll, opt, bson.M{"call_sign": "YD3DDD"}, update, &inserted); err != nil {
+			t.Fatal(err)
+		}
+		if inserted.ID != "4" || inserted.CallSign != "YD3DDD" || inserted.Name != "Dewi" {
+			t.Fatalf("got %+v, want upserted YD3DDD", inserted)
+		}
+
+		var existing identity
+		update["$setOnInsert"] = bson.M{"_id": "5"}
+		if err := store.QueryFindAndUpdateV2(ctx, coll, opt, bson.M{"call_sign": "YB0AAA"}, update, &existing); err != nil {
+			t.Fatal(err)
+		}
+		if existing.ID != "1" || existing.Name != "Dewi" {
+			t.Fatalf("got %+v, want updated identity 1", existing)
+		}
+
+		count, err := store.QueryCount(ctx, coll, bson.M{})
+		if err != nil || count != 4 {
+			t.Fatalf("got %d documents (%v), want 4", count, err)
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		deleted, err := store.QueryRemoveMany(ctx, coll, bson.M{"call_sign": bson.M{"$in": bson.A{"YB0AAA", "YB1BBB"}}})
+		if err != nil || deleted != 2 {
+			t.Fatalf("got %d deleted (%v), want 2", deleted, err)
+		}
+		deleted, err = store.QueryRemoveOne(ctx, coll, bson.M{"call_sign": "YB0AAA"})
+		if err != nil || deleted != 0 {
+			t.Fatalf("got %d deleted (%v), want 0", deleted, err)
+		}
+	})
+
+	t.Run("Transaction", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		errRollback := errors.New("rollback")
+		err := store.WithTransaction(ctx, func(txCtx context.Context) error {
+			if _, err := store.QueryRemoveOne(txCtx, coll, bson.M{"_id": "1"}); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		if isStandalone(err) {
+			t.Skip("transactions need a replica set")
+		}
+		if !errors.Is(err, errRollback) {
+			t.Fatalf("got %v, want the callback error", err)
+		}
+
+		err = store.WithTransaction(ctx, func(txCtx context.Context) error {
+			_, err := store.QueryInsertV3(txCtx, coll, identity{ID: "4", CallSign: "YD3DDD"})
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count, err := store.QueryCount(ctx, coll, bson.M{})
+		if err != nil || count != 4 {
+			t.Fatalf("got %d documents (%v), want 4", count, err)
+		}
+	})
+
+	t.Run("Aggregate", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"call_sign": bson.M{"$ne": "YC2CCC"}}}},
+			{{Key: "$unwind", Value: bson.M{"path": "$attributes", "preserveNullAndEmptyArrays": true}}},
+			{{Key: "$replaceRoot", Value: bson.M{"newRoot": bson.M{"$mergeObjects": bson.A{
+				bson.M{"call_sign": "$$ROOT.call_sign"},
+				"$$ROOT.attributes",
+			}}}}},
+			{{Key: "$sort", Value: bson.D{{Key: "counter", Value: -1}}}},
+			{{Key: "$limit", Value: 2}},
+			{{Key: "$project", Value: bson.D{{Key: "call_sign", Value: 1}, {Key: "counter", Value: 1}, {Key: "_id", Value: 0}}}},
+		}
+
+		var results []bson.M
+		if err := store.QueryAggregate(ctx, coll, nil, pipeline, &results); err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("got %d results, want 2", len(results))
+		}
+		if results[0]["call_sign"] != "YB1BBB" || fmt.Sprint(results[0]["counter"]) != "3" {
+			t.Fatalf("got %v, want YB1BBB with counter 3 first", results[0])
+		}
+	})
+
+	t.Run("PipelineBuilder", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		qsos := gomongo.NewPipeline().Unwind("$attributes", false)
+		pipeline := qsos.Facet(
+			gomongo.Facet{Name: "modes", Pipeline: gomongo.NewPipeline().
+				Group("$attributes.mode", bson.D{{Key: "qsos", Value: gomongo.Sum(1)}}).
+				Sort(gomongo.SortKey{Key: "_id", Order: 1})},
+			gomongo.Facet{Name: "total", Pipeline: gomongo.NewPipeline().Count("qsos")},
+		)
+
+		type summary struct {
+			Modes []struct {
+				Mode string `bson:"_id"`
+				QSOs int    `bson:"qsos"`
+			} `bson:"modes"`
+			Total []struct {
+				QSOs int `bson:"qsos"`
+			} `bson:"total"`
+		}
+		results, err := gomongo.Aggregate[summary](ctx, store, coll, pipeline)
+		if err != nil || len(results) != 1 {
+			t.Fatalf("got %+v (%v), want one summary", results, err)
+		}
+		got := results[0]
+		if len(got.Modes) != 3 || got.Modes[0].Mode != "CW" || got.Modes[0].QSOs != 2 || got.Total[0].QSOs != 4 {
+			t.Fatalf("got %+v, want 2 CW of 4 QSOs", got)
+		}
+		if len(qsos.Stages()) != 1 {
+			t.Fatalf("extending the pipeline changed the base: %v", qsos.Stages())
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		var out bytes.Buffer
+		opt := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetProjection(bson.M{"call_sign": 1})
+		written, err := gomongo.StreamJSON(ctx, store, coll, opt, bson.M{}, &out, gomongo.StreamJSONArray)
+		if err != nil || written != 3 {
+			t.Fatalf("got %d written (%v), want 3", written, err)
+		}
+		want := `[{"_id":"1","call_sign":"YB0AAA"},{"_id":"2","call_sign":"YB1BBB"},{"_id":"3","call_sign":"YC2CCC"}]`
+		if out.String() != want {
+			t.Fatalf("got %s, want %s", out.String(), want)
+		}
+
+		streamCtx, cancel := context.WithCancel(ctx)
+		documents, errc := gomongo.Stream[identity](streamCtx, store, coll, opt, bson.M{}, 0)
+		first := <-documents
+		cancel()
+		for range documents {
+		}
+		if err := <-errc; first.ID != "1" || !errors.Is(err, context.Canceled) {
+			t.Fatalf("got %q and %v, want identity 1 and context.Canceled", first.ID, err)
+		}
+	})
+
+	t.Run("Keyset", func(t *testing.T) {
+		store := newStore(t)
+		seed(t, ctx, store, coll)
+
+		request := gomongo.KeysetRequest{Sort: []gomongo.SortKey{{Key: "name", Order: -1}}, Limit: 2}
+		first, err := gomongo.FindKeyset[identity](ctx, store, coll, nil, request)
+		if err != nil || len(first.Items) != 2 || first.Items[0].Name != "Citra" || !first.HasNext || first.HasPrev {
+			t.Fatalf("got %+v (%v), want Citra and Budi with a next page", first, err)
+		}
+
+		request.Token = first.Next
+		second, err := gomongo.FindKeyset[identity](ctx, store, coll, nil, request)
+		if err != nil || len(second.Items) != 1 || second.Items[0].Name != "Ani" || second.HasNext || !second.HasPrev {
+			t.Fatalf("got %+v (%v), want Ani with a previous page", second, err)
+		}
+
+		request.Token = second.Prev
+		back, err := gomongo.FindKeyset[identity](ctx, store, coll, nil, request)
+		if err != nil || len(back.Items) != 2 || back.Items[0].Name != "Citra" || back.Items[1].Name != "Budi" {
+			t.Fatalf("got %+v (%v), want Citra and Budi again", back, err)
+		}
+
+		request.Sort[0].Order = 1
+		if _, err := gomongo.FindKeyset[identity](ctx, store, coll, nil, request); !errors.Is(err, gomongo.ErrValidation) {
+			t.Fatalf("got %v, want a token of another sort rejected", err)
+		}
+	})
+
+	t.Run("ReportKeyset", func(t *testing.T) {
+		store := newStore(t)
+		eventID := primitive.NewObjectID()
+		if _, err := store.QueryInsertV3(ctx, "event", bson.M{"_id": eventID, "name": "Event"}); err != nil {
+			t.Fatal(err)
+		}
+		for _, doc := range []bson.M{
+			{"_id": "a", "event_id": eventID.Hex(), "call_sign": "YB0AAA", "name": "Ani", "attributes": bson.A{bson.M{"frequency": "7.100"}, bson.M{"frequency": "14.200"}}},
+			{"_id": "b", "event_id": eventID.Hex(), "call_sign": "YB1BBB", "name": "Budi", "attributes": bson.A{bson.M{"frequency": "7.100"}}},
+			{"_id": "c", "event_id": eventID.Hex(), "call_sign": "YB2CCC", "name": "Citra"},
+		} {
+			if _, err := store.QueryInsertV3(ctx, "identity", doc); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// the two QSOs of YB0AAA are told apart by their index
+		request := models.TRequestCallSignReport{EventID: eventID.Hex(), Limit: 2, Projections: []string{"call_sign"}}
+		first, err := gomongo.ReportLogKeyset(ctx, store, request, "")
+		if err != nil || len(first.Items) != 2 || first.Items[1].CallSign != "YB0AAA" || !first.HasNext {
+			t.Fatalf("got %+v (%v), want both QSOs of YB0AAA", first, err)
+		}
+		second, err := gomongo.ReportLogKeyset(ctx, store, request, first.Next)
+		if err != nil || len(second.Items) != 2 || second.Items[0].CallSign != "YB1BBB" || second.Items[1].CallSign != "YB2CCC" || second.HasNext {
+			t.Fatalf("got %+v (%v), want YB1BBB and the identity without QSOs", second, err)
+		}
+		back, err := gomongo.ReportLogKeyset(ctx, store, request, second.Prev)
+		if err != nil || len(back.Items) != 2 || back.Items[0].CallSign != "YB0AAA" || back.HasPrev {
+			t.Fatalf("got %+v (%v), want the first page again", back, err)
+		}
+	})
+
+	t.Run("Sequence", func(t *testing.T) {
+		store := newStore(t)
+		seq := gomongo.NewSequence(store, gomongo.SequenceKey{EventID: "event", Frequency: "7.100"}, gomongo.SequenceFormat{Digits: 4, Prefix: "QSL-"})
+
+		first, err := seq.Next(ctx)
+		if err != nil || first != "QSL-0001" {
+			t.Fatalf("got %q (%v), want QSL-0001", first, err)
+		}
+		numbers, err := seq.Reserve(ctx, 3)
+		if err != nil || len(numbers) != 3 || numbers[0] != "QSL-0002" || numbers[2] != "QSL-0004" {
+			t.Fatalf("got %v (%v), want QSL-0002 to QSL-0004", numbers, err)
+		}
+		current, err := seq.Current(ctx)
+		if err != nil || current != 4 {
+			t.Fatalf("got current %d (%v), want 4", current, err)
+		}
+
+		seeded := gomongo.NewSequence(store, gomongo.SequenceKey{EventID: "event", Frequency: "14.200"}, gomongo.SequenceFormat{Digits: 4, Prefix: "QSL-"})
+		for _, value := range []int64{41, 99} {
+			if err := seeded.Seed(ctx, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if next, err := seeded.Next(ctx); err != nil || next != "QSL-0042" {
+			t.Fatalf("got %q (%v), want QSL-0042 after the first seed", next, err)
+		}
+	})
+
+	t.Run("GeoIndex", func(t *testing.T) {
+		store := newStore(t)
+		indexer, ok := store.(interface{ EnsureGeoIndex(context.Context) error })
+		if !ok {
+			t.Skip("the store has no geo index")
+		}
+		// creating it again is a no-op
+		for i := 0; i < 2; i++ {
+			if err := indexer.EnsureGeoIndex(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		date := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+		qsos := []gomongo.QSO{
+			{CallSign: "YB1BBB", Frequency: "7.135", Mode: "SSB", Date: date, Grid: "OI33jt"},
+			{CallSign: "JA1AAA", Frequency: "14.2", Mode: "SSB", Date: date, Grid: "PM95"},
+		}
+		if _, err := gomongo.ImportQSOs(ctx, store, "event", qsos, gomongo.ImportOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		near, err := gomongo.NearContacts(ctx, store, "event", maidenhead.Point{Latitude: -6.2, Longitude: 106.8}, 100)
+		if err != nil || len(near) != 1 || near[0].CallSign != "YB1BBB" {
+			t.Fatalf("got %+v (%v), want YB1BBB only", near, err)
+		}
+	})
+}
+
+// isStandalone reports the error a standalone server returns for transactions
+func isStandalone(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) && serverErr.HasErrorCode(20)
+}
@@ -0,0 +1,91 @@
+package gomongo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/agustadewa/gomongo/bandplan"
+	"github.com/agustadewa/gomongo/callsign"
+	"github.com/agustadewa/gomongo/modes"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// NormalizeIdentity function keys identity on its station call sign, see
+// callsign.Normalize, rewrites the frequency of every attribute in
+// MHz and derives its band, see bandplan.Normalize, and rewrites its mode as
+// the ADIF submode, or the ADIF mode when it has none, see modes.Specific.
+// It fails with ErrValidation when a
+// frequency is outside the band plan of region or outside the band of the
+// attribute, or when the call sign or a mode is invalid.
+func NormalizeIdentity(identity *models.Identity, region bandplan.Region) error {
+	callSign, err := callsign.Normalize(identity.CallSign)
+	if err != nil {
+		return &Error{Op: "NormalizeIdentity", Kind: ErrValidation, Err: err}
+	}
+	identity.CallSign = callSign
+
+	for i := range identity.Attributes {
+		attribute := &identity.Attributes[i]
+		frequency, band, err := bandplan.Normalize(attribute.Frequency, attribute.Band, region)
+		if err != nil {
+			return &Error{Op: "NormalizeIdentity", Kind: ErrValidation, Err: fmt.Errorf("attributes.%d: %w", i, err)}
+		}
+		mode, err := modes.Specific(attribute.Mode)
+		if err != nil {
+			return &Error{Op: "NormalizeIdentity", Kind: ErrValidation, Err: fmt.Errorf("attributes.%d: %w", i, err)}
+		}
+		attribute.Frequency, attribute.Band, attribute.Mode = frequency, band, mode
+	}
+	return nil
+}
+
+// InsertIdentity method, see InsertIdentity
+func (adaptor *Adaptor) InsertIdentity(ctx context.Context, identity models.Identity, region bandplan.Region) (ImportResult, error) {
+	return InsertIdentity(ctx, adaptor, identity, region)
+}
+
+// InsertIdentity function stores the attributes of identity as QSOs of its
+// event with the checks of ImportQSOs: they join the identity of the same
+// station call sign and the dupe rule of the event applies. An identity
+// without attributes or with an invalid one fails with ErrValidation, a
+// duplicate the dupe rule rejects fails with ErrDupe.
+func InsertIdentity(ctx context.Context, store Querier, identity models.Identity, region bandplan.Region) (ImportResult, error) {
+	if len(identity.Attributes) == 0 {
+		return ImportResult{}, validationError("InsertIdentity", "%s has no attribute", identity.CallSign)
+	}
+	qsos := make([]QSO, 0, len(identity.Attributes))
+	for i, attribute := range identity.Attributes {
+		millis, err := strconv.ParseInt(attribute.Date, 10, 64)
+		if err != nil {
+			return ImportResult{}, validationError("InsertIdentity", "attributes.%d: bad date %q", i, attribute.Date)
+		}
+		qsos = append(qsos, QSO{
+			CallSign:  identity.CallSign,
+			Name:      identity.Name,
+			Frequency: attribute.Frequency,
+			Band:      attribute.Band,
+			Mode:      attribute.Mode,
+			RST:       attribute.RST,
+			Date:      timeFromMillis(millis),
+		})
+	}
+
+	result, err := ImportQSOs(ctx, store, identity.EventID, qsos, ImportOptions{Region: region})
+	if err != nil {
+		return result, err
+	}
+	return result, importFailure("InsertIdentity", result)
+}
+
+// qsoIdentity returns an identity of callSign with attribute as its only
+// attribute, for the certificate templates that print attribute 0
+func qsoIdentity(callSign, name string, attribute bson.M) (models.Identity, error) {
+	var identity models.Identity
+	raw, err := bson.Marshal(bson.M{"call_sign": callSign, "name": name, "attributes": bson.A{attribute}})
+	if err != nil {
+		return identity, wrapError("qsoIdentity", err)
+	}
+	return identity, wrapError("qsoIdentity", bson.Unmarshal(raw, &identity))
+}
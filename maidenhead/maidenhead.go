@@ -0,0 +1,172 @@
+// Package maidenhead converts Maidenhead grid locators to and from
+// latitudes and longitudes and measures the distance and bearing between
+// two points on the earth.
+package maidenhead
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ErrInvalid is returned for a locator that is not a 4, 6 or 8 character
+// Maidenhead locator, or a point off the earth
+var ErrInvalid = errors.New("invalid grid locator")
+
+// EarthRadius is the mean radius of the earth in km
+const EarthRadius = 6371.0088
+
+// Point type is a position in degrees, north and east positive
+type Point struct {
+	Latitude  float64 `json:"latitude" bson:"latitude"`
+	Longitude float64 `json:"longitude" bson:"longitude"`
+}
+
+// Valid method reports whether the point is on the earth
+func (p Point) Valid() bool {
+	return p.Latitude >= -90 && p.Latitude <= 90 && p.Longitude >= -180 && p.Longitude <= 180
+}
+
+// String method
+func (p Point) String() string {
+	return fmt.Sprintf("%.5f,%.5f", p.Latitude, p.Longitude)
+}
+
+// pairs are the character pairs of a locator: the longitude step in
+// degrees, the latitude step is half of it, the number of values of each
+// character and the first value
+var pairs = []struct {
+	lon   float64
+	count int
+	first byte
+}{
+	{20, 18, 'A'},
+	{2, 10, '0'},
+	{2.0 / 24, 24, 'a'},
+	{2.0 / 240, 10, '0'},
+}
+
+// Normalize function returns locator in the usual case, JO22ab, or
+// ErrInvalid when it is not a 4, 6 or 8 character locator
+func Normalize(locator string) (string, error) {
+	s := strings.TrimSpace(locator)
+	if len(s) != 4 && len(s) != 6 && len(s) != 8 {
+		return "", fmt.Errorf("%w: %q", ErrInvalid, locator)
+	}
+
+	out := make([]byte, len(s))
+	for i := 0; i < len(s); i++ {
+		pair := pairs[i/2]
+		c := s[i]
+		switch {
+		case pair.first == 'A' && c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case pair.first == 'a' && c >= 'A' && c <= 'Z':
+			c += 'a' - 'A'
+		}
+		if c < pair.first || c >= pair.first+byte(pair.count) {
+			return "", fmt.Errorf("%w: %q", ErrInvalid, locator)
+		}
+		out[i] = c
+	}
+	return string(out), nil
+}
+
+// Valid function reports whether locator is a 4, 6 or 8 character locator
+func Valid(locator string) bool {
+	_, err := Normalize(locator)
+	return err == nil
+}
+
+// Bounds function returns the south west corner of locator and the center
+// of the square it covers
+func Bounds(locator string) (southWest, center Point, err error) {
+	s, err := Normalize(locator)
+	if err != nil {
+		return Point{}, Point{}, err
+	}
+
+	lon, lat := -180.0, -90.0
+	var size float64
+	for i := 0; i < len(s); i += 2 {
+		pair := pairs[i/2]
+		lon += float64(s[i]-pair.first) * pair.lon
+		lat += float64(s[i+1]-pair.first) * pair.lon / 2
+		size = pair.lon
+	}
+	southWest = Point{Latitude: lat, Longitude: lon}
+	center = Point{Latitude: lat + size/4, Longitude: lon + size/2}
+	return southWest, center, nil
+}
+
+// ToPoint function returns the center of the square of locator
+func ToPoint(locator string) (Point, error) {
+	_, center, err := Bounds(locator)
+	return center, err
+}
+
+// FromPoint function returns the locator of length 4, 6 or 8 of the square
+// holding p
+func FromPoint(p Point, length int) (string, error) {
+	if !p.Valid() || (length != 4 && length != 6 && length != 8) {
+		return "", fmt.Errorf("%w: %s in %d characters", ErrInvalid, p, length)
+	}
+
+	// the north pole and the antimeridian belong to the last square
+	lon := math.Min(p.Longitude+180, 360-1e-9)
+	lat := math.Min(p.Latitude+90, 180-1e-9)
+
+	out := make([]byte, length)
+	for i := 0; i < length; i += 2 {
+		pair := pairs[i/2]
+		x := int(lon / pair.lon)
+		y := int(lat / (pair.lon / 2))
+		out[i] = pair.first + byte(x)
+		out[i+1] = pair.first + byte(y)
+		lon -= float64(x) * pair.lon
+		lat -= float64(y) * pair.lon / 2
+	}
+	return string(out), nil
+}
+
+// radians function
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// Distance function returns the great circle distance from a to b in km
+func Distance(a, b Point) float64 {
+	lat1, lat2 := radians(a.Latitude), radians(b.Latitude)
+	dLat := lat2 - lat1
+	dLon := radians(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Bearing function returns the initial great circle bearing from a to b in
+// degrees clockwise from true north, 0 up to 360
+func Bearing(a, b Point) float64 {
+	lat1, lat2 := radians(a.Latitude), radians(b.Latitude)
+	dLon := radians(b.Longitude - a.Longitude)
+
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+	bearing := math.Atan2(y, x) * 180 / math.Pi
+	return math.Mod(bearing+360, 360)
+}
+
+// GridDistance function returns the distance in km and the bearing between
+// the centers of two locators
+func GridDistance(from, to string) (km, bearing float64, err error) {
+	a, err := ToPoint(from)
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := ToPoint(to)
+	if err != nil {
+		return 0, 0, err
+	}
+	return Distance(a, b), Bearing(a, b), nil
+}
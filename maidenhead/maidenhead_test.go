@@ -0,0 +1,97 @@
+package maidenhead
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{"jo22AB": "JO22ab", "oi33JT": "OI33jt", " OI33 ": "OI33", "OI33jx12": "OI33jx12"} {
+		if got, err := Normalize(in); err != nil || got != want {
+			t.Errorf("%q: got %q (%v), want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "JO2", "SA00", "JO22zz", "JO22ab1", "JO22abAB"} {
+		if Valid(in) {
+			t.Errorf("%q: want invalid", in)
+		}
+	}
+	if _, err := Normalize("JS22"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("got %v, want ErrInvalid", err)
+	}
+}
+
+func TestBounds(t *testing.T) {
+	southWest, center, err := Bounds("JO22")
+	if err != nil || southWest != (Point{Latitude: 52, Longitude: 4}) || center != (Point{Latitude: 52.5, Longitude: 5}) {
+		t.Fatalf("got %v %v (%v), want JO22 from 52,4", southWest, center, err)
+	}
+}
+
+func TestFromPoint(t *testing.T) {
+	for _, c := range []struct {
+		p      Point
+		length int
+		want   string
+	}{
+		{Point{Latitude: -6.2, Longitude: 106.8}, 6, "OI33jt"},
+		{Point{Latitude: 38.9, Longitude: -77.04}, 6, "FM18lv"},
+		{Point{Latitude: 52.5, Longitude: 5}, 4, "JO22"},
+		{Point{Latitude: 90, Longitude: 180}, 4, "RR99"},
+		{Point{Latitude: -90, Longitude: -180}, 8, "AA00aa00"},
+	} {
+		if got, err := FromPoint(c.p, c.length); err != nil || got != c.want {
+			t.Errorf("%v: got %q (%v), want %q", c.p, got, err, c.want)
+		}
+	}
+	if _, err := FromPoint(Point{Latitude: 91}, 4); !errors.Is(err, ErrInvalid) {
+		t.Errorf("got %v, want a point off the earth rejected", err)
+	}
+	if _, err := FromPoint(Point{}, 5); !errors.Is(err, ErrInvalid) {
+		t.Errorf("got %v, want a length of 5 rejected", err)
+	}
+}
+
+func TestGridDistance(t *testing.T) {
+	for _, c := range []struct {
+		from, to     string
+		km           float64
+		bearingRange [2]float64
+	}{
+		{"JJ00", "jj00", 0, [2]float64{0, 360}},
+		// Jakarta to Amsterdam
+		{"OI33", "JO22", 11400, [2]float64{300, 340}},
+	} {
+		km, bearing, err := GridDistance(c.from, c.to)
+		if err != nil || math.Abs(km-c.km) > 100 {
+			t.Errorf("%s to %s: got %g km (%v), want %g", c.from, c.to, km, err, c.km)
+		}
+		if bearing < c.bearingRange[0] || bearing > c.bearingRange[1] {
+			t.Errorf("%s to %s: got a bearing of %g, want %g to %g", c.from, c.to, bearing, c.bearingRange[0], c.bearingRange[1])
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	// a quarter of the equator
+	if quarter := Distance(Point{}, Point{Longitude: 90}); math.Abs(quarter-math.Pi*EarthRadius/2) > 1e-6 {
+		t.Fatalf("got %g km, want a quarter of the equator", quarter)
+	}
+}
+
+func TestBearing(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		from, to Point
+		want     float64
+	}{
+		{"north", Point{}, Point{Latitude: 10}, 0},
+		{"east", Point{}, Point{Longitude: 90}, 90},
+		{"south", Point{Latitude: 10}, Point{}, 180},
+	} {
+		if got := Bearing(c.from, c.to); got != c.want {
+			t.Errorf("%s: got %g, want %g", c.name, got, c.want)
+		}
+	}
+}
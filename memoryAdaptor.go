@@ -0,0 +1,520 @@
+package gomongo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MemoryAdaptor type keeps every collection in memory and implements Querier
+// with the filter, update and pipeline subset used by this package
+// ($elemMatch, $set, $inc, $setOnInsert, upsert, projection, sort, skip, limit …).
+// It is meant for unit tests of handlers, not for production data.
+type MemoryAdaptor struct {
+	mu          sync.Mutex
+	collections map[string][]bson.M
+	filters     *FilterParser
+	dupes       *DupeChecker
+}
+
+// NewMemoryAdaptor function
+func NewMemoryAdaptor() *MemoryAdaptor {
+	return &MemoryAdaptor{collections: map[string][]bson.M{}}
+}
+
+// memoryTxKey marks the context of a MemoryAdaptor transaction
+type memoryTxKey struct{}
+
+// lock locks the store unless ctx belongs to one of its transactions, which
+// already hold the lock, and returns the matching unlock
+func (m *MemoryAdaptor) lock(ctx context.Context) func() {
+	if ctx != nil && ctx.Value(memoryTxKey{}) == m {
+		return func() {}
+	}
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+// duplicateKeyError mimics the server error so mongo.IsDuplicateKeyError matches it
+func duplicateKeyError(collName string, id interface{}) error {
+	return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+		Code:    11000,
+		Message: fmt.Sprintf("E11000 duplicate key error collection: %s dup key: { _id: %v }", collName, id),
+	}}}
+}
+
+// decodeDocument decodes doc into result through bson
+func decodeDocument(doc bson.M, result interface{}) error {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, result)
+}
+
+// decodeDocuments decodes docs into result, a pointer to a slice
+func decodeDocuments(docs []bson.M, result interface{}) error {
+	sliceValue := reflect.ValueOf(result)
+	if sliceValue.Kind() != reflect.Ptr || sliceValue.Elem().Kind() != reflect.Slice {
+		return errors.New("result argument must be a pointer to a slice")
+	}
+	sliceValue = sliceValue.Elem()
+	elemType := sliceValue.Type().Elem()
+
+	decoded := reflect.MakeSlice(sliceValue.Type(), 0, len(docs))
+	for _, doc := range docs {
+		elem := reflect.New(elemType)
+		if err := decodeDocument(doc, elem.Interface()); err != nil {
+			return err
+		}
+		decoded = reflect.Append(decoded, elem.Elem())
+	}
+	sliceValue.Set(decoded)
+	return nil
+}
+
+// insert adds doc to the collection, the caller holds the lock
+func (m *MemoryAdaptor) insert(collName string, doc bson.M) (interface{}, error) {
+	if _, ok := doc["_id"]; !ok {
+		doc["_id"] = primitive.NewObjectID()
+	}
+	for _, existing := range m.collections[collName] {
+		if equalValues(existing["_id"], doc["_id"]) {
+			return nil, duplicateKeyError(collName, doc["_id"])
+		}
+	}
+	m.collections[collName] = append(m.collections[collName], doc)
+	return doc["_id"], nil
+}
+
+// find returns the documents matching filter in storage order with the
+// positional index of each match, the caller holds the lock
+func (m *MemoryAdaptor) find(collName string, filter interface{}) ([]bson.M, []int, error) {
+	query, err := toDoc(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var docs []bson.M
+	var positions []int
+	for _, doc := range m.collections[collName] {
+		st := newMatchState()
+		ok, err := matchDocument(doc, query, st)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			docs = append(docs, doc)
+			positions = append(positions, st.pos)
+		}
+	}
+	return docs, positions, nil
+}
+
+// findSorted applies sort, skip, limit and projection to the matching documents
+func (m *MemoryAdaptor) findSorted(collName string, filter, sortSpec interface{}, skip, limit *int64, projection interface{}) ([]bson.M, error) {
+	docs, _, err := m.find(collName, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := toOrdered(sortSpec)
+	if err != nil {
+		return nil, err
+	}
+	sortDocuments(docs, spec)
+
+	if skip != nil && *skip > 0 {
+		if *skip > int64(len(docs)) {
+			docs = nil
+		} else {
+			docs = docs[*skip:]
+		}
+	}
+	if limit != nil && *limit > 0 && *limit < int64(len(docs)) {
+		docs = docs[:*limit]
+	}
+
+	fields, err := toDoc(projection)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]bson.M, len(docs))
+	for i, doc := range docs {
+		if results[i], err = projectDocument(doc, fields); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// update applies update to the documents matching filter, the caller holds the lock
+func (m *MemoryAdaptor) update(collName string, filter, update interface{}, upsert, many bool) (*mongo.UpdateResult, bson.M, bson.M, error) {
+	query, err := toDoc(filter)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	updateDoc, err := toDoc(update)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if _, isOps := operatorDoc(updateDoc); !isOps {
+		return nil, nil, nil, errors.New("update document requires atomic operators")
+	}
+
+	docs, positions, err := m.find(collName, query)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	result := &mongo.UpdateResult{}
+	if len(docs) == 0 {
+		if !upsert {
+			return result, nil, nil, nil
+		}
+		doc := upsertDocument(query)
+		if err = applyUpdate(doc, updateDoc, -1, true); err != nil {
+			return nil, nil, nil, err
+		}
+		id, err := m.insert(collName, doc)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		result.UpsertedCount = 1
+		result.UpsertedID = id
+		return result, nil, doc, nil
+	}
+
+	if !many {
+		docs, positions = docs[:1], positions[:1]
+	}
+
+	var before bson.M
+	for i, doc := range docs {
+		if i == 0 {
+			before = deepCopy(doc).(bson.M)
+		}
+		original := deepCopy(doc).(bson.M)
+		if err = applyUpdate(doc, updateDoc, positions[i], false); err != nil {
+			return nil, nil, nil, err
+		}
+		result.MatchedCount++
+		if !equalValues(original, doc) {
+			result.ModifiedCount++
+		}
+	}
+	return result, before, docs[0], nil
+}
+
+// remove deletes the documents matching filter, the caller holds the lock
+func (m *MemoryAdaptor) remove(collName string, filter interface{}, many bool) (int64, error) {
+	query, err := toDoc(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	kept := m.collections[collName][:0]
+	for _, doc := range m.collections[collName] {
+		matched := false
+		if many || deleted == 0 {
+			if matched, err = matchDocument(doc, query, nil); err != nil {
+				return 0, err
+			}
+		}
+		if matched {
+			deleted++
+			continue
+		}
+		kept = append(kept, doc)
+	}
+	m.collections[collName] = kept
+	return deleted, nil
+}
+
+// QueryCreateCollection method
+func (m *MemoryAdaptor) QueryCreateCollection(ctx context.Context, collName string) error {
+	unlock := m.lock(ctx)
+	defer unlock()
+
+	if _, ok := m.collections[collName]; ok {
+		return wrapError("QueryCreateCollection", fmt.Errorf("collection %s already exists", collName))
+	}
+	m.collections[collName] = []bson.M{}
+	return nil
+}
+
+// QueryInsert method
+func (m *MemoryAdaptor) QueryInsert(ctx context.Context, collName string, byteQuery []byte) (interface{}, error) {
+	var query bson.M
+	if err := json.Unmarshal(byteQuery, &query); err != nil {
+		return nil, wrapError("QueryInsert", err)
+	}
+
+	doc, err := toDoc(query)
+	if err != nil {
+		return nil, wrapError("QueryInsert", err)
+	}
+
+	unlock := m.lock(ctx)
+	defer unlock()
+
+	id, err := m.insert(collName, doc)
+	if err != nil {
+		return nil, wrapError("QueryInsert", err)
+	}
+	return &mongo.InsertOneResult{InsertedID: id}, nil
+}
+
+// QueryInsertV3 method
+func (m *MemoryAdaptor) QueryInsertV3(ctx context.Context, collName string, query interface{}) (*mongo.InsertOneResult, error) {
+	doc, err := toDoc(query)
+	if err != nil {
+		return nil, wrapError("QueryInsertV3", err)
+	}
+
+	unlock := m.lock(ctx)
+	defer unlock()
+
+	id, err := m.insert(collName, doc)
+	if err != nil {
+		return nil, wrapError("QueryInsertV3", err)
+	}
+	return &mongo.InsertOneResult{InsertedID: id}, nil
+}
+
+// QueryInsertMany method
+func (m *MemoryAdaptor) QueryInsertMany(ctx context.Context, collName string, documents []interface{}) (*mongo.InsertManyResult, error) {
+	docs := make([]bson.M, len(documents))
+	for i, document := range documents {
+		doc, err := toDoc(document)
+		if err != nil {
+			return nil, wrapError("QueryInsertMany", err)
+		}
+		docs[i] = doc
+	}
+
+	unlock := m.lock(ctx)
+	defer unlock()
+
+	result := &mongo.InsertManyResult{}
+	for _, doc := range docs {
+		id, err := m.insert(collName, doc)
+		if err != nil {
+			return result, wrapError("QueryInsertMany", err)
+		}
+		result.InsertedIDs = append(result.InsertedIDs, id)
+	}
+	return result, nil
+}
+
+// QueryFind method
+func (m *MemoryAdaptor) QueryFind(ctx context.Context, collName string, byteQuery []byte) ([]byte, error) {
+	query, err := m.filterParser().Parse(collName, byteQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := m.lock(ctx)
+	docs, err := m.findSorted(collName, query, nil, nil, nil, nil)
+	unlock()
+	if err != nil {
+		return nil, wrapError("QueryFind", err)
+	}
+	if len(docs) == 0 {
+		return nil, wrapError("QueryFind", mongo.ErrNoDocuments)
+	}
+
+	var received bson.M
+	if err = decodeDocument(docs[0], &received); err != nil {
+		return nil, wrapError("QueryFind", err)
+	}
+
+	jsonBytes, err := json.Marshal(&received)
+	return jsonBytes, wrapError("QueryFind", err)
+}
+
+// QueryFindV2 method
+func (m *MemoryAdaptor) QueryFindV2(ctx context.Context, collName string, findOneOptions *options.FindOneOptions, query interface{}, result interface{}) error {
+	if findOneOptions == nil {
+		findOneOptions = options.FindOne()
+	}
+
+	unlock := m.lock(ctx)
+	docs, err := m.findSorted(collName, query, findOneOptions.Sort, findOneOptions.Skip, nil, findOneOptions.Projection)
+	unlock()
+	if err != nil {
+		return wrapError("QueryFindV2", err)
+	}
+
+	if len(docs) == 0 {
+		return wrapError("QueryFindV2", mongo.ErrNoDocuments)
+	}
+	return wrapError("QueryFindV2", decodeDocument(docs[0], result))
+}
+
+// QueryFindMany method
+func (m *MemoryAdaptor) QueryFindMany(ctx context.Context, collName string, byteQuery []byte, findOptions *options.FindOptions) ([]byte, error) {
+	query, err := m.filterParser().Parse(collName, byteQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	var received []bson.M
+	if err := m.QueryFindManyV2(ctx, collName, findOptions, query, &received); err != nil {
+		return nil, err
+	}
+
+	results, err := json.Marshal(received)
+	return results, wrapError("QueryFindMany", err)
+}
+
+// QueryFindManyV2 method
+func (m *MemoryAdaptor) QueryFindManyV2(ctx context.Context, collName string, findOptions *options.FindOptions, query interface{}, result interface{}) error {
+	if findOptions == nil {
+		findOptions = options.Find()
+	}
+
+	unlock := m.lock(ctx)
+	docs, err := m.findSorted(collName, query, findOptions.Sort, findOptions.Skip, findOptions.Limit, findOptions.Projection)
+	unlock()
+	if err != nil {
+		return wrapError("QueryFindManyV2", err)
+	}
+
+	return wrapError("QueryFindManyV2", decodeDocuments(docs, result))
+}
+
+// QueryCount method
+func (m *MemoryAdaptor) QueryCount(ctx context.Context, collName string, query bson.M) (int64, error) {
+	unlock := m.lock(ctx)
+	defer unlock()
+
+	docs, _, err := m.find(collName, query)
+	if err != nil {
+		return 0, wrapError("QueryCount", err)
+	}
+	return int64(len(docs)), nil
+}
+
+// QueryUpdateOne method
+func (m *MemoryAdaptor) QueryUpdateOne(ctx context.Context, collName string, updateOpt *options.UpdateOptions, filterQuery bson.M, updateQuery bson.M, result *mongo.UpdateResult) error {
+	upsert := updateOpt != nil && updateOpt.Upsert != nil && *updateOpt.Upsert
+
+	unlock := m.lock(ctx)
+	defer unlock()
+
+	updateResult, _, _, err := m.update(collName, filterQuery, updateQuery, upsert, false)
+	if err != nil {
+		return wrapError("QueryUpdateOne", err)
+	}
+	if result != nil {
+		*result = *updateResult
+	}
+	return nil
+}
+
+// QueryUpdateMany method
+func (m *MemoryAdaptor) QueryUpdateMany(ctx context.Context, collName string, filterQuery bson.M, updateQuery bson.M) error {
+	unlock := m.lock(ctx)
+	defer unlock()
+
+	_, _, _, err := m.update(collName, filterQuery, updateQuery, false, true)
+	return wrapError("QueryUpdateMany", err)
+}
+
+// QueryFindAndUpdateV2 method
+func (m *MemoryAdaptor) QueryFindAndUpdateV2(ctx context.Context, collName string, findAndUpdateOpt *options.FindOneAndUpdateOptions, filterQuery interface{}, updateQuery interface{}, result interface{}) error {
+	if findAndUpdateOpt == nil {
+		findAndUpdateOpt = options.FindOneAndUpdate()
+	}
+	upsert := findAndUpdateOpt.Upsert != nil && *findAndUpdateOpt.Upsert
+	returnAfter := findAndUpdateOpt.ReturnDocument != nil && *findAndUpdateOpt.ReturnDocument == options.After
+
+	unlock := m.lock(ctx)
+	defer unlock()
+
+	// sort decides which document is updated
+	if findAndUpdateOpt.Sort != nil {
+		docs, err := m.findSorted(collName, filterQuery, findAndUpdateOpt.Sort, nil, nil, nil)
+		if err != nil {
+			return wrapError("QueryFindAndUpdateV2", err)
+		}
+		if len(docs) > 0 {
+			query, err := toDoc(filterQuery)
+			if err != nil {
+				return wrapError("QueryFindAndUpdateV2", err)
+			}
+			filterQuery = bson.M{"$and": bson.A{query, bson.M{"_id": docs[0]["_id"]}}}
+		}
+	}
+
+	_, before, after, err := m.update(collName, filterQuery, updateQuery, upsert, false)
+	if err != nil {
+		return wrapError("QueryFindAndUpdateV2", err)
+	}
+
+	doc := before
+	if returnAfter {
+		doc = after
+	}
+	if doc == nil {
+		return wrapError("QueryFindAndUpdateV2", mongo.ErrNoDocuments)
+	}
+
+	projection, err := toDoc(findAndUpdateOpt.Projection)
+	if err != nil {
+		return wrapError("QueryFindAndUpdateV2", err)
+	}
+	if doc, err = projectDocument(doc, projection); err != nil {
+		return wrapError("QueryFindAndUpdateV2", err)
+	}
+	return wrapError("QueryFindAndUpdateV2", decodeDocument(doc, result))
+}
+
+// QueryRemoveOne method
+func (m *MemoryAdaptor) QueryRemoveOne(ctx context.Context, collName string, queryFilter interface{}) (int64, error) {
+	unlock := m.lock(ctx)
+	defer unlock()
+
+	deleted, err := m.remove(collName, queryFilter, false)
+	return deleted, wrapError("QueryRemoveOne", err)
+}
+
+// QueryRemoveMany method
+func (m *MemoryAdaptor) QueryRemoveMany(ctx context.Context, collName string, queryFilter interface{}) (int64, error) {
+	unlock := m.lock(ctx)
+	defer unlock()
+
+	deleted, err := m.remove(collName, queryFilter, true)
+	return deleted, wrapError("QueryRemoveMany", err)
+}
+
+// QueryAggregate method
+func (m *MemoryAdaptor) QueryAggregate(ctx context.Context, collName string, aggregateOptions *options.AggregateOptions, pipeline interface{}, result interface{}) error {
+	stages, err := toStages(pipeline)
+	if err != nil {
+		return wrapError("QueryAggregate", err)
+	}
+
+	unlock := m.lock(ctx)
+	docs := make([]bson.M, len(m.collections[collName]))
+	for i, doc := range m.collections[collName] {
+		docs[i] = deepCopy(doc).(bson.M)
+	}
+	docs, err = m.runPipeline(docs, stages)
+	unlock()
+	if err != nil {
+		return wrapError("QueryAggregate", err)
+	}
+
+	return wrapError("QueryAggregate", decodeDocuments(docs, result))
+}
@@ -0,0 +1,129 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/agustadewa/gomongo/maidenhead"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ////////// GEO //////////
+
+// EnsureGeoIndex method, the memory backend needs no index for its geo queries
+func (m *MemoryAdaptor) EnsureGeoIndex(ctx context.Context) error {
+	return nil
+}
+
+// geoPoint reads a GeoJSON point or a legacy [longitude, latitude] pair
+func geoPoint(v interface{}) (maidenhead.Point, bool) {
+	if doc, isDoc := v.(bson.M); isDoc {
+		if doc["type"] != "Point" {
+			return maidenhead.Point{}, false
+		}
+		v = doc["coordinates"]
+	}
+	pair, isArray := v.(bson.A)
+	if !isArray || len(pair) != 2 {
+		return maidenhead.Point{}, false
+	}
+	lon, okLon := toFloat(pair[0])
+	lat, okLat := toFloat(pair[1])
+	return maidenhead.Point{Latitude: lat, Longitude: lon}, okLon && okLat
+}
+
+// geoRing reads a GeoJSON linear ring
+func geoRing(v interface{}) ([]maidenhead.Point, error) {
+	coordinates, isArray := v.(bson.A)
+	if !isArray || len(coordinates) < 4 {
+		return nil, errors.New("a polygon ring needs 4 positions or more")
+	}
+	ring := make([]maidenhead.Point, len(coordinates))
+	for i, position := range coordinates {
+		p, ok := geoPoint(position)
+		if !ok {
+			return nil, fmt.Errorf("bad polygon position %v", position)
+		}
+		ring[i] = p
+	}
+	return ring, nil
+}
+
+// inRing reports whether p is inside ring, the edges are taken as straight
+// lines of the longitude and latitude plane
+func inRing(ring []maidenhead.Point, p maidenhead.Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Latitude > p.Latitude) != (b.Latitude > p.Latitude) &&
+			p.Longitude < (b.Longitude-a.Longitude)*(p.Latitude-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// matchGeo runs the geo query operators on v: $near and $nearSphere with a
+// GeoJSON point and distances in meters, $geoWithin with a GeoJSON polygon
+// or $centerSphere. Unlike MongoDB, $near does not order the documents.
+func matchGeo(v interface{}, op string, arg interface{}) (bool, error) {
+	spec, ok := arg.(bson.M)
+	if !ok {
+		return false, fmt.Errorf("%s needs a document", op)
+	}
+	p, isPoint := geoPoint(v)
+
+	switch op {
+	case "$near", "$nearSphere":
+		center, ok := geoPoint(spec["$geometry"])
+		if !ok {
+			return false, fmt.Errorf("%s needs a GeoJSON point $geometry", op)
+		}
+		if !isPoint {
+			return false, nil
+		}
+		meters := maidenhead.Distance(center, p) * 1000
+		if max, ok := toFloat(spec["$maxDistance"]); ok && meters > max {
+			return false, nil
+		}
+		if min, ok := toFloat(spec["$minDistance"]); ok && meters < min {
+			return false, nil
+		}
+		return true, nil
+
+	case "$geoWithin":
+		if sphere, ok := spec["$centerSphere"].(bson.A); ok && len(sphere) == 2 {
+			center, okCenter := geoPoint(sphere[0])
+			radians, okRadians := toFloat(sphere[1])
+			if !okCenter || !okRadians {
+				return false, errors.New("$centerSphere needs [[longitude, latitude], radians]")
+			}
+			return isPoint && maidenhead.Distance(center, p) <= radians*maidenhead.EarthRadius, nil
+		}
+
+		geometry, _ := spec["$geometry"].(bson.M)
+		if geometry["type"] != "Polygon" {
+			return false, errors.New("$geoWithin needs a GeoJSON Polygon $geometry or $centerSphere")
+		}
+		rings, _ := geometry["coordinates"].(bson.A)
+		if len(rings) == 0 {
+			return false, errors.New("the polygon has no ring")
+		}
+		if !isPoint {
+			return false, nil
+		}
+		for i, coordinates := range rings {
+			ring, err := geoRing(coordinates)
+			if err != nil {
+				return false, err
+			}
+			// the first ring is the outline, the others are holes
+			if inRing(ring, p) != (i == 0) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("unsupported query operator %s", op)
+}
@@ -0,0 +1,1386 @@
+package gomongo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// matchState remembers the array index matched by a filter, used by the
+// positional "$" operator of updates
+type matchState struct {
+	pos int
+}
+
+func newMatchState() *matchState {
+	return &matchState{pos: -1}
+}
+
+func (st *matchState) setPos(i int) {
+	if st != nil && st.pos < 0 {
+		st.pos = i
+	}
+}
+
+// toDoc converts any document (bson.M, bson.D, struct …) into a normalized bson.M
+func toDoc(v interface{}) (bson.M, error) {
+	if v == nil {
+		return bson.M{}, nil
+	}
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.M{}
+	if err = bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return normalize(doc).(bson.M), nil
+}
+
+// toOrdered converts any document into a bson.D keeping the key order
+func toOrdered(v interface{}) (bson.D, error) {
+	if v == nil {
+		return bson.D{}, nil
+	}
+	if d, ok := v.(bson.D); ok {
+		return d, nil
+	}
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.D
+	return doc, bson.Unmarshal(raw, &doc)
+}
+
+// toStages converts a pipeline (mongo.Pipeline, bson.A, []bson.M …) into stages
+func toStages(pipeline interface{}) ([]bson.D, error) {
+	raw, err := bson.Marshal(bson.M{"pipeline": pipeline})
+	if err != nil {
+		return nil, err
+	}
+	var wrapper struct {
+		Pipeline []bson.D `bson:"pipeline"`
+	}
+	return wrapper.Pipeline, bson.Unmarshal(raw, &wrapper)
+}
+
+// normalize turns nested bson.D, primitive.M and []interface{} into bson.M and bson.A
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case bson.M:
+		for k, e := range t {
+			t[k] = normalize(e)
+		}
+		return t
+	case map[string]interface{}:
+		m := bson.M{}
+		for k, e := range t {
+			m[k] = normalize(e)
+		}
+		return m
+	case bson.D:
+		m := bson.M{}
+		for _, e := range t {
+			m[e.Key] = normalize(e.Value)
+		}
+		return m
+	case bson.A:
+		for i, e := range t {
+			t[i] = normalize(e)
+		}
+		return t
+	case []interface{}:
+		a := make(bson.A, len(t))
+		for i, e := range t {
+			a[i] = normalize(e)
+		}
+		return a
+	case int:
+		return int64(t)
+	}
+	return v
+}
+
+// deepCopy copies nested documents and arrays
+func deepCopy(v interface{}) interface{} {
+	switch t := v.(type) {
+	case bson.M:
+		m := make(bson.M, len(t))
+		for k, e := range t {
+			m[k] = deepCopy(e)
+		}
+		return m
+	case bson.A:
+		a := make(bson.A, len(t))
+		for i, e := range t {
+			a[i] = deepCopy(e)
+		}
+		return a
+	}
+	return v
+}
+
+// ////////// COMPARISON //////////
+
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return 1
+	case int32, int64, float64, int:
+		return 2
+	case string, primitive.Symbol:
+		return 3
+	case bson.M:
+		return 4
+	case bson.A:
+		return 5
+	case primitive.Binary:
+		return 6
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case primitive.DateTime, time.Time:
+		return 9
+	case primitive.Timestamp:
+		return 10
+	case primitive.Regex:
+		return 11
+	}
+	return 12
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case float64:
+		return int64(n), n == float64(int64(n))
+	}
+	return 0, false
+}
+
+func toMillis(v interface{}) int64 {
+	switch t := v.(type) {
+	case primitive.DateTime:
+		return int64(t)
+	case time.Time:
+		return t.UnixMilli()
+	}
+	return 0
+}
+
+func cmpInt(a, b int) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// compareValues orders two values using the MongoDB type order
+func compareValues(a, b interface{}) int {
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		return cmpInt(ra, rb)
+	}
+
+	switch ra {
+	case 1:
+		return 0
+	case 2:
+		fa, _ := toFloat(a)
+		fb, _ := toFloat(b)
+		if fa < fb {
+			return -1
+		}
+		if fa > fb {
+			return 1
+		}
+		return 0
+	case 3:
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	case 4:
+		if equalValues(a, b) {
+			return 0
+		}
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	case 5:
+		aa, ab := a.(bson.A), b.(bson.A)
+		for i := 0; i < len(aa) && i < len(ab); i++ {
+			if c := compareValues(aa[i], ab[i]); c != 0 {
+				return c
+			}
+		}
+		return cmpInt(len(aa), len(ab))
+	case 7:
+		oa, ob := a.(primitive.ObjectID), b.(primitive.ObjectID)
+		return bytes.Compare(oa[:], ob[:])
+	case 8:
+		ba, bb := a.(bool), b.(bool)
+		if ba == bb {
+			return 0
+		}
+		if !ba {
+			return -1
+		}
+		return 1
+	case 9:
+		ma, mb := toMillis(a), toMillis(b)
+		if ma < mb {
+			return -1
+		}
+		if ma > mb {
+			return 1
+		}
+		return 0
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func equalValues(a, b interface{}) bool {
+	if typeRank(a) != typeRank(b) {
+		return false
+	}
+	switch ta := a.(type) {
+	case bson.M:
+		tb := b.(bson.M)
+		if len(ta) != len(tb) {
+			return false
+		}
+		for k, va := range ta {
+			vb, ok := tb[k]
+			if !ok || !equalValues(va, vb) {
+				return false
+			}
+		}
+		return true
+	case bson.A:
+		tb := b.(bson.A)
+		if len(ta) != len(tb) {
+			return false
+		}
+		for i := range ta {
+			if !equalValues(ta[i], tb[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return compareValues(a, b) == 0
+}
+
+func truthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0
+	}
+	return true
+}
+
+// ////////// PATHS //////////
+
+// getPath returns the value stored at a dotted path without traversing arrays
+func getPath(doc bson.M, path string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		switch t := current.(type) {
+		case bson.M:
+			v, ok := t[part]
+			if !ok {
+				return nil, false
+			}
+			current = v
+		case bson.A:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(t) {
+				return nil, false
+			}
+			current = t[idx]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// pathValue resolves a dotted path the way aggregation field paths do,
+// traversing arrays of documents
+func pathValue(v interface{}, parts []string) (interface{}, bool) {
+	if len(parts) == 0 {
+		return v, true
+	}
+	switch t := v.(type) {
+	case bson.M:
+		child, ok := t[parts[0]]
+		if !ok {
+			return nil, false
+		}
+		return pathValue(child, parts[1:])
+	case bson.A:
+		values := bson.A{}
+		for _, e := range t {
+			if child, ok := pathValue(e, parts); ok {
+				values = append(values, child)
+			}
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+func setPath(doc bson.M, path string, value interface{}) error {
+	parts := strings.Split(path, ".")
+	var current interface{} = doc
+	for i, part := range parts {
+		last := i == len(parts)-1
+		switch t := current.(type) {
+		case bson.M:
+			if last {
+				t[part] = value
+				return nil
+			}
+			child, ok := t[part]
+			if !ok || child == nil {
+				child = bson.M{}
+				t[part] = child
+			}
+			current = child
+		case bson.A:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 {
+				return fmt.Errorf("cannot create field %q in array", part)
+			}
+			if idx >= len(t) {
+				return fmt.Errorf("array index %d out of range", idx)
+			}
+			if last {
+				t[idx] = value
+				return nil
+			}
+			current = t[idx]
+		default:
+			return fmt.Errorf("cannot create field %q in element %v", part, current)
+		}
+	}
+	return nil
+}
+
+func unsetPath(doc bson.M, path string) {
+	parts := strings.Split(path, ".")
+	parent, ok := getPath(doc, strings.Join(parts[:len(parts)-1], "."))
+	if len(parts) == 1 {
+		parent, ok = doc, true
+	}
+	if !ok {
+		return
+	}
+	switch t := parent.(type) {
+	case bson.M:
+		delete(t, parts[len(parts)-1])
+	case bson.A:
+		if idx, err := strconv.Atoi(parts[len(parts)-1]); err == nil && idx >= 0 && idx < len(t) {
+			t[idx] = nil
+		}
+	}
+}
+
+// ////////// FILTER //////////
+
+// operatorDoc returns cond as an operator document like {"$gt": 1}
+func operatorDoc(cond interface{}) (bson.M, bool) {
+	m, ok := cond.(bson.M)
+	if !ok || len(m) == 0 {
+		return nil, false
+	}
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+// matchDocument reports whether doc matches filter
+func matchDocument(doc bson.M, filter bson.M, st *matchState) (bool, error) {
+	for key, cond := range filter {
+		var ok bool
+		var err error
+
+		switch key {
+		case "$and", "$or", "$nor":
+			clauses, isArray := cond.(bson.A)
+			if !isArray {
+				return false, fmt.Errorf("%s needs an array", key)
+			}
+			ok = key == "$and" || key == "$nor"
+			for _, clause := range clauses {
+				sub, isDoc := clause.(bson.M)
+				if !isDoc {
+					return false, fmt.Errorf("%s entries must be documents", key)
+				}
+				matched, err := matchDocument(doc, sub, st)
+				if err != nil {
+					return false, err
+				}
+				if key == "$and" && !matched {
+					ok = false
+					break
+				}
+				if key == "$or" && matched {
+					ok = true
+					break
+				}
+				if key == "$nor" && matched {
+					ok = false
+					break
+				}
+			}
+		default:
+			if strings.HasPrefix(key, "$") {
+				return false, fmt.Errorf("unsupported query operator %s", key)
+			}
+			ok, err = matchField(doc, key, cond, st)
+		}
+
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchField(doc bson.M, path string, cond interface{}, st *matchState) (bool, error) {
+	parts := strings.Split(path, ".")
+
+	ops, isOps := operatorDoc(cond)
+	if !isOps {
+		return matchPath(doc, parts, "$eq", cond, st)
+	}
+
+	for op, arg := range ops {
+		var ok bool
+		var err error
+
+		switch op {
+		case "$ne":
+			ok, err = matchPath(doc, parts, "$eq", arg, nil)
+			ok = !ok
+		case "$nin":
+			ok, err = matchPath(doc, parts, "$in", arg, nil)
+			ok = !ok
+		case "$not":
+			ok, err = matchField(doc, path, arg, nil)
+			ok = !ok
+		case "$exists":
+			_, found := pathValue(doc, parts)
+			ok = found == truthy(arg)
+		case "$options":
+			continue
+		case "$regex":
+			options, _ := ops["$options"].(string)
+			ok, err = matchPath(doc, parts, "$regex", bson.A{arg, options}, st)
+		default:
+			ok, err = matchPath(doc, parts, op, arg, st)
+		}
+
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchPath(v interface{}, parts []string, op string, arg interface{}, st *matchState) (bool, error) {
+	if len(parts) == 0 {
+		return matchValue(v, op, arg, st)
+	}
+
+	switch t := v.(type) {
+	case bson.M:
+		child, ok := t[parts[0]]
+		if !ok {
+			return matchValue(nil, op, arg, nil)
+		}
+		return matchPath(child, parts[1:], op, arg, st)
+	case bson.A:
+		if idx, err := strconv.Atoi(parts[0]); err == nil {
+			if idx < 0 || idx >= len(t) {
+				return matchValue(nil, op, arg, nil)
+			}
+			return matchPath(t[idx], parts[1:], op, arg, st)
+		}
+		for i, e := range t {
+			if _, isDoc := e.(bson.M); !isDoc {
+				continue
+			}
+			ok, err := matchPath(e, parts, op, arg, nil)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				st.setPos(i)
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return matchValue(nil, op, arg, nil)
+}
+
+func matchValue(v interface{}, op string, arg interface{}, st *matchState) (bool, error) {
+	array, isArray := v.(bson.A)
+
+	// any element of an array matches the plain operators
+	anyElement := func(test func(e interface{}) bool) bool {
+		if test(v) {
+			return true
+		}
+		if isArray {
+			for i, e := range array {
+				if test(e) {
+					st.setPos(i)
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	switch op {
+	case "$eq":
+		if arg == nil {
+			return v == nil, nil
+		}
+		// a regular expression value matches like $regex, also inside $in
+		if re, isRegex := arg.(primitive.Regex); isRegex {
+			return matchValue(v, "$regex", bson.A{re, ""}, st)
+		}
+		return anyElement(func(e interface{}) bool { return equalValues(e, arg) }), nil
+
+	case "$gt", "$gte", "$lt", "$lte":
+		return anyElement(func(e interface{}) bool {
+			if e == nil || typeRank(e) != typeRank(arg) {
+				return false
+			}
+			c := compareValues(e, arg)
+			switch op {
+			case "$gt":
+				return c > 0
+			case "$gte":
+				return c >= 0
+			case "$lt":
+				return c < 0
+			}
+			return c <= 0
+		}), nil
+
+	case "$in":
+		candidates, ok := arg.(bson.A)
+		if !ok {
+			return false, errors.New("$in needs an array")
+		}
+		for _, candidate := range candidates {
+			if matched, _ := matchValue(v, "$eq", candidate, st); matched {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "$elemMatch":
+		if !isArray {
+			return false, nil
+		}
+		cond, ok := arg.(bson.M)
+		if !ok {
+			return false, errors.New("$elemMatch needs a document")
+		}
+		ops, isOps := operatorDoc(cond)
+		for i, e := range array {
+			var matched bool
+			var err error
+			if isOps {
+				matched, err = matchField(bson.M{"v": e}, "v", ops, nil)
+			} else if sub, isDoc := e.(bson.M); isDoc {
+				matched, err = matchDocument(sub, cond, nil)
+			}
+			if err != nil {
+				return false, err
+			}
+			if matched {
+				st.setPos(i)
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "$size":
+		size, ok := toInt64(arg)
+		return ok && isArray && int64(len(array)) == size, nil
+
+	case "$near", "$nearSphere", "$geoWithin":
+		return matchGeo(v, op, arg)
+
+	case "$regex":
+		pattern := arg.(bson.A)
+		re, err := compileRegex(pattern[0], pattern[1].(string))
+		if err != nil {
+			return false, err
+		}
+		return anyElement(func(e interface{}) bool {
+			s, isString := e.(string)
+			return isString && re.MatchString(s)
+		}), nil
+	}
+
+	return false, fmt.Errorf("unsupported query operator %s", op)
+}
+
+func compileRegex(pattern interface{}, options string) (*regexp.Regexp, error) {
+	var expr string
+	switch p := pattern.(type) {
+	case string:
+		expr = p
+	case primitive.Regex:
+		expr = p.Pattern
+		if options == "" {
+			options = p.Options
+		}
+	default:
+		return nil, errors.New("$regex needs a string")
+	}
+
+	var flags string
+	for _, o := range options {
+		if strings.ContainsRune("ims", o) {
+			flags += string(o)
+		}
+	}
+	if flags != "" {
+		expr = "(?" + flags + ")" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// ////////// UPDATE //////////
+
+// applyUpdate runs the update operators on doc, pos is the array index
+// used by the positional "$" operator
+func applyUpdate(doc bson.M, update bson.M, pos int, inserting bool) error {
+	for op, arg := range update {
+		fields, ok := arg.(bson.M)
+		if !ok {
+			return fmt.Errorf("%s needs a document", op)
+		}
+
+		for path, value := range fields {
+			if !strings.HasPrefix(op, "$") {
+				return fmt.Errorf("unsupported update operator %s", op)
+			}
+
+			resolved, err := positionalPath(path, pos)
+			if err != nil {
+				return err
+			}
+
+			switch op {
+			case "$set":
+				err = setPath(doc, resolved, deepCopy(value))
+			case "$setOnInsert":
+				if inserting {
+					err = setPath(doc, resolved, deepCopy(value))
+				}
+			case "$unset":
+				unsetPath(doc, resolved)
+			case "$inc":
+				current, found := getPath(doc, resolved)
+				if !found || current == nil {
+					err = setPath(doc, resolved, value)
+					break
+				}
+				var sum interface{}
+				sum, err = addNumbers(current, value)
+				if err == nil {
+					err = setPath(doc, resolved, sum)
+				}
+			case "$min", "$max":
+				// a missing field takes the value, else the lower or higher one stays
+				current, found := getPath(doc, resolved)
+				c := compareValues(value, current)
+				if !found || op == "$min" && c < 0 || op == "$max" && c > 0 {
+					err = setPath(doc, resolved, deepCopy(value))
+				}
+			case "$push", "$addToSet":
+				values := bson.A{value}
+				if each, isEach := operatorDoc(value); isEach {
+					if values, ok = each["$each"].(bson.A); !ok {
+						return fmt.Errorf("%s needs $each to be an array", op)
+					}
+				}
+				current, found := getPath(doc, resolved)
+				array, isArray := current.(bson.A)
+				if found && current != nil && !isArray {
+					return fmt.Errorf("%s on non array field %s", op, resolved)
+				}
+				for _, v := range values {
+					if op == "$addToSet" {
+						if exists, _ := matchValue(array, "$eq", v, nil); exists && len(array) > 0 {
+							continue
+						}
+					}
+					array = append(array, deepCopy(v))
+				}
+				err = setPath(doc, resolved, array)
+			default:
+				return fmt.Errorf("unsupported update operator %s", op)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func positionalPath(path string, pos int) (string, error) {
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		if part == "$" {
+			if pos < 0 {
+				return "", errors.New("the positional operator did not find the match needed from the query")
+			}
+			parts[i] = strconv.Itoa(pos)
+		}
+	}
+	return strings.Join(parts, "."), nil
+}
+
+func addNumbers(a, b interface{}) (interface{}, error) {
+	_, aInt32 := a.(int32)
+	_, bInt32 := b.(int32)
+	ia, aInt := toInt64(a)
+	ib, bInt := toInt64(b)
+	_, aFloat := a.(float64)
+	_, bFloat := b.(float64)
+
+	switch {
+	case aInt && bInt && !aFloat && !bFloat:
+		if aInt32 && bInt32 {
+			return int32(ia + ib), nil
+		}
+		return ia + ib, nil
+	default:
+		fa, okA := toFloat(a)
+		fb, okB := toFloat(b)
+		if !okA || !okB {
+			return nil, errors.New("cannot apply $inc to a value of non-numeric type")
+		}
+		return fa + fb, nil
+	}
+}
+
+// upsertDocument seeds a new document with the equality fields of filter
+func upsertDocument(filter bson.M) bson.M {
+	doc := bson.M{}
+	for key, cond := range filter {
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		if ops, isOps := operatorDoc(cond); isOps {
+			if eq, ok := ops["$eq"]; ok {
+				_ = setPath(doc, key, deepCopy(eq))
+			}
+			continue
+		}
+		_ = setPath(doc, key, deepCopy(cond))
+	}
+	return doc
+}
+
+// ////////// PROJECTION & SORT //////////
+
+func projectDocument(doc bson.M, projection bson.M) (bson.M, error) {
+	if len(projection) == 0 {
+		return doc, nil
+	}
+
+	inclusion := false
+	for key, value := range projection {
+		if key == "_id" {
+			continue
+		}
+		if _, isDoc := value.(bson.M); isDoc || truthy(value) {
+			inclusion = true
+		}
+		if s, isString := value.(string); isString && strings.HasPrefix(s, "$") {
+			inclusion = true
+		}
+	}
+
+	if !inclusion {
+		result := deepCopy(doc).(bson.M)
+		for key, value := range projection {
+			if !truthy(value) {
+				unsetPath(result, key)
+			}
+		}
+		return result, nil
+	}
+
+	result := bson.M{}
+	if idValue, ok := projection["_id"]; !ok || truthy(idValue) {
+		if id, found := doc["_id"]; found {
+			result["_id"] = id
+		}
+	}
+
+	for key, value := range projection {
+		if key == "_id" {
+			continue
+		}
+
+		if cond, isDoc := value.(bson.M); isDoc {
+			if elemMatch, ok := cond["$elemMatch"]; ok {
+				array, _ := doc[key].(bson.A)
+				for _, e := range array {
+					if matched, err := matchValue(bson.A{e}, "$elemMatch", elemMatch, nil); err != nil {
+						return nil, err
+					} else if matched {
+						result[key] = bson.A{deepCopy(e)}
+						break
+					}
+				}
+				continue
+			}
+			computed, err := evalExpression(doc, value)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = computed
+			continue
+		}
+
+		if s, isString := value.(string); isString && strings.HasPrefix(s, "$") {
+			computed, err := evalExpression(doc, value)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = computed
+			continue
+		}
+
+		if !truthy(value) {
+			continue
+		}
+		if found, ok := getPath(doc, key); ok {
+			if err := setPath(result, key, deepCopy(found)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func sortDocuments(docs []bson.M, spec bson.D) {
+	if len(spec) == 0 {
+		return
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, e := range spec {
+			direction, _ := toInt64(e.Value)
+			vi, _ := getPath(docs[i], e.Key)
+			vj, _ := getPath(docs[j], e.Key)
+			c := compareValues(vi, vj)
+			if c == 0 {
+				continue
+			}
+			if direction < 0 {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// ////////// AGGREGATION //////////
+
+// evalExpression evaluates the aggregation expression subset used by this package
+func evalExpression(doc bson.M, expr interface{}) (interface{}, error) {
+	switch t := expr.(type) {
+	case string:
+		if t == "$$ROOT" {
+			return doc, nil
+		}
+		if strings.HasPrefix(t, "$$ROOT.") {
+			v, _ := pathValue(doc, strings.Split(strings.TrimPrefix(t, "$$ROOT."), "."))
+			return v, nil
+		}
+		if strings.HasPrefix(t, "$") {
+			v, _ := pathValue(doc, strings.Split(strings.TrimPrefix(t, "$"), "."))
+			return v, nil
+		}
+		return t, nil
+
+	case bson.A:
+		values := make(bson.A, len(t))
+		for i, e := range t {
+			v, err := evalExpression(doc, e)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return values, nil
+
+	case bson.M:
+		if ops, isOps := operatorDoc(t); isOps && len(ops) == 1 {
+			for op, arg := range ops {
+				return evalOperator(doc, op, arg)
+			}
+		}
+		result := bson.M{}
+		for key, e := range t {
+			v, err := evalExpression(doc, e)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = v
+		}
+		return result, nil
+	}
+
+	return expr, nil
+}
+
+func evalOperator(doc bson.M, op string, arg interface{}) (interface{}, error) {
+	if op == "$literal" {
+		return arg, nil
+	}
+
+	value, err := evalExpression(doc, arg)
+	if err != nil {
+		return nil, err
+	}
+	args, _ := value.(bson.A)
+
+	switch op {
+	case "$mergeObjects":
+		if args == nil {
+			args = bson.A{value}
+		}
+		result := bson.M{}
+		for _, a := range args {
+			if m, ok := a.(bson.M); ok {
+				for k, v := range m {
+					result[k] = v
+				}
+			}
+		}
+		return result, nil
+	case "$trim", "$toUpper", "$toLower":
+		input := value
+		if m, ok := value.(bson.M); ok {
+			input = m["input"]
+		}
+		s, _ := input.(string)
+		switch op {
+		case "$trim":
+			return strings.TrimSpace(s), nil
+		case "$toUpper":
+			return strings.ToUpper(s), nil
+		}
+		return strings.ToLower(s), nil
+	case "$concat":
+		var b strings.Builder
+		for _, a := range args {
+			if a == nil {
+				return nil, nil
+			}
+			b.WriteString(fmt.Sprint(a))
+		}
+		return b.String(), nil
+	case "$ifNull":
+		for _, a := range args {
+			if a != nil {
+				return a, nil
+			}
+		}
+		return nil, nil
+	case "$size":
+		if array, ok := value.(bson.A); ok && len(array) == 1 {
+			if inner, isArray := array[0].(bson.A); isArray {
+				return int32(len(inner)), nil
+			}
+		}
+		if array, ok := value.(bson.A); ok {
+			return int32(len(array)), nil
+		}
+		return nil, errors.New("$size needs an array")
+	case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s needs two arguments", op)
+		}
+		c := compareValues(args[0], args[1])
+		switch op {
+		case "$eq":
+			return c == 0, nil
+		case "$ne":
+			return c != 0, nil
+		case "$gt":
+			return c > 0, nil
+		case "$gte":
+			return c >= 0, nil
+		case "$lt":
+			return c < 0, nil
+		}
+		return c <= 0, nil
+	case "$cond":
+		var condition, then, otherwise interface{}
+		if m, ok := value.(bson.M); ok {
+			condition, then, otherwise = m["if"], m["then"], m["else"]
+		} else if len(args) == 3 {
+			condition, then, otherwise = args[0], args[1], args[2]
+		}
+		if truthy(condition) {
+			return then, nil
+		}
+		return otherwise, nil
+	}
+
+	return nil, fmt.Errorf("unsupported expression operator %s", op)
+}
+
+// runPipeline runs the aggregation stage subset used by this package
+func (m *MemoryAdaptor) runPipeline(docs []bson.M, stages []bson.D) ([]bson.M, error) {
+	for _, stage := range stages {
+		if len(stage) != 1 {
+			return nil, errors.New("a pipeline stage must have exactly one field")
+		}
+		name, arg := stage[0].Key, stage[0].Value
+
+		var err error
+		switch name {
+		case "$match":
+			filter, _ := normalize(deepCopy(arg)).(bson.M)
+			var matched []bson.M
+			for _, doc := range docs {
+				ok, err := matchDocument(doc, filter, nil)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					matched = append(matched, doc)
+				}
+			}
+			docs = matched
+
+		case "$sort":
+			spec, err := toOrdered(arg)
+			if err != nil {
+				return nil, err
+			}
+			sortDocuments(docs, spec)
+
+		case "$skip", "$limit":
+			n, ok := toInt64(arg)
+			if !ok || n < 0 {
+				return nil, fmt.Errorf("%s needs a positive number", name)
+			}
+			if name == "$skip" {
+				if n > int64(len(docs)) {
+					n = int64(len(docs))
+				}
+				docs = docs[n:]
+			} else if n < int64(len(docs)) {
+				docs = docs[:n]
+			}
+
+		case "$project":
+			projection, _ := normalize(deepCopy(arg)).(bson.M)
+			for i, doc := range docs {
+				if docs[i], err = projectDocument(doc, projection); err != nil {
+					return nil, err
+				}
+			}
+
+		case "$addFields", "$set":
+			fields, _ := normalize(deepCopy(arg)).(bson.M)
+			for i, doc := range docs {
+				doc = deepCopy(doc).(bson.M)
+				for key, expr := range fields {
+					v, err := evalExpression(doc, expr)
+					if err != nil {
+						return nil, err
+					}
+					if err = setPath(doc, key, v); err != nil {
+						return nil, err
+					}
+				}
+				docs[i] = doc
+			}
+
+		case "$unwind":
+			path, indexField, preserve := "", "", false
+			switch u := normalize(deepCopy(arg)).(type) {
+			case string:
+				path = u
+			case bson.M:
+				path, _ = u["path"].(string)
+				indexField, _ = u["includeArrayIndex"].(string)
+				preserve = truthy(u["preserveNullAndEmptyArrays"])
+			}
+			path = strings.TrimPrefix(path, "$")
+
+			var unwound []bson.M
+			for _, doc := range docs {
+				value, found := getPath(doc, path)
+				array, isArray := value.(bson.A)
+				switch {
+				case isArray && len(array) > 0:
+					for i, e := range array {
+						copied := deepCopy(doc).(bson.M)
+						_ = setPath(copied, path, deepCopy(e))
+						if indexField != "" {
+							_ = setPath(copied, indexField, int64(i))
+						}
+						unwound = append(unwound, copied)
+					}
+				case found && value != nil && !isArray:
+					if indexField != "" {
+						doc = deepCopy(doc).(bson.M)
+						_ = setPath(doc, indexField, nil)
+					}
+					unwound = append(unwound, doc)
+				case preserve:
+					copied := deepCopy(doc).(bson.M)
+					if isArray {
+						unsetPath(copied, path)
+					}
+					if indexField != "" {
+						_ = setPath(copied, indexField, nil)
+					}
+					unwound = append(unwound, copied)
+				}
+			}
+			docs = unwound
+
+		case "$replaceRoot", "$replaceWith":
+			spec, _ := normalize(deepCopy(arg)).(bson.M)
+			expr := interface{}(spec)
+			if name == "$replaceRoot" {
+				expr = spec["newRoot"]
+			}
+			for i, doc := range docs {
+				root, err := evalExpression(doc, expr)
+				if err != nil {
+					return nil, err
+				}
+				newRoot, ok := root.(bson.M)
+				if !ok {
+					return nil, errors.New("newRoot must evaluate to a document")
+				}
+				docs[i] = newRoot
+			}
+
+		case "$count":
+			field, _ := arg.(string)
+			if len(docs) == 0 {
+				docs = nil
+				break
+			}
+			docs = []bson.M{{field: int32(len(docs))}}
+
+		case "$group":
+			spec, _ := normalize(deepCopy(arg)).(bson.M)
+			if docs, err = groupDocuments(docs, spec); err != nil {
+				return nil, err
+			}
+
+		case "$lookup":
+			spec, _ := normalize(deepCopy(arg)).(bson.M)
+			from, _ := spec["from"].(string)
+			localField, _ := spec["localField"].(string)
+			foreignField, _ := spec["foreignField"].(string)
+			as, _ := spec["as"].(string)
+			foreign := m.collections[from]
+			for i, doc := range docs {
+				local, _ := getPath(doc, localField)
+				joined := bson.A{}
+				for _, f := range foreign {
+					if ok, _ := matchField(f, foreignField, local, nil); ok {
+						joined = append(joined, deepCopy(f))
+					}
+				}
+				doc = deepCopy(doc).(bson.M)
+				doc[as] = joined
+				docs[i] = doc
+			}
+
+		case "$facet":
+			facets, ok := arg.(bson.D)
+			if !ok {
+				return nil, errors.New("$facet needs a document")
+			}
+			result := bson.M{}
+			for _, facet := range facets {
+				facetStages, err := toStages(facet.Value)
+				if err != nil {
+					return nil, err
+				}
+				facetDocs := make([]bson.M, len(docs))
+				for i, doc := range docs {
+					facetDocs[i] = deepCopy(doc).(bson.M)
+				}
+				if facetDocs, err = m.runPipeline(facetDocs, facetStages); err != nil {
+					return nil, err
+				}
+				values := bson.A{}
+				for _, doc := range facetDocs {
+					values = append(values, doc)
+				}
+				result[facet.Key] = values
+			}
+			docs = []bson.M{result}
+
+		default:
+			return nil, fmt.Errorf("unsupported pipeline stage %s", name)
+		}
+	}
+
+	return docs, nil
+}
+
+func groupDocuments(docs []bson.M, spec bson.M) ([]bson.M, error) {
+	type group struct {
+		doc    bson.M
+		counts map[string]int
+	}
+	var order []*group
+	groups := map[string]*group{}
+
+	for _, doc := range docs {
+		id, err := evalExpression(doc, spec["_id"])
+		if err != nil {
+			return nil, err
+		}
+		key := fmt.Sprintf("%#v", id)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{doc: bson.M{"_id": id}, counts: map[string]int{}}
+			groups[key] = g
+			order = append(order, g)
+		}
+
+		for field, acc := range spec {
+			if field == "_id" {
+				continue
+			}
+			accumulator, isOps := operatorDoc(acc)
+			if !isOps || len(accumulator) != 1 {
+				return nil, fmt.Errorf("the field %s must be an accumulator object", field)
+			}
+			for op, expr := range accumulator {
+				value, err := evalExpression(doc, expr)
+				if err != nil {
+					return nil, err
+				}
+				current, exists := g.doc[field]
+				switch op {
+				case "$sum", "$avg":
+					if _, isNumber := toFloat(value); !isNumber {
+						value = int32(0)
+					}
+					if !exists {
+						current = int32(0)
+					}
+					if g.doc[field], err = addNumbers(current, value); err != nil {
+						return nil, err
+					}
+					g.counts[field]++
+				case "$first":
+					if !exists {
+						g.doc[field] = value
+					}
+				case "$last":
+					g.doc[field] = value
+				case "$push", "$addToSet":
+					array, _ := current.(bson.A)
+					if op == "$addToSet" {
+						if found, _ := matchValue(array, "$eq", value, nil); found && len(array) > 0 {
+							g.doc[field] = array
+							break
+						}
+					}
+					g.doc[field] = append(array, value)
+				case "$min", "$max":
+					if value == nil {
+						break
+					}
+					c := compareValues(value, current)
+					if !exists || current == nil || (op == "$min" && c < 0) || (op == "$max" && c > 0) {
+						g.doc[field] = value
+					}
+				default:
+					return nil, fmt.Errorf("unsupported accumulator %s", op)
+				}
+			}
+		}
+	}
+
+	results := make([]bson.M, 0, len(order))
+	for _, g := range order {
+		for field, acc := range spec {
+			if accumulator, ok := acc.(bson.M); ok {
+				if _, isAvg := accumulator["$avg"]; isAvg && g.counts[field] > 0 {
+					sum, _ := toFloat(g.doc[field])
+					g.doc[field] = sum / float64(g.counts[field])
+				}
+			}
+		}
+		results = append(results, g.doc)
+	}
+	return results, nil
+}
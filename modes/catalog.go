@@ -0,0 +1,128 @@
+package modes
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Default registry holds the ADIF 3.1.4 modes and submodes and the aliases
+// of contest logs
+var Default = newDefault()
+
+// catalog is the ADIF 3.1.4 mode enumeration
+var catalog = []Mode{
+	{Name: "AM", Group: Phone},
+	{Name: "ARDOP", Group: Digital},
+	{Name: "ATV", Group: Image},
+	{Name: "CHIP", Group: Digital, Submodes: []string{"CHIP64", "CHIP128"}},
+	{Name: "CLO", Group: Digital},
+	{Name: "CONTESTI", Group: Digital},
+	{Name: "CW", Group: CW, Submodes: []string{"PCW"}},
+	{Name: "DIGITALVOICE", Group: Phone, Submodes: []string{"C4FM", "DMR", "DSTAR", "FREEDV", "M17"}},
+	{Name: "DOMINO", Group: Digital, Submodes: []string{"DOM-M", "DOM4", "DOM5", "DOM8", "DOM11", "DOM16", "DOM22", "DOM44", "DOM88", "DOMINOEX", "DOMINOF"}},
+	{Name: "DYNAMIC", Group: Digital, Submodes: []string{"VARA HF", "VARA SATELLITE", "VARA FM 1200", "VARA FM 9600"}},
+	{Name: "FAX", Group: Image},
+	{Name: "FM", Group: Phone},
+	{Name: "FSK441", Group: Digital},
+	{Name: "FT8", Group: Digital},
+	{Name: "HELL", Group: Digital, Submodes: []string{"FMHELL", "FSKHELL", "HELL80", "HELLX5", "HELLX9", "HFSK", "PSKHELL", "SLOWHELL"}},
+	{Name: "ISCAT", Group: Digital, Submodes: []string{"ISCAT-A", "ISCAT-B"}},
+	{Name: "JT4", Group: Digital, Submodes: []string{"JT4A", "JT4B", "JT4C", "JT4D", "JT4E", "JT4F", "JT4G"}},
+	{Name: "JT6M", Group: Digital},
+	{Name: "JT9", Group: Digital, Submodes: []string{"JT9-1", "JT9-2", "JT9-5", "JT9-10", "JT9-30", "JT9A", "JT9B", "JT9C", "JT9D", "JT9E", "JT9E FAST", "JT9F", "JT9F FAST", "JT9G", "JT9G FAST", "JT9H", "JT9H FAST"}},
+	{Name: "JT44", Group: Digital},
+	{Name: "JT65", Group: Digital, Submodes: []string{"JT65A", "JT65B", "JT65B2", "JT65C", "JT65C2"}},
+	{Name: "MFSK", Group: Digital, Submodes: []string{"FSQCALL", "FST4", "FST4W", "FT4", "JS8", "JTMS", "MFSK4", "MFSK8", "MFSK11", "MFSK16", "MFSK22", "MFSK31", "MFSK32", "MFSK64", "MFSK64L", "MFSK128", "MFSK128L", "Q65"}},
+	{Name: "MSK144", Group: Digital},
+	{Name: "MT63", Group: Digital},
+	{Name: "OLIVIA", Group: Digital, Submodes: []string{"OLIVIA 4/125", "OLIVIA 4/250", "OLIVIA 8/250", "OLIVIA 8/500", "OLIVIA 16/500", "OLIVIA 16/1000", "OLIVIA 32/1000"}},
+	{Name: "OPERA", Group: Digital, Submodes: []string{"OPERA-BEACON", "OPERA-QSO"}},
+	{Name: "PAC", Group: Digital, Submodes: []string{"PAC2", "PAC3", "PAC4"}},
+	{Name: "PAX", Group: Digital, Submodes: []string{"PAX2"}},
+	{Name: "PKT", Group: Digital},
+	{Name: "PSK", Group: Digital, Submodes: []string{"8PSK125", "8PSK125F", "8PSK125FL", "8PSK250", "8PSK250F", "8PSK250FL", "8PSK500", "8PSK500F", "8PSK1000", "8PSK1000F", "8PSK1200F", "FSK31", "PSK10", "PSK31", "PSK63", "PSK63F", "PSK63RC4", "PSK63RC5", "PSK63RC10", "PSK63RC20", "PSK63RC32", "PSK125", "PSK125C12", "PSK125R", "PSK125RC10", "PSK125RC12", "PSK125RC16", "PSK125RC4", "PSK125RC5", "PSK250", "PSK250C6", "PSK250R", "PSK250RC2", "PSK250RC3", "PSK250RC5", "PSK250RC6", "PSK250RC7", "PSK500", "PSK500C2", "PSK500C4", "PSK500R", "PSK500RC2", "PSK500RC3", "PSK500RC4", "PSK800C2", "PSK800RC2", "PSK1000", "PSK1000C2", "PSK1000R", "PSK1000RC2", "PSKAM10", "PSKAM31", "PSKAM50", "PSKFEC31", "QPSK31", "QPSK63", "QPSK125", "QPSK250", "QPSK500", "SIM31"}},
+	{Name: "PSK2K", Group: Digital},
+	{Name: "Q15", Group: Digital},
+	{Name: "QRA64", Group: Digital, Submodes: []string{"QRA64A", "QRA64B", "QRA64C", "QRA64D", "QRA64E"}},
+	{Name: "ROS", Group: Digital, Submodes: []string{"ROS-EME", "ROS-HF", "ROS-MF"}},
+	{Name: "RTTY", Group: Digital, Submodes: []string{"ASCI"}},
+	{Name: "RTTYM", Group: Digital},
+	{Name: "SSB", Group: Phone, Submodes: []string{"LSB", "USB"}},
+	{Name: "SSTV", Group: Image},
+	{Name: "T10", Group: Digital},
+	{Name: "THOR", Group: Digital, Submodes: []string{"THOR-M", "THOR4", "THOR5", "THOR8", "THOR11", "THOR16", "THOR22", "THOR25X4", "THOR50X1", "THOR50X2", "THOR100"}},
+	{Name: "THRB", Group: Digital, Submodes: []string{"THRBX", "THRBX1", "THRBX2", "THRBX4", "THROB1", "THROB2", "THROB4"}},
+	{Name: "TOR", Group: Digital, Submodes: []string{"AMTORFEC", "GTOR", "NAVTEX", "SITORB"}},
+	{Name: "V4", Group: Digital},
+	{Name: "VOI", Group: Phone},
+	{Name: "WINMOR", Group: Digital},
+	{Name: "WSPR", Group: Digital},
+
+	// DATA is not an ADIF mode, it is the unspecified digital mode of
+	// contest logs, Cabrillo DG
+	{Name: "DATA", Group: Digital},
+}
+
+// aliases are the spellings of contest logs and of operators
+var aliases = []struct {
+	alias, mode, submode string
+}{
+	{"PHONE", "SSB", ""},
+	{"PH", "SSB", ""},
+	{"RY", "RTTY", ""},
+	{"DG", "DATA", ""},
+	{"DIGI", "DATA", ""},
+	{"DIGITAL", "DATA", ""},
+	{"A1A", "CW", ""},
+	{"BPSK31", "PSK", "PSK31"},
+	{"BPSK63", "PSK", "PSK63"},
+	{"D-STAR", "DIGITALVOICE", "DSTAR"},
+	{"FUSION", "DIGITALVOICE", "C4FM"},
+	{"PACKET", "PKT", ""},
+}
+
+// newDefault returns a registry of catalog and aliases
+func newDefault() *Registry {
+	registry := NewRegistry()
+	for _, mode := range catalog {
+		registry.Register(mode)
+	}
+	for _, alias := range aliases {
+		if err := registry.Alias(alias.alias, alias.mode, alias.submode); err != nil {
+			panic(err)
+		}
+	}
+	return registry
+}
+
+// Normalize function, see Registry.Normalize of the Default registry
+func Normalize(spelling string) (mode, submode string, err error) {
+	return Default.Normalize(spelling)
+}
+
+// Canonical function, see Registry.Canonical of the Default registry
+func Canonical(spelling string) string {
+	return Default.Canonical(spelling)
+}
+
+// Specific function, see Registry.Specific of the Default registry
+func Specific(spelling string) (string, error) {
+	return Default.Specific(spelling)
+}
+
+// Label function, see Registry.Label of the Default registry
+func Label(spelling string) string {
+	return Default.Label(spelling)
+}
+
+// GroupOf function, see Registry.GroupOf of the Default registry
+func GroupOf(spelling string) (Group, error) {
+	return Default.GroupOf(spelling)
+}
+
+// Filter function, see Registry.Filter of the Default registry
+func Filter(spellings ...string) primitive.Regex {
+	return Default.Filter(spellings...)
+}
+
+// GroupFilter function, see Registry.GroupFilter of the Default registry
+func GroupFilter(group Group) primitive.Regex {
+	return Default.GroupFilter(group)
+}
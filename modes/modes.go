@@ -0,0 +1,208 @@
+// Package modes is the catalogue of the ADIF modes and submodes, with the
+// aliases found in logs and the groups contests score by.
+package modes
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Group type
+type Group string
+
+// Mode groups
+const (
+	CW      Group = "CW"
+	Phone   Group = "PHONE"
+	Digital Group = "DIGITAL"
+	Image   Group = "IMAGE"
+)
+
+// ErrUnknownMode is returned for a mode that is neither a mode, a submode nor an alias
+var ErrUnknownMode = errors.New("unknown mode")
+
+// Mode type is an ADIF mode with its submodes
+type Mode struct {
+	Name     string
+	Group    Group
+	Submodes []string
+}
+
+// Registry type resolves modes, submodes and aliases, case insensitively
+type Registry struct {
+	mu      sync.RWMutex
+	modes   map[string]Mode
+	lookup  map[string][2]string // spelling to mode and submode
+	spelled map[string][]string  // mode to every spelling resolving to it
+}
+
+// NewRegistry function returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		modes:   map[string]Mode{},
+		lookup:  map[string][2]string{},
+		spelled: map[string][]string{},
+	}
+}
+
+// Register method adds a mode and its submodes
+func (registry *Registry) Register(mode Mode) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	mode.Name = strings.ToUpper(mode.Name)
+	registry.modes[mode.Name] = mode
+	registry.add(mode.Name, mode.Name, "")
+	for _, submode := range mode.Submodes {
+		registry.add(submode, mode.Name, strings.ToUpper(submode))
+	}
+}
+
+// Alias method makes alias resolve to mode and submode, submode may be empty
+func (registry *Registry) Alias(alias, mode, submode string) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	mode = strings.ToUpper(mode)
+	if _, ok := registry.modes[mode]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownMode, mode)
+	}
+	registry.add(alias, mode, strings.ToUpper(submode))
+	return nil
+}
+
+// add records a spelling, the caller holds the lock
+func (registry *Registry) add(spelling, mode, submode string) {
+	key := strings.ToUpper(strings.TrimSpace(spelling))
+	registry.lookup[key] = [2]string{mode, submode}
+	registry.spelled[mode] = append(registry.spelled[mode], key)
+}
+
+// Normalize method returns the ADIF mode and submode of a mode written in
+// any case, as a submode or as an alias: "usb" is SSB and USB, "Phone" is SSB
+func (registry *Registry) Normalize(spelling string) (mode, submode string, err error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	found, ok := registry.lookup[strings.ToUpper(strings.TrimSpace(spelling))]
+	if !ok {
+		return "", "", fmt.Errorf("%w %q", ErrUnknownMode, spelling)
+	}
+	return found[0], found[1], nil
+}
+
+// Canonical method returns the ADIF mode of spelling, or spelling in upper
+// case when it is not known, for grouping without rejecting
+func (registry *Registry) Canonical(spelling string) string {
+	mode, _, err := registry.Normalize(spelling)
+	if err != nil {
+		return strings.ToUpper(strings.TrimSpace(spelling))
+	}
+	return mode
+}
+
+// Specific method returns the submode spelling resolves to, or its mode
+// when it has none: "usb" is USB, "ft4" is FT4 and "Phone" is SSB
+func (registry *Registry) Specific(spelling string) (string, error) {
+	mode, submode, err := registry.Normalize(spelling)
+	if err != nil || submode == "" {
+		return mode, err
+	}
+	return submode, nil
+}
+
+// Label method returns the submode spelling resolves to, or spelling as it
+// is, for printing a stored mode the way it was logged
+func (registry *Registry) Label(spelling string) string {
+	if _, submode, err := registry.Normalize(spelling); err == nil && submode != "" {
+		return submode
+	}
+	return spelling
+}
+
+// Mode method returns the mode spelling resolves to
+func (registry *Registry) Mode(spelling string) (Mode, error) {
+	name, _, err := registry.Normalize(spelling)
+	if err != nil {
+		return Mode{}, err
+	}
+
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return registry.modes[name], nil
+}
+
+// GroupOf method returns the group of the mode spelling resolves to
+func (registry *Registry) GroupOf(spelling string) (Group, error) {
+	mode, err := registry.Mode(spelling)
+	return mode.Group, err
+}
+
+// Spellings method returns every spelling resolving to mode, sorted
+func (registry *Registry) Spellings(mode string) []string {
+	name, _, err := registry.Normalize(mode)
+	if err != nil {
+		return nil
+	}
+
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	spellings := append([]string{}, registry.spelled[name]...)
+	sort.Strings(spellings)
+	return spellings
+}
+
+// InGroup method returns the modes of group, sorted
+func (registry *Registry) InGroup(group Group) []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	var names []string
+	for name, mode := range registry.modes {
+		if mode.Group == group {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Filter method returns a regular expression matching every spelling of the
+// modes spellings resolve to, in any case, so documents stored before modes
+// were normalized still match. An unknown mode matches itself only.
+func (registry *Registry) Filter(spellings ...string) primitive.Regex {
+	var all []string
+	for _, spelling := range spellings {
+		known := registry.Spellings(spelling)
+		if len(known) == 0 {
+			known = []string{strings.TrimSpace(spelling)}
+		}
+		all = append(all, known...)
+	}
+	return spellingsRegex(all)
+}
+
+// GroupFilter method returns a query condition matching every spelling of
+// every mode of group
+func (registry *Registry) GroupFilter(group Group) primitive.Regex {
+	var spellings []string
+	for _, name := range registry.InGroup(group) {
+		spellings = append(spellings, registry.Spellings(name)...)
+	}
+	return spellingsRegex(spellings)
+}
+
+// spellingsRegex matches any of spellings, whole and case insensitive
+func spellingsRegex(spellings []string) primitive.Regex {
+	quoted := make([]string, len(spellings))
+	for i, spelling := range spellings {
+		quoted[i] = regexp.QuoteMeta(spelling)
+	}
+	return primitive.Regex{Pattern: "^\\s*(" + strings.Join(quoted, "|") + ")\\s*$", Options: "i"}
+}
@@ -0,0 +1,110 @@
+package modes
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, c := range []struct {
+		spelling, mode, submode string
+	}{
+		{"SSB", "SSB", ""},
+		{"usb", "SSB", "USB"},
+		{" Phone ", "SSB", ""},
+		{"PH", "SSB", ""},
+		{"ft4", "MFSK", "FT4"},
+		{"FT8", "FT8", ""},
+		{"BPSK31", "PSK", "PSK31"},
+		{"fusion", "DIGITALVOICE", "C4FM"},
+		{"DG", "DATA", ""},
+	} {
+		mode, submode, err := Normalize(c.spelling)
+		if err != nil || mode != c.mode || submode != c.submode {
+			t.Errorf("%q: got %q %q (%v), want %q %q", c.spelling, mode, submode, err, c.mode, c.submode)
+		}
+	}
+}
+
+func TestUnknownMode(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		err  func() error
+	}{
+		{"Normalize", func() error { _, _, err := Normalize("SMOKE"); return err }},
+		{"Specific", func() error { _, err := Specific("SMOKE"); return err }},
+		{"GroupOf", func() error { _, err := GroupOf("SMOKE"); return err }},
+	} {
+		if err := c.err(); !errors.Is(err, ErrUnknownMode) {
+			t.Errorf("%s: got %v, want ErrUnknownMode", c.name, err)
+		}
+	}
+	if got := Canonical("smoke"); got != "SMOKE" {
+		t.Errorf("got %q, want an unknown mode kept in upper case", got)
+	}
+}
+
+func TestSpecific(t *testing.T) {
+	for spelling, want := range map[string]string{"usb": "USB", "ft4": "FT4", "BPSK31": "PSK31", "Phone": "SSB", "dmr": "DMR", "cw": "CW"} {
+		if got, err := Specific(spelling); err != nil || got != want {
+			t.Errorf("%q: got %q (%v), want %q", spelling, got, err, want)
+		}
+	}
+}
+
+func TestLabel(t *testing.T) {
+	for spelling, want := range map[string]string{"usb": "USB", "FT4": "FT4", "SSB": "SSB", "Phone": "Phone", "smoke": "smoke"} {
+		if got := Label(spelling); got != want {
+			t.Errorf("%q: got label %q, want %q", spelling, got, want)
+		}
+	}
+}
+
+func TestGroupOf(t *testing.T) {
+	for spelling, want := range map[string]Group{"CW": CW, "LSB": Phone, "DMR": Phone, "FT8": Digital, "RY": Digital, "SSTV": Image} {
+		if got, err := GroupOf(spelling); err != nil || got != want {
+			t.Errorf("%q: got %q (%v), want %q", spelling, got, err, want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	filter := Filter("phone")
+	if filter.Options != "i" {
+		t.Fatalf("got options %q, want case insensitive", filter.Options)
+	}
+	for _, want := range []string{"SSB", "USB", "LSB", "PHONE", "PH"} {
+		if !contains(Default.Spellings("SSB"), want) {
+			t.Errorf("got %v, want %s among the spellings of SSB", Default.Spellings("SSB"), want)
+		}
+	}
+	if unknown := Filter("SMOKE"); unknown.Pattern != `^\s*(SMOKE)\s*$` {
+		t.Errorf("got %q, want an unknown mode matching itself", unknown.Pattern)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(Mode{Name: "ssb", Group: Phone, Submodes: []string{"USB"}})
+	if err := registry.Alias("SIDEBAND", "SSB", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Alias("WIRE", "CW", ""); !errors.Is(err, ErrUnknownMode) {
+		t.Fatalf("got %v, want an alias of an unregistered mode rejected", err)
+	}
+	if mode, _, err := registry.Normalize("sideband"); err != nil || mode != "SSB" {
+		t.Fatalf("got %q (%v), want SSB", mode, err)
+	}
+	if got := registry.InGroup(Phone); len(got) != 1 || got[0] != "SSB" {
+		t.Fatalf("got %v, want SSB", got)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,318 @@
+package gomongo
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PageInfo type describes one page of an offset pagination
+type PageInfo struct {
+	Total   int64 `json:"total"`
+	Limit   int64 `json:"limit"`
+	Pages   int64 `json:"pages"`
+	Current int64 `json:"current"`
+	HasNext bool  `json:"has_next"`
+	HasPrev bool  `json:"has_prev"`
+}
+
+// NewPageInfo function clamps docLimit and page, page starts at 1
+func NewPageInfo(docLimit, page, totalDoc int64) PageInfo {
+	if docLimit < 1 {
+		docLimit = 1
+	}
+
+	totalPage := totalDoc / docLimit
+	if totalDoc%docLimit != 0 {
+		totalPage++
+	}
+
+	if page > totalPage {
+		page = totalPage
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	return PageInfo{
+		Total:   totalDoc,
+		Limit:   docLimit,
+		Pages:   totalPage,
+		Current: page,
+		HasNext: page < totalPage,
+		HasPrev: page > 1,
+	}
+}
+
+// Skip method returns the number of documents before the page
+func (info PageInfo) Skip() int64 {
+	return (info.Current - 1) * info.Limit
+}
+
+// SortKey type is one key of a keyset sort, Order is 1 or -1
+type SortKey struct {
+	Key   string `json:"key"`
+	Order int    `json:"order"`
+}
+
+// ReportSortKeys function returns the Sorts of a report request as sort keys
+func ReportSortKeys(request models.TRequestCallSignReport) []SortKey {
+	keys := make([]SortKey, 0, len(request.Sorts))
+	for _, s := range request.Sorts {
+		keys = append(keys, SortKey{Key: s.Key, Order: int(s.Value.GetVal())})
+	}
+	return keys
+}
+
+// KeysetRequest type asks for the page after or before Token, an empty
+// Token asks for the first page
+type KeysetRequest struct {
+	Sort  []SortKey `json:"sort"`
+	Limit int64     `json:"limit"`
+	Token string    `json:"token"`
+}
+
+// KeysetPage type, Next and Prev are the tokens of the neighbour pages,
+// empty when there is none
+type KeysetPage[T any] struct {
+	Items   []T    `json:"items"`
+	Next    string `json:"next,omitempty"`
+	Prev    string `json:"prev,omitempty"`
+	HasNext bool   `json:"has_next"`
+	HasPrev bool   `json:"has_prev"`
+}
+
+// Keyset directions stored in a token
+const (
+	keysetNext = "next"
+	keysetPrev = "prev"
+)
+
+// keysetToken is the decoded continuation token, Sort guards against a token
+// reused with another sort
+type keysetToken struct {
+	Direction string `bson:"d"`
+	Sort      string `bson:"s"`
+	Values    bson.A `bson:"v"`
+}
+
+// keysetSort returns the sort keys with _id appended as tie breaker when
+// they do not hold it
+func keysetSort(keys []SortKey) []SortKey {
+	sortKeys := make([]SortKey, 0, len(keys)+1)
+	hasID := false
+	for _, key := range keys {
+		if key.Order != -1 {
+			key.Order = 1
+		}
+		sortKeys = append(sortKeys, key)
+		hasID = hasID || key.Key == "_id"
+	}
+	if hasID {
+		return sortKeys
+	}
+	return append(sortKeys, SortKey{Key: "_id", Order: 1})
+}
+
+// keysetSortName identifies a sort in a token
+func keysetSortName(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		if key.Order == -1 {
+			parts[i] = "-" + key.Key
+		} else {
+			parts[i] = key.Key
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// encodeKeysetToken returns the opaque token pointing after doc in direction
+func encodeKeysetToken(op, direction string, keys []SortKey, doc bson.M) (string, error) {
+	values := make(bson.A, len(keys))
+	for i, key := range keys {
+		values[i], _ = getPath(doc, key.Key)
+	}
+
+	data, err := bson.MarshalExtJSON(keysetToken{Direction: direction, Sort: keysetSortName(keys), Values: values}, true, false)
+	if err != nil {
+		return "", wrapError(op, err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeKeysetToken reverses encodeKeysetToken
+func decodeKeysetToken(op, token string, keys []SortKey) (keysetToken, error) {
+	var decoded keysetToken
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		err = bson.UnmarshalExtJSON(data, true, &decoded)
+	}
+	if err != nil {
+		return decoded, validationError(op, "malformed token: %v", err)
+	}
+
+	if decoded.Sort != keysetSortName(keys) || len(decoded.Values) != len(keys) {
+		return decoded, validationError(op, "token does not match the sort")
+	}
+	if decoded.Direction != keysetNext && decoded.Direction != keysetPrev {
+		return decoded, validationError(op, "token has an unknown direction")
+	}
+	return decoded, nil
+}
+
+// keysetFilter matches the documents strictly after values in the order of keys,
+// reversed flips every order
+func keysetFilter(keys []SortKey, values bson.A, reversed bool) bson.M {
+	clauses := make(bson.A, 0, len(keys))
+	for i, key := range keys {
+		clause := bson.M{}
+		for j := 0; j < i; j++ {
+			clause[keys[j].Key] = values[j]
+		}
+
+		operator := "$gt"
+		if (key.Order == -1) != reversed {
+			operator = "$lt"
+		}
+		clause[key.Key] = bson.M{operator: values[i]}
+		clauses = append(clauses, clause)
+	}
+	return bson.M{"$or": clauses}
+}
+
+// keysetQuery returns the sort keys of request, the direction it reads in,
+// the condition of the documents past its token, nil on the first page, and
+// the sort to read them in
+func keysetQuery(op string, request KeysetRequest) (keys []SortKey, direction string, after bson.M, sort bson.D, err error) {
+	if request.Limit < 1 {
+		return nil, "", nil, nil, validationError(op, "limit must be positive")
+	}
+
+	keys = keysetSort(request.Sort)
+	direction = keysetNext
+	if request.Token != "" {
+		token, err := decodeKeysetToken(op, request.Token, keys)
+		if err != nil {
+			return nil, "", nil, nil, err
+		}
+		direction = token.Direction
+		after = keysetFilter(keys, token.Values, direction == keysetPrev)
+	}
+
+	sort = make(bson.D, len(keys))
+	for i, key := range keys {
+		order := key.Order
+		if direction == keysetPrev {
+			order = -order
+		}
+		sort[i] = bson.E{Key: key.Key, Value: order}
+	}
+	return keys, direction, after, sort, nil
+}
+
+// keysetResult returns the page of docs, read in direction with one
+// document past the limit telling whether there is more
+func keysetResult[T any](op string, docs []bson.M, keys []SortKey, direction string, request KeysetRequest) (KeysetPage[T], error) {
+	page := KeysetPage[T]{Items: make([]T, 0)}
+
+	more := int64(len(docs)) > request.Limit
+	if more {
+		docs = docs[:request.Limit]
+	}
+	if direction == keysetPrev {
+		for i, j := 0, len(docs)-1; i < j; i, j = i+1, j-1 {
+			docs[i], docs[j] = docs[j], docs[i]
+		}
+		page.HasPrev, page.HasNext = more, true
+	} else {
+		page.HasNext, page.HasPrev = more, request.Token != ""
+	}
+
+	for _, doc := range docs {
+		var item T
+		if err := decodeDocument(doc, &item); err != nil {
+			return page, wrapError(op, err)
+		}
+		page.Items = append(page.Items, item)
+	}
+
+	if len(docs) == 0 {
+		return page, nil
+	}
+
+	var err error
+	if page.HasNext {
+		if page.Next, err = encodeKeysetToken(op, keysetNext, keys, docs[len(docs)-1]); err != nil {
+			return page, err
+		}
+	}
+	if page.HasPrev {
+		if page.Prev, err = encodeKeysetToken(op, keysetPrev, keys, docs[0]); err != nil {
+			return page, err
+		}
+	}
+	return page, nil
+}
+
+// FindKeyset function returns the page of collName documents matching filter
+// after or before the token of request. Unlike skip and limit it costs the
+// same on every page and does not shift when documents are inserted between
+// two loads. The sort keys should be present in every document.
+func FindKeyset[T any](ctx context.Context, store Querier, collName string, filter bson.M, request KeysetRequest) (KeysetPage[T], error) {
+	keys, direction, after, sort, err := keysetQuery("FindKeyset", request)
+	if err != nil {
+		return KeysetPage[T]{Items: make([]T, 0)}, err
+	}
+
+	query := filter
+	if query == nil {
+		query = bson.M{}
+	}
+	if after != nil {
+		if len(query) == 0 {
+			query = after
+		} else {
+			query = bson.M{"$and": bson.A{query, after}}
+		}
+	}
+
+	var docs []bson.M
+	opt := options.Find().SetSort(sort).SetLimit(request.Limit + 1)
+	if err := store.QueryFindManyV2(ctx, collName, opt, query, &docs); err != nil {
+		return KeysetPage[T]{Items: make([]T, 0)}, err
+	}
+	return keysetResult[T]("FindKeyset", docs, keys, direction, request)
+}
+
+// AggregateKeyset function returns the page of the documents pipeline
+// produces from collName after or before the token of request, see
+// FindKeyset. The sort and the limit are appended to pipeline, the documents
+// it produces must hold the sort keys and a unique _id.
+func AggregateKeyset[T any](ctx context.Context, store Querier, collName string, pipeline Pipeline, request KeysetRequest) (KeysetPage[T], error) {
+	keys, direction, after, sort, err := keysetQuery("AggregateKeyset", request)
+	if err != nil {
+		return KeysetPage[T]{Items: make([]T, 0)}, err
+	}
+
+	if after != nil {
+		pipeline = pipeline.Match(after)
+	}
+	pipeline = pipeline.Stage("$sort", sort).Limit(request.Limit + 1)
+
+	var docs []bson.M
+	if err := store.QueryAggregate(ctx, collName, options.Aggregate().SetAllowDiskUse(true), pipeline.Stages(), &docs); err != nil {
+		return KeysetPage[T]{Items: make([]T, 0)}, err
+	}
+	return keysetResult[T]("AggregateKeyset", docs, keys, direction, request)
+}
+
+// FindKeyset method, see FindKeyset function
+func (repo *Repository[T]) FindKeyset(ctx context.Context, filter bson.M, request KeysetRequest) (KeysetPage[T], error) {
+	return FindKeyset[T](ctx, repo.store, repo.collName, filter, request)
+}
@@ -0,0 +1,185 @@
+package gomongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Pipeline type builds an aggregation pipeline one stage at a time. Every
+// method returns a new Pipeline, so a base pipeline can be extended in
+// several ways without the branches seeing each other's stages.
+//
+//	pipeline := NewPipeline().
+//		Match(bson.M{"event_id": eventID}).
+//		Unwind("$attributes", true).
+//		Sort(SortKey{Key: "date", Order: -1}).
+//		Limit(100)
+type Pipeline struct {
+	stages mongo.Pipeline
+}
+
+// NewPipeline function, stages are added as they are
+func NewPipeline(stages ...bson.D) Pipeline {
+	return Pipeline{stages: append(mongo.Pipeline{}, stages...)}
+}
+
+// Stages method returns the stages to pass to the driver
+func (p Pipeline) Stages() mongo.Pipeline {
+	return append(mongo.Pipeline{}, p.stages...)
+}
+
+// Stage method appends a raw stage, for the stages without a constructor
+func (p Pipeline) Stage(name string, value interface{}) Pipeline {
+	stages := make(mongo.Pipeline, len(p.stages), len(p.stages)+1)
+	copy(stages, p.stages)
+	return Pipeline{stages: append(stages, bson.D{{Key: name, Value: value}})}
+}
+
+// Then method appends the stages of next
+func (p Pipeline) Then(next Pipeline) Pipeline {
+	stages := make(mongo.Pipeline, 0, len(p.stages)+len(next.stages))
+	stages = append(stages, p.stages...)
+	return Pipeline{stages: append(stages, next.stages...)}
+}
+
+// Match method appends a $match stage
+func (p Pipeline) Match(filter interface{}) Pipeline {
+	return p.Stage("$match", filter)
+}
+
+// Unwind method appends an $unwind stage, path starts with $
+func (p Pipeline) Unwind(path string, preserveNullAndEmptyArrays bool) Pipeline {
+	return p.Stage("$unwind", bson.D{
+		{Key: "path", Value: path},
+		{Key: "preserveNullAndEmptyArrays", Value: preserveNullAndEmptyArrays},
+	})
+}
+
+// UnwindIndex method appends an $unwind stage storing the array index of
+// every element in indexField, null for a document kept without one
+func (p Pipeline) UnwindIndex(path, indexField string, preserveNullAndEmptyArrays bool) Pipeline {
+	return p.Stage("$unwind", bson.D{
+		{Key: "path", Value: path},
+		{Key: "includeArrayIndex", Value: indexField},
+		{Key: "preserveNullAndEmptyArrays", Value: preserveNullAndEmptyArrays},
+	})
+}
+
+// ReplaceRoot method appends a $replaceRoot stage
+func (p Pipeline) ReplaceRoot(newRoot interface{}) Pipeline {
+	return p.Stage("$replaceRoot", bson.D{{Key: "newRoot", Value: newRoot}})
+}
+
+// Group method appends a $group stage, accumulators maps each output field
+// to an accumulator such as Sum or Push
+func (p Pipeline) Group(id interface{}, accumulators bson.D) Pipeline {
+	group := bson.D{{Key: "_id", Value: id}}
+	return p.Stage("$group", append(group, accumulators...))
+}
+
+// Lookup method appends a $lookup stage joining from on localField = foreignField
+func (p Pipeline) Lookup(from, localField, foreignField, as string) Pipeline {
+	return p.Stage("$lookup", bson.D{
+		{Key: "from", Value: from},
+		{Key: "localField", Value: localField},
+		{Key: "foreignField", Value: foreignField},
+		{Key: "as", Value: as},
+	})
+}
+
+// Facet type names the sub-pipelines of a $facet stage
+type Facet struct {
+	Name     string
+	Pipeline Pipeline
+}
+
+// Facet method appends a $facet stage running every facet on the same input
+func (p Pipeline) Facet(facets ...Facet) Pipeline {
+	spec := make(bson.D, 0, len(facets))
+	for _, facet := range facets {
+		spec = append(spec, bson.E{Key: facet.Name, Value: facet.Pipeline.Stages()})
+	}
+	return p.Stage("$facet", spec)
+}
+
+// Sort method appends a $sort stage, a sort without keys is left out
+// because the server rejects it
+func (p Pipeline) Sort(keys ...SortKey) Pipeline {
+	if len(keys) == 0 {
+		return p
+	}
+
+	sort := make(bson.D, 0, len(keys))
+	for _, key := range keys {
+		order := 1
+		if key.Order == -1 {
+			order = -1
+		}
+		sort = append(sort, bson.E{Key: key.Key, Value: order})
+	}
+	return p.Stage("$sort", sort)
+}
+
+// Skip method appends a $skip stage
+func (p Pipeline) Skip(n int64) Pipeline {
+	return p.Stage("$skip", n)
+}
+
+// Limit method appends a $limit stage
+func (p Pipeline) Limit(n int64) Pipeline {
+	return p.Stage("$limit", n)
+}
+
+// Project method appends a $project stage
+func (p Pipeline) Project(projection interface{}) Pipeline {
+	return p.Stage("$project", projection)
+}
+
+// Count method appends a $count stage writing the count to field
+func (p Pipeline) Count(field string) Pipeline {
+	return p.Stage("$count", field)
+}
+
+// Include function returns a projection keeping fields
+func Include(fields ...string) bson.D {
+	projection := make(bson.D, 0, len(fields))
+	for _, field := range fields {
+		projection = append(projection, bson.E{Key: field, Value: 1})
+	}
+	return projection
+}
+
+// MergeObjects expression
+func MergeObjects(documents ...interface{}) bson.M {
+	return bson.M{"$mergeObjects": bson.A(documents)}
+}
+
+// Trim expression
+func Trim(input interface{}) bson.M {
+	return bson.M{"$trim": bson.M{"input": input}}
+}
+
+// Sum accumulator
+func Sum(expression interface{}) bson.M {
+	return bson.M{"$sum": expression}
+}
+
+// Push accumulator
+func Push(expression interface{}) bson.M {
+	return bson.M{"$push": expression}
+}
+
+// First accumulator
+func First(expression interface{}) bson.M {
+	return bson.M{"$first": expression}
+}
+
+// Aggregate function runs pipeline on collName and decodes the results into T
+func Aggregate[T any](ctx context.Context, store Querier, collName string, pipeline Pipeline) ([]T, error) {
+	results := make([]T, 0)
+	err := store.QueryAggregate(ctx, collName, &options.AggregateOptions{}, pipeline.Stages(), &results)
+	return results, err
+}
@@ -0,0 +1,303 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/agustadewa/gomongo/bandplan"
+	"github.com/agustadewa/gomongo/callsign"
+	"github.com/agustadewa/gomongo/maidenhead"
+	"github.com/agustadewa/gomongo/modes"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultImportBatchSize is the number of call signs written per bulk write
+const defaultImportBatchSize = 500
+
+// QSO type is one contact read from a log file, it becomes one attribute of
+// the identity of CallSign
+type QSO struct {
+	CallSign  string
+	Name      string
+	Frequency string
+	Band      string
+	Mode      string
+	RST       string
+	Date      time.Time
+	// Grid is the Maidenhead locator of the station worked, optional
+	Grid string
+	// Station is the call sign of the event station that logged the QSO,
+	// optional
+	Station string
+	// LoggedCall is the call sign as logged, VK2/YB0AAA when CallSign is
+	// YB0AAA, set by ImportQSOs
+	LoggedCall string
+	// Line of the record in the log file, for error messages
+	Line int
+}
+
+// Validate method
+func (qso QSO) Validate() error {
+	switch {
+	case qso.CallSign == "":
+		return errors.New("call sign is missing")
+	case qso.Frequency == "" && qso.Band == "":
+		return errors.New("frequency and band are missing")
+	case qso.Mode == "":
+		return errors.New("mode is missing")
+	case qso.Date.IsZero():
+		return errors.New("date is missing")
+	}
+	return nil
+}
+
+// key identifies the QSO among the attributes of its identity
+func (qso QSO) key() string {
+	return attributeKey(qso.Frequency, qso.Mode, qso.dateMillis())
+}
+
+// dateMillis returns the date in the unix milliseconds string stored in attributes
+func (qso QSO) dateMillis() string {
+	return millisString(qso.Date)
+}
+
+// attribute returns the identity attribute of the QSO, with the GeoJSON
+// point of the center of its grid when it has one
+func (qso QSO) attribute() bson.M {
+	attribute := bson.M{
+		"frequency": qso.Frequency,
+		"band":      qso.Band,
+		"mode":      qso.Mode,
+		"rst":       qso.RST,
+		"date":      qso.dateMillis(),
+		"counter":   0,
+	}
+	if qso.LoggedCall != "" {
+		attribute["logged_call"] = qso.LoggedCall
+	}
+	if qso.Station != "" {
+		attribute["station"] = qso.Station
+	}
+	if grid, location, err := gridLocation(qso.Grid); err == nil {
+		attribute["grid"], attribute["location"] = grid, location
+	}
+	return attribute
+}
+
+// attributeKey identifies an attribute of an identity
+func attributeKey(frequency, mode, date string) string {
+	return frequency + "|" + modes.Canonical(mode) + "|" + date
+}
+
+// ImportOptions type
+type ImportOptions struct {
+	// DryRun reports what would be written without writing
+	DryRun bool
+	// BatchSize is the number of call signs per bulk write, 500 when 0
+	BatchSize int
+	// Region is the IARU region whose band plan the frequencies are checked against
+	Region bandplan.Region
+}
+
+// ImportResult type. Duplicates are QSOs already stored, skipped so a log
+// can be imported again, Dupes are QSOs repeating an earlier contact under
+// the dupe rule of the event, rejected, flagged or merged.
+type ImportResult struct {
+	Records       int     `json:"records"`
+	Imported      int     `json:"imported"`
+	Duplicates    int     `json:"duplicates"`
+	Dupes         int     `json:"dupes"`
+	NewIdentities int     `json:"new_identities"`
+	Errors        []error `json:"-"`
+	DryRun        bool    `json:"dry_run"`
+}
+
+// ImportError type is a record that could not be imported
+type ImportError struct {
+	Line     int
+	CallSign string
+	Err      error
+}
+
+// Error method
+func (e *ImportError) Error() string {
+	if e.CallSign == "" {
+		return "line " + strconv.Itoa(e.Line) + ": " + e.Err.Error()
+	}
+	return "line " + strconv.Itoa(e.Line) + ": " + e.CallSign + ": " + e.Err.Error()
+}
+
+// Unwrap method
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
+
+// ImportQSOs function adds qsos to the identities of eventID, creating the
+// identities that do not exist yet. Identities are keyed on the station call
+// sign, YB0AAA/P is YB0AAA, see callsign.Normalize, and every QSO keeps the
+// call as logged in logged_call, the DXCC entity is looked up by it.
+// Frequencies are stored in MHz and the band is derived from them, modes are
+// stored as ADIF submodes, or ADIF modes when they have none, see
+// modes.Specific, and grids as Maidenhead locators with the GeoJSON point of
+// their center, see GeoIndexKey. QSOs already in an identity, same frequency,
+// mode and date, are counted as duplicates and skipped, so a log can be
+// imported again after it grew. The dupe rule of the event, see DupeChecker,
+// applies to the QSOs left. Invalid and rejected QSOs end up in Errors and do
+// not stop the import.
+func ImportQSOs(ctx context.Context, store Querier, eventID string, qsos []QSO, opt ImportOptions) (ImportResult, error) {
+	result := ImportResult{Records: len(qsos), DryRun: opt.DryRun}
+	batchSize := opt.BatchSize
+	if batchSize < 1 {
+		batchSize = defaultImportBatchSize
+	}
+
+	rule := dupeRule(store, eventID)
+
+	// group the valid QSOs per call sign, in the order of the file
+	var callSigns []string
+	byCallSign := map[string][]QSO{}
+	for _, qso := range qsos {
+		err := qso.Validate()
+		if err == nil {
+			var call callsign.Call
+			if call, err = callsign.Parse(qso.CallSign); err == nil {
+				qso.CallSign, qso.LoggedCall = call.Base, call.String()
+			}
+		}
+		if err == nil {
+			qso.Frequency, qso.Band, err = bandplan.Normalize(qso.Frequency, qso.Band, opt.Region)
+		}
+		if err == nil {
+			qso.Mode, err = modes.Specific(qso.Mode)
+		}
+		if err == nil && qso.Station != "" {
+			qso.Station, err = callsign.Normalize(qso.Station)
+		}
+		if err == nil && qso.Grid != "" {
+			qso.Grid, err = maidenhead.Normalize(qso.Grid)
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, &ImportError{Line: qso.Line, CallSign: qso.CallSign, Err: err})
+			continue
+		}
+		if _, ok := byCallSign[qso.CallSign]; !ok {
+			callSigns = append(callSigns, qso.CallSign)
+		}
+		byCallSign[qso.CallSign] = append(byCallSign[qso.CallSign], qso)
+	}
+	// the dupe rule takes the QSOs of a call sign in date order, as ScanDupes
+	for _, callSign := range callSigns {
+		group := byCallSign[callSign]
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].Date.Before(group[j].Date)
+		})
+	}
+
+	for start := 0; start < len(callSigns); start += batchSize {
+		end := start + batchSize
+		if end > len(callSigns) {
+			end = len(callSigns)
+		}
+		if err := importBatch(ctx, store, eventID, callSigns[start:end], byCallSign, rule, opt.DryRun, &result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// importBatch writes the QSOs of callSigns with one bulk write
+func importBatch(ctx context.Context, store Querier, eventID string, callSigns []string, byCallSign map[string][]QSO, rule DupeRule, dryRun bool, result *ImportResult) error {
+	var existing []bson.M
+	err := store.QueryFindManyV2(
+		ctx,
+		models.CollIdentity,
+		options.Find().SetProjection(bson.M{"call_sign": 1, "attributes": 1}),
+		bson.M{"event_id": eventID, "call_sign": bson.M{"$in": callSigns}},
+		&existing)
+	if err != nil {
+		return err
+	}
+
+	known := map[string]map[string]bool{}
+	worked := map[string][]dupeContact{}
+	for _, identity := range existing {
+		callSign, _ := identity["call_sign"].(string)
+		keys := map[string]bool{}
+		attributes, _ := identity["attributes"].(bson.A)
+		for _, attribute := range attributes {
+			attributeDoc, _ := attribute.(bson.M)
+			frequency, _ := attributeDoc["frequency"].(string)
+			mode, _ := attributeDoc["mode"].(string)
+			date, _ := attributeDoc["date"].(string)
+			keys[attributeKey(frequency, mode, date)] = true
+			worked[callSign] = append(worked[callSign], attributeContact(attributeDoc))
+		}
+		known[callSign] = keys
+	}
+
+	writeModels := make([]mongo.WriteModel, 0, len(callSigns))
+	for _, callSign := range callSigns {
+		keys, exists := known[callSign]
+		if !exists {
+			keys = map[string]bool{}
+		}
+
+		name := ""
+		attributes := bson.A{}
+		for _, qso := range byCallSign[callSign] {
+			if name == "" {
+				name = qso.Name
+			}
+			if keys[qso.key()] {
+				result.Duplicates++
+				continue
+			}
+
+			attribute := qso.attribute()
+			contact := newDupeContact(qso.Frequency, qso.Band, qso.Mode, qso.Date)
+			if _, dupe := rule.first(worked[callSign], contact); dupe {
+				result.Dupes++
+				switch rule.Action {
+				case DupeReject:
+					result.Errors = append(result.Errors, &ImportError{Line: qso.Line, CallSign: callSign, Err: ErrDupe})
+					continue
+				case DupeMerge:
+					continue
+				}
+				attribute["dupe"], contact.flagged = true, true
+			}
+
+			keys[qso.key()] = true
+			worked[callSign] = append(worked[callSign], contact)
+			attributes = append(attributes, attribute)
+		}
+		if len(attributes) == 0 {
+			continue
+		}
+
+		result.Imported += len(attributes)
+		if !exists {
+			result.NewIdentities++
+		}
+
+		writeModels = append(writeModels, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"event_id": eventID, "call_sign": callSign}).
+			SetUpdate(bson.M{
+				"$setOnInsert": bson.M{"event_id": eventID, "call_sign": callSign, "name": name},
+				"$push":        bson.M{"attributes": bson.M{"$each": attributes}},
+			}).
+			SetUpsert(true))
+	}
+
+	if dryRun || len(writeModels) == 0 {
+		return nil
+	}
+	_, err = store.QueryBulkWrite(ctx, models.CollIdentity, writeModels, options.BulkWrite())
+	return err
+}
@@ -0,0 +1,106 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agustadewa/gomongo/adif"
+	"github.com/agustadewa/gomongo/modes"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestImportQSOsModes(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	date := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	stored := bson.M{"event_id": "event", "call_sign": "YB0AAA", "attributes": bson.A{
+		bson.M{"frequency": "7.135", "band": "40m", "mode": "usb", "date": millisString(date)},
+	}}
+	if _, err := store.QueryInsertV3(ctx, models.CollIdentity, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	qsos := []QSO{
+		{CallSign: "YB0AAA", Frequency: "7.135", Mode: "Phone", Date: date},
+		{CallSign: "YB0AAA", Frequency: "14.2", Mode: "ry", Date: date},
+		{CallSign: "YB0AAA", Frequency: "14.2", Mode: "SPARK", Date: date},
+		{CallSign: "YB0AAA", Frequency: "14.080", Mode: "ft4", Date: date},
+	}
+	result, err := ImportQSOs(ctx, store, "event", qsos, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// usb and Phone are one SSB QSO, SPARK is rejected
+	if result.Imported != 2 || result.Duplicates != 1 {
+		t.Errorf("got %+v, want 2 imported and 1 duplicate", result)
+	}
+	if len(result.Errors) != 1 || !errors.Is(result.Errors[0], modes.ErrUnknownMode) {
+		t.Errorf("got %v, want SPARK rejected", result.Errors)
+	}
+
+	for _, c := range []struct {
+		name  string
+		mode  interface{}
+		modes string
+	}{
+		{"phone group", modes.GroupFilter(modes.Phone), "usb RTTY FT4"},
+		{"FT4 as MFSK", modes.Filter("MFSK"), "usb RTTY FT4"},
+		{"RY as RTTY", modes.Filter("RTTY"), "usb RTTY FT4"},
+		{"no CW", modes.Filter("CW"), ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var identities []models.Identity
+			filter := bson.M{"attributes": bson.M{"$elemMatch": bson.M{"mode": c.mode}}}
+			if err := store.QueryFindManyV2(ctx, models.CollIdentity, nil, filter, &identities); err != nil {
+				t.Fatal(err)
+			}
+			var stored []string
+			for _, identity := range identities {
+				for _, attribute := range identity.Attributes {
+					stored = append(stored, attribute.Mode)
+				}
+			}
+			if got := strings.Join(stored, " "); got != c.modes {
+				t.Fatalf("got %q, want %q", got, c.modes)
+			}
+		})
+	}
+}
+
+func TestQSOFromADIFSubmode(t *testing.T) {
+	log, err := adif.Read(strings.NewReader("<CALL:6>YB0AAA <QSO_DATE:8>20260301 <MODE:4>MFSK <SUBMODE:3>FT4 <EOR>\n"), adif.ADI)
+	if err != nil || len(log.Records) != 1 {
+		t.Fatalf("got %+v (%v), want 1 record", log, err)
+	}
+	qso, err := QSOFromADIF(log.Records[0])
+	if err != nil || qso.Mode != "FT4" {
+		t.Fatalf("got %+v (%v), want the submode FT4", qso, err)
+	}
+}
+
+// workedQSOs returns the QSOs most tests start from, worked at date:
+// YB1BBB on 40m SSB, JA1AAA on 20m SSB and W1AW on 40m CW
+func workedQSOs(date time.Time) []QSO {
+	return []QSO{
+		{CallSign: "YB1BBB", Name: "Budi", Frequency: "7.135", Mode: "SSB", Date: date},
+		{CallSign: "JA1AAA", Frequency: "14.2", Mode: "SSB", Date: date},
+		{CallSign: "W1AW", Frequency: "7.02", Mode: "CW", Date: date},
+	}
+}
+
+// importQSOs imports qsos into eventID and fails t when one is not stored
+func importQSOs(t *testing.T, store Querier, eventID string, qsos ...QSO) {
+	t.Helper()
+	result, err := ImportQSOs(context.Background(), store, eventID, qsos, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors[0])
+	}
+}
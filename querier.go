@@ -0,0 +1,38 @@
+package gomongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Querier is the set of query methods shared by Adaptor and MemoryAdaptor,
+// handlers depending on it can be tested without a MongoDB server
+type Querier interface {
+	QueryCreateCollection(ctx context.Context, collName string) error
+	QueryInsert(ctx context.Context, collName string, byteQuery []byte) (interface{}, error)
+	QueryInsertV3(ctx context.Context, collName string, query interface{}) (*mongo.InsertOneResult, error)
+	QueryInsertMany(ctx context.Context, collName string, documents []interface{}) (*mongo.InsertManyResult, error)
+	QueryBulkWrite(ctx context.Context, collName string, writeModels []mongo.WriteModel, bulkWriteOptions *options.BulkWriteOptions) (*mongo.BulkWriteResult, error)
+	QueryFind(ctx context.Context, collName string, byteQuery []byte) ([]byte, error)
+	QueryFindV2(ctx context.Context, collName string, findOneOptions *options.FindOneOptions, query interface{}, result interface{}) error
+	QueryFindMany(ctx context.Context, collName string, byteQuery []byte, findOptions *options.FindOptions) ([]byte, error)
+	QueryFindManyV2(ctx context.Context, collName string, findOptions *options.FindOptions, query interface{}, result interface{}) error
+	QueryEach(ctx context.Context, collName string, findOptions *options.FindOptions, query interface{}, fn func(raw bson.Raw) error) error
+	QueryCount(ctx context.Context, collName string, query bson.M) (int64, error)
+	QueryUpdateOne(ctx context.Context, collName string, updateOpt *options.UpdateOptions, filterQuery bson.M, updateQuery bson.M, result *mongo.UpdateResult) error
+	QueryUpdateMany(ctx context.Context, collName string, filterQuery bson.M, updateQuery bson.M) error
+	QueryFindAndUpdateV2(ctx context.Context, collName string, findAndUpdateOpt *options.FindOneAndUpdateOptions, filterQuery interface{}, updateQuery interface{}, result interface{}) error
+	QueryRemoveOne(ctx context.Context, collName string, queryFilter interface{}) (int64, error)
+	QueryRemoveMany(ctx context.Context, collName string, queryFilter interface{}) (int64, error)
+	QueryAggregate(ctx context.Context, collName string, aggregateOptions *options.AggregateOptions, pipeline interface{}, result interface{}) error
+	QueryAggregateEach(ctx context.Context, collName string, aggregateOptions *options.AggregateOptions, pipeline interface{}, fn func(raw bson.Raw) error) error
+	WithTransaction(ctx context.Context, fn func(txCtx context.Context) error, opts ...*options.TransactionOptions) error
+}
+
+var (
+	_ Querier = (*Adaptor)(nil)
+	_ Querier = (*MemoryAdaptor)(nil)
+)
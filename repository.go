@@ -0,0 +1,152 @@
+package gomongo
+
+import (
+	"context"
+
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repository type is a typed view of one collection, every document of the
+// collection decodes into T
+type Repository[T any] struct {
+	store    Querier
+	collName string
+}
+
+// NewRepository function, store is an *Adaptor or a *MemoryAdaptor
+func NewRepository[T any](store Querier, collName string) *Repository[T] {
+	return &Repository[T]{store: store, collName: collName}
+}
+
+// Identities repository of models.CollIdentity
+func (adaptor *Adaptor) Identities() *Repository[models.Identity] {
+	return NewRepository[models.Identity](adaptor, models.CollIdentity)
+}
+
+// Events repository of models.CollEvent
+func (adaptor *Adaptor) Events() *Repository[models.Event] {
+	return NewRepository[models.Event](adaptor, models.CollEvent)
+}
+
+// DownloadLogs repository of models.CollCertificateDownloadLog
+func (adaptor *Adaptor) DownloadLogs() *Repository[models.DownloadLog] {
+	return NewRepository[models.DownloadLog](adaptor, models.CollCertificateDownloadLog)
+}
+
+// Identities repository of models.CollIdentity
+func (m *MemoryAdaptor) Identities() *Repository[models.Identity] {
+	return NewRepository[models.Identity](m, models.CollIdentity)
+}
+
+// Events repository of models.CollEvent
+func (m *MemoryAdaptor) Events() *Repository[models.Event] {
+	return NewRepository[models.Event](m, models.CollEvent)
+}
+
+// DownloadLogs repository of models.CollCertificateDownloadLog
+func (m *MemoryAdaptor) DownloadLogs() *Repository[models.DownloadLog] {
+	return NewRepository[models.DownloadLog](m, models.CollCertificateDownloadLog)
+}
+
+// CollName method
+func (repo *Repository[T]) CollName() string {
+	return repo.collName
+}
+
+// FindOne method
+func (repo *Repository[T]) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) (T, error) {
+	var result T
+	err := repo.store.QueryFindV2(ctx, repo.collName, options.MergeFindOneOptions(opts...), filter, &result)
+	return result, err
+}
+
+// FindMany method
+func (repo *Repository[T]) FindMany(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]T, error) {
+	results := make([]T, 0)
+	err := repo.store.QueryFindManyV2(ctx, repo.collName, options.MergeFindOptions(opts...), filter, &results)
+	return results, err
+}
+
+// Insert method returns the inserted _id
+func (repo *Repository[T]) Insert(ctx context.Context, document T) (interface{}, error) {
+	result, err := repo.store.QueryInsertV3(ctx, repo.collName, document)
+	if err != nil {
+		return nil, err
+	}
+	return result.InsertedID, nil
+}
+
+// InsertMany method returns the inserted _ids
+func (repo *Repository[T]) InsertMany(ctx context.Context, documents []T) ([]interface{}, error) {
+	if len(documents) == 0 {
+		return nil, nil
+	}
+
+	docs := make([]interface{}, len(documents))
+	for i := range documents {
+		docs[i] = documents[i]
+	}
+
+	result, err := repo.store.QueryInsertMany(ctx, repo.collName, docs)
+	if err != nil {
+		return nil, err
+	}
+	return result.InsertedIDs, nil
+}
+
+// Update method updates the first document matching filter
+func (repo *Repository[T]) Update(ctx context.Context, filter bson.M, update bson.M) (*mongo.UpdateResult, error) {
+	result := mongo.UpdateResult{}
+	err := repo.store.QueryUpdateOne(ctx, repo.collName, &options.UpdateOptions{}, filter, update, &result)
+	return &result, err
+}
+
+// Upsert method updates the first document matching filter or inserts it
+func (repo *Repository[T]) Upsert(ctx context.Context, filter bson.M, update bson.M) (*mongo.UpdateResult, error) {
+	result := mongo.UpdateResult{}
+	err := repo.store.QueryUpdateOne(ctx, repo.collName, options.Update().SetUpsert(true), filter, update, &result)
+	return &result, err
+}
+
+// FindAndUpdate method returns the document after the update
+func (repo *Repository[T]) FindAndUpdate(ctx context.Context, filter interface{}, update interface{}, upsert bool) (T, error) {
+	var result T
+	opt := options.FindOneAndUpdate().SetReturnDocument(options.After).SetUpsert(upsert)
+	err := repo.store.QueryFindAndUpdateV2(ctx, repo.collName, opt, filter, update, &result)
+	return result, err
+}
+
+// Delete method deletes the first document matching filter
+func (repo *Repository[T]) Delete(ctx context.Context, filter interface{}) (int64, error) {
+	return repo.store.QueryRemoveOne(ctx, repo.collName, filter)
+}
+
+// DeleteMany method
+func (repo *Repository[T]) DeleteMany(ctx context.Context, filter interface{}) (int64, error) {
+	return repo.store.QueryRemoveMany(ctx, repo.collName, filter)
+}
+
+// Count method
+func (repo *Repository[T]) Count(ctx context.Context, filter bson.M) (int64, error) {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	return repo.store.QueryCount(ctx, repo.collName, filter)
+}
+
+// Aggregate method decodes every result of the pipeline into T,
+// use AggregateAs when the pipeline reshapes the documents
+func (repo *Repository[T]) Aggregate(ctx context.Context, pipeline interface{}) ([]T, error) {
+	return AggregateAs[T](ctx, repo, pipeline)
+}
+
+// AggregateAs function runs pipeline on the repository collection and
+// decodes the results into R
+func AggregateAs[R any, T any](ctx context.Context, repo *Repository[T], pipeline interface{}) ([]R, error) {
+	results := make([]R, 0)
+	err := repo.store.QueryAggregate(ctx, repo.collName, &options.AggregateOptions{}, pipeline, &results)
+	return results, err
+}
@@ -0,0 +1,104 @@
+package gomongo
+
+import (
+	"context"
+	"testing"
+
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRepositoryAccessors(t *testing.T) {
+	store := NewMemoryAdaptor()
+	for _, c := range []struct {
+		got, want string
+	}{
+		{store.Identities().CollName(), models.CollIdentity},
+		{store.Events().CollName(), models.CollEvent},
+		{store.DownloadLogs().CollName(), models.CollCertificateDownloadLog},
+	} {
+		if c.got != c.want {
+			t.Errorf("got %s, want %s", c.got, c.want)
+		}
+	}
+}
+
+func TestRepositoryDecoding(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	identity := models.Identity{EventID: "event", CallSign: "YB0AAA", Name: "Budi", Attributes: []models.IdentityAttribute{{Frequency: "7.135", Band: "40m", Mode: "SSB"}}}
+	if _, err := store.Identities().Insert(ctx, identity); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Identities().FindOne(ctx, bson.M{"call_sign": "YB0AAA"})
+	if err != nil || got.Name != "Budi" || len(got.Attributes) != 1 || got.Attributes[0].Band != "40m" {
+		t.Fatalf("got %+v (%v), want the identity decoded", got, err)
+	}
+
+	if _, err := store.Events().Insert(ctx, models.Event{Name: "Field Day"}); err != nil {
+		t.Fatal(err)
+	}
+	events, err := store.Events().FindMany(ctx, bson.M{})
+	if err != nil || len(events) != 1 || events[0].Name != "Field Day" {
+		t.Fatalf("got %+v (%v), want the event decoded", events, err)
+	}
+
+	logs, err := store.DownloadLogs().FindMany(ctx, bson.M{"call_sign": "YB0AAA"})
+	if err != nil || logs == nil || len(logs) != 0 {
+		t.Fatalf("got %#v (%v), want an empty slice", logs, err)
+	}
+}
+
+func TestRepositoryInsertMany(t *testing.T) {
+	ctx := context.Background()
+	for _, c := range []struct {
+		name string
+		logs []models.DownloadLog
+		want int
+	}{
+		{"empty", nil, 0},
+		{"two", []models.DownloadLog{{CallSign: "YB0AAA"}, {CallSign: "JA1AAA"}}, 2},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			repo := NewMemoryAdaptor().DownloadLogs()
+			ids, err := repo.InsertMany(ctx, c.logs)
+			if err != nil || len(ids) != c.want {
+				t.Fatalf("got %v (%v), want %d ids", ids, err, c.want)
+			}
+			// Count takes a nil filter as every document
+			if n, err := repo.Count(ctx, nil); err != nil || n != int64(c.want) {
+				t.Fatalf("got %d (%v), want %d documents", n, err, c.want)
+			}
+		})
+	}
+}
+
+func TestRepositoryAggregateAs(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryAdaptor().Identities()
+	if _, err := repo.InsertMany(ctx, []models.Identity{
+		{EventID: "event", CallSign: "YB0AAA"},
+		{EventID: "event", CallSign: "JA1AAA"},
+		{EventID: "other", CallSign: "W1AW"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	type eventCount struct {
+		EventID string `bson:"_id"`
+		Count   int    `bson:"count"`
+	}
+	counts, err := AggregateAs[eventCount](ctx, repo, bson.A{
+		bson.M{"$group": bson.M{"_id": "$event_id", "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	})
+	if err != nil || len(counts) != 2 || counts[0] != (eventCount{"event", 2}) || counts[1] != (eventCount{"other", 1}) {
+		t.Fatalf("got %+v (%v), want 2 identities in event and 1 in other", counts, err)
+	}
+
+	identities, err := repo.Aggregate(ctx, bson.A{bson.M{"$match": bson.M{"event_id": "other"}}})
+	if err != nil || len(identities) != 1 || identities[0].CallSign != "W1AW" {
+		t.Fatalf("got %+v (%v), want W1AW", identities, err)
+	}
+}
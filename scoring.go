@@ -0,0 +1,396 @@
+package gomongo
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/agustadewa/gomongo/callsign"
+	"github.com/agustadewa/gomongo/modes"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollScoringRules collection holding the scoring rules of each event, keyed by event id
+const CollScoringRules = "scoring_rules"
+
+// PointLocation type is where the worked station is, seen from the home station
+type PointLocation string
+
+// Point locations
+const (
+	// LocationAny matches every station
+	LocationAny PointLocation = ""
+	// LocationEntity matches the stations in the DXCC entity of the home station
+	LocationEntity PointLocation = "entity"
+	// LocationContinent matches the other stations on the continent of the home station
+	LocationContinent PointLocation = "continent"
+	// LocationDX matches the stations on other continents
+	LocationDX PointLocation = "dx"
+)
+
+// PointRule type gives Points to the QSOs it matches, empty fields match
+// everything. Mode is a mode or a mode group, CW, PHONE or DIGITAL, and
+// Continent the continent of the worked station.
+type PointRule struct {
+	Band      string        `json:"band,omitempty" bson:"band,omitempty"`
+	Mode      string        `json:"mode,omitempty" bson:"mode,omitempty"`
+	Continent string        `json:"continent,omitempty" bson:"continent,omitempty"`
+	Location  PointLocation `json:"location,omitempty" bson:"location,omitempty"`
+	Points    int           `json:"points" bson:"points"`
+}
+
+// MultiplierKind type is what a QSO counts a multiplier for. The DXCC
+// entity, prefix and zones are the ones of the call sign the QSO was logged
+// as, the grid is the one of the station worked and Station is the event
+// station that logged the QSO.
+type MultiplierKind string
+
+// Multiplier kinds
+const (
+	MultDXCC    MultiplierKind = "dxcc"
+	MultPrefix  MultiplierKind = "prefix"
+	MultCQZone  MultiplierKind = "cq_zone"
+	MultITUZone MultiplierKind = "itu_zone"
+	MultGrid    MultiplierKind = "grid"
+	MultBand    MultiplierKind = "band"
+	MultStation MultiplierKind = "station"
+)
+
+// Multiplier type counts each distinct value of Kind once, once per band
+// when PerBand is set. A QSO whose value is unknown, a call sign missing
+// from the country file or a QSO without grid, counts for no multiplier and
+// is reported in Unknown.
+type Multiplier struct {
+	Kind    MultiplierKind `json:"kind" bson:"kind"`
+	PerBand bool           `json:"per_band" bson:"per_band"`
+}
+
+// ScoringRules type is how the QSOs of an event score. Each QSO gets the
+// points of the first rule of Points it matches, DefaultPoints when none
+// does. The score of a station is its points times the sum of its
+// multipliers, taken as 1 when there is none. QSOs the Dupes
+// rule finds, and the attributes flagged as dupes, score nothing.
+type ScoringRules struct {
+	EventID string `json:"event_id" bson:"event_id"`
+	// Home is the call sign of the event station, the reference of Location
+	Home          string       `json:"home" bson:"home"`
+	Points        []PointRule  `json:"points" bson:"points"`
+	DefaultPoints int          `json:"default_points" bson:"default_points"`
+	Multipliers   []Multiplier `json:"multipliers" bson:"multipliers"`
+	Dupes         DupeRule     `json:"dupes" bson:"dupes"`
+}
+
+// BandScore type is the part of a station score made on one band
+type BandScore struct {
+	Band        string `json:"band" bson:"band"`
+	QSOs        int    `json:"qsos" bson:"qsos"`
+	Dupes       int    `json:"dupes" bson:"dupes"`
+	Points      int    `json:"points" bson:"points"`
+	Multipliers int    `json:"multipliers" bson:"multipliers"`
+	Unknown     int    `json:"unknown" bson:"unknown"`
+}
+
+// StationScore type is the claimed score of one call sign of an event
+type StationScore struct {
+	Rank        int         `json:"rank" bson:"rank"`
+	CallSign    string      `json:"call_sign" bson:"call_sign"`
+	Entity      string      `json:"entity" bson:"entity"`
+	QSOs        int         `json:"qsos" bson:"qsos"`
+	Dupes       int         `json:"dupes" bson:"dupes"`
+	Points      int         `json:"points" bson:"points"`
+	Multipliers int         `json:"multipliers" bson:"multipliers"`
+	Unknown     int         `json:"unknown" bson:"unknown"`
+	Score       int         `json:"score" bson:"score"`
+	Bands       []BandScore `json:"bands" bson:"bands"`
+}
+
+// SaveScoringRules function stores the rules of rules.EventID
+func SaveScoringRules(ctx context.Context, store Querier, rules ScoringRules) error {
+	if rules.EventID == "" {
+		return validationError("SaveScoringRules", "the event id is missing")
+	}
+	opt := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var saved ScoringRules
+	return store.QueryFindAndUpdateV2(ctx, CollScoringRules, opt, bson.M{"_id": rules.EventID}, bson.M{"$set": rules}, &saved)
+}
+
+// LoadScoringRules function returns the rules of eventID, ErrNotFound when it has none
+func LoadScoringRules(ctx context.Context, store Querier, eventID string) (ScoringRules, error) {
+	var rules ScoringRules
+	err := store.QueryFindV2(ctx, CollScoringRules, &options.FindOneOptions{}, bson.M{"_id": eventID}, &rules)
+	return rules, err
+}
+
+// scoredQSO is one document of the scoring aggregation
+type scoredQSO struct {
+	CallSign   string `bson:"call_sign"`
+	LoggedCall string `bson:"logged_call"`
+	Station    string `bson:"station"`
+	Frequency  string `bson:"frequency"`
+	Band       string `bson:"band"`
+	Mode       string `bson:"mode"`
+	Date       string `bson:"date"`
+	Grid       string `bson:"grid"`
+	Dupe       bool   `bson:"dupe"`
+}
+
+// Leaderboard method scores eventID by its stored rules, see Leaderboard
+func (adaptor *Adaptor) Leaderboard(ctx context.Context, eventID string, limit int) ([]StationScore, error) {
+	return Leaderboard(ctx, adaptor, eventID, limit)
+}
+
+// Leaderboard function scores eventID by its stored rules with the
+// callsign.Default database and returns the limit best stations, every
+// station when limit is 0
+func Leaderboard(ctx context.Context, store Querier, eventID string, limit int) ([]StationScore, error) {
+	rules, err := LoadScoringRules(ctx, store, eventID)
+	if err != nil {
+		return nil, err
+	}
+	scores, err := ScoreEvent(ctx, store, eventID, rules, callsign.Default)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(scores) > limit {
+		scores = scores[:limit]
+	}
+	return scores, nil
+}
+
+// ScoreEvent function computes the claimed score of every call sign of
+// eventID under rules, best first, stations with the same score share their
+// rank. The QSOs are read from the store in call sign and date order one at a
+// time, the entities are resolved with db. The bands are added up here rather
+// than in a $group stage: the points and multipliers of a QSO depend on the
+// DXCC entity of its logged call, looked up in db, and on the dupe rule
+// applied in date order, neither of which the server can evaluate.
+func ScoreEvent(ctx context.Context, store Querier, eventID string, rules ScoringRules, db *callsign.DB) ([]StationScore, error) {
+	home, _ := db.Lookup(rules.Home)
+	dupes := rules.Dupes
+	if dupes.Action == "" {
+		dupes.Action = DupeAllow
+	}
+
+	pipeline := eventQSOPipeline(eventID, "").
+		Match(bson.M{"date": bson.M{"$exists": true}}).
+		Sort(SortKey{Key: "call_sign", Order: 1}, SortKey{Key: "date", Order: 1})
+
+	var scores []StationScore
+	var station *stationScorer
+	err := store.QueryAggregateEach(ctx, models.CollIdentity, options.Aggregate().SetAllowDiskUse(true), pipeline.Stages(), func(raw bson.Raw) error {
+		var qso scoredQSO
+		if err := bson.Unmarshal(raw, &qso); err != nil {
+			return wrapError("ScoreEvent", err)
+		}
+		if station == nil || station.score.CallSign != qso.CallSign {
+			if station != nil {
+				scores = append(scores, station.total())
+			}
+			station = newStationScorer(qso.CallSign, rules, dupes, home, db)
+		}
+		station.add(qso)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if station != nil {
+		scores = append(scores, station.total())
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
+	})
+	for i := range scores {
+		scores[i].Rank = i + 1
+		if i > 0 && scores[i].Score == scores[i-1].Score {
+			scores[i].Rank = scores[i-1].Rank
+		}
+	}
+	return scores, nil
+}
+
+// stationScorer adds up the QSOs of one call sign
+type stationScorer struct {
+	rules   ScoringRules
+	dupes   DupeRule
+	home    callsign.Entity
+	db      *callsign.DB
+	located map[string]location
+	score   StationScore
+	bands   map[string]*BandScore
+	order   []string
+	worked  []dupeContact
+	counted map[string]bool
+}
+
+// location is the call sign a QSO was logged as and its DXCC entity
+type location struct {
+	call   callsign.Call
+	entity callsign.Entity
+}
+
+// newStationScorer function
+func newStationScorer(callSign string, rules ScoringRules, dupes DupeRule, home callsign.Entity, db *callsign.DB) *stationScorer {
+	return &stationScorer{
+		rules:   rules,
+		dupes:   dupes,
+		home:    home,
+		db:      db,
+		located: map[string]location{},
+		score:   StationScore{CallSign: callSign},
+		bands:   map[string]*BandScore{},
+		counted: map[string]bool{},
+	}
+}
+
+// locate returns the location of the call sign a QSO was logged as, the
+// station call sign for the QSOs stored without one
+func (station *stationScorer) locate(loggedCall string) location {
+	if loggedCall == "" {
+		loggedCall = station.score.CallSign
+	}
+	at, ok := station.located[loggedCall]
+	if !ok {
+		at.call, _ = callsign.Parse(loggedCall)
+		at.entity, _ = station.db.LookupCall(at.call)
+		station.located[loggedCall] = at
+	}
+	return at
+}
+
+// add scores one QSO, the entity of the station is the one of its first QSO
+func (station *stationScorer) add(qso scoredQSO) {
+	millis, _ := strconv.ParseInt(qso.Date, 10, 64)
+	contact := newDupeContact(qso.Frequency, qso.Band, qso.Mode, timeFromMillis(millis))
+	at := station.locate(qso.LoggedCall)
+	if station.score.Entity == "" {
+		station.score.Entity = at.entity.Name
+	}
+
+	band, ok := station.bands[contact.band]
+	if !ok {
+		band = &BandScore{Band: contact.band}
+		station.bands[contact.band] = band
+		station.order = append(station.order, contact.band)
+	}
+	band.QSOs++
+
+	if _, dupe := station.dupes.first(station.worked, contact); dupe || qso.Dupe {
+		band.Dupes++
+		return
+	}
+	station.worked = append(station.worked, contact)
+	band.Points += station.points(contact, at)
+
+	for _, multiplier := range station.rules.Multipliers {
+		value := station.multiplierValue(multiplier.Kind, contact, qso, at)
+		if value == "" {
+			band.Unknown++
+			continue
+		}
+		key := string(multiplier.Kind) + "|" + value
+		if multiplier.PerBand {
+			key += "|" + contact.band
+		}
+		if !station.counted[key] {
+			station.counted[key] = true
+			band.Multipliers++
+		}
+	}
+}
+
+// points returns the points of a QSO by the first matching rule
+func (station *stationScorer) points(contact dupeContact, at location) int {
+	for _, rule := range station.rules.Points {
+		if station.matches(rule, contact, at) {
+			return rule.Points
+		}
+	}
+	return station.rules.DefaultPoints
+}
+
+// matches reports whether rule applies to contact logged at
+func (station *stationScorer) matches(rule PointRule, contact dupeContact, at location) bool {
+	if rule.Band != "" && !strings.EqualFold(rule.Band, contact.band) {
+		return false
+	}
+	if rule.Mode != "" {
+		group, _ := modes.GroupOf(contact.mode)
+		if modes.Canonical(rule.Mode) != contact.mode && !strings.EqualFold(rule.Mode, string(group)) {
+			return false
+		}
+	}
+	if rule.Continent != "" && !strings.EqualFold(rule.Continent, at.entity.Continent) {
+		return false
+	}
+
+	sameEntity := at.entity.Name != "" && at.entity.Name == station.home.Name
+	sameContinent := at.entity.Continent != "" && at.entity.Continent == station.home.Continent
+	switch rule.Location {
+	case LocationEntity:
+		return sameEntity
+	case LocationContinent:
+		return sameContinent && !sameEntity
+	case LocationDX:
+		return at.entity.Continent != "" && !sameContinent
+	}
+	return true
+}
+
+// multiplierValue returns the value a QSO counts for kind, "" for none
+func (station *stationScorer) multiplierValue(kind MultiplierKind, contact dupeContact, qso scoredQSO, at location) string {
+	switch kind {
+	case MultDXCC:
+		return at.entity.Name
+	case MultPrefix:
+		if at.call.Base == "" {
+			return ""
+		}
+		return at.call.WPX()
+	case MultCQZone:
+		if at.entity.CQZone == 0 {
+			return ""
+		}
+		return strconv.Itoa(at.entity.CQZone)
+	case MultITUZone:
+		if at.entity.ITUZone == 0 {
+			return ""
+		}
+		return strconv.Itoa(at.entity.ITUZone)
+	case MultGrid:
+		if len(qso.Grid) < 4 {
+			return ""
+		}
+		return strings.ToUpper(qso.Grid[:4])
+	case MultBand:
+		return contact.band
+	case MultStation:
+		return strings.ToUpper(qso.Station)
+	}
+	return ""
+}
+
+// total returns the score of the station with its bands in the order worked
+func (station *stationScorer) total() StationScore {
+	score := station.score
+	score.Bands = make([]BandScore, 0, len(station.order))
+	for _, name := range station.order {
+		band := *station.bands[name]
+		score.Bands = append(score.Bands, band)
+		score.QSOs += band.QSOs
+		score.Dupes += band.Dupes
+		score.Points += band.Points
+		score.Multipliers += band.Multipliers
+		score.Unknown += band.Unknown
+	}
+
+	score.Score = score.Points
+	if score.Multipliers > 1 {
+		score.Score = score.Points * score.Multipliers
+	}
+	return score
+}
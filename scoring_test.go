@@ -0,0 +1,142 @@
+package gomongo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agustadewa/gomongo/callsign"
+)
+
+func TestLeaderboard(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	date := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	importQSOs(t, store, "event", append(workedQSOs(date),
+		QSO{CallSign: "YB1BBB", Frequency: "7.02", Mode: "CW", Date: date.Add(time.Minute)},
+		QSO{CallSign: "YB1BBB", Frequency: "14.2", Mode: "SSB", Date: date.Add(2 * time.Minute)},
+		QSO{CallSign: "JA1AAA", Frequency: "14.21", Mode: "SSB", Date: date.Add(time.Minute)},
+	)...)
+
+	rules := ScoringRules{
+		EventID: "event",
+		Home:    "YB0AAA",
+		Points: []PointRule{
+			{Location: LocationEntity, Points: 1},
+			{Mode: "CW", Location: LocationDX, Points: 5},
+			{Location: LocationContinent, Points: 2},
+		},
+		DefaultPoints: 3,
+		Multipliers:   []Multiplier{{Kind: MultBand}},
+		Dupes:         DupeRule{ByMode: true, Action: DupeFlag},
+	}
+	if err := SaveScoringRules(ctx, store, rules); err != nil {
+		t.Fatal(err)
+	}
+
+	board, err := Leaderboard(ctx, store, "event", 0)
+	if err != nil || len(board) != 3 {
+		t.Fatalf("got %+v (%v), want 3 stations", board, err)
+	}
+	type rank struct {
+		callSign string
+		score    int
+		dupes    int
+		bands    string
+	}
+	for i, want := range []rank{
+		// 3 points on 2 bands
+		{"YB1BBB", 6, 0, "40m:2 20m:1"},
+		// 5 points for CW with another entity on 1 band
+		{"W1AW", 5, 0, "40m:5"},
+		// another continent, the second 20m SSB QSO is a dupe
+		{"JA1AAA", 3, 1, "20m:3"},
+	} {
+		if got := (rank{board[i].CallSign, board[i].Score, board[i].Dupes, bandPoints(board[i].Bands)}); got != want {
+			t.Errorf("rank %d: got %+v, want %+v", i+1, got, want)
+		}
+	}
+}
+
+// bandPoints returns the points of each band as "40m:2 20m:1"
+func bandPoints(bands []BandScore) string {
+	points := make([]string, len(bands))
+	for i, band := range bands {
+		points[i] = fmt.Sprintf("%s:%d", band.Band, band.Points)
+	}
+	return strings.Join(points, " ")
+}
+
+func TestScoreEventMultipliers(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	date := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	qsos := []QSO{
+		{CallSign: "YB1BBB", Station: "YB0XYZ", Frequency: "7.135", Mode: "SSB", Grid: "OI33", Date: date},
+		{CallSign: "YB1BBB", Station: "YB9XYZ/P", Frequency: "7.140", Mode: "SSB", Date: date.Add(time.Hour)},
+		{CallSign: "YB1BBB", Station: "YB0XYZ", Frequency: "14.2", Mode: "SSB", Date: date.Add(2 * time.Hour)},
+		{CallSign: "4X1ZZ", Frequency: "14.2", Mode: "SSB", Date: date},
+	}
+	importQSOs(t, store, "event", qsos...)
+
+	rules := ScoringRules{DefaultPoints: 1, Multipliers: []Multiplier{{Kind: MultStation}, {Kind: MultGrid}, {Kind: MultDXCC}}}
+	scores, err := ScoreEvent(ctx, store, "event", rules, callsign.Default)
+	if err != nil || len(scores) != 2 {
+		t.Fatalf("got %+v (%v), want 2 stations", scores, err)
+	}
+	for i, want := range []stationScore{
+		// 2 event stations, 1 grid and 1 entity, the 2 QSOs without grid unknown
+		{CallSign: "YB1BBB", Entity: "Indonesia", Points: 3, Multipliers: 4, Unknown: 2, Score: 12},
+		// nothing is known of 4X1ZZ, its points stand
+		{CallSign: "4X1ZZ", Points: 1, Multipliers: 0, Unknown: 3, Score: 1},
+	} {
+		if got := newStationScore(scores[i]); got != want {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+}
+
+// stationScore is the part of a StationScore the tests compare
+type stationScore struct {
+	CallSign, Entity                    string
+	Points, Multipliers, Unknown, Score int
+}
+
+// newStationScore returns the compared part of score
+func newStationScore(score StationScore) stationScore {
+	return stationScore{CallSign: score.CallSign, Entity: score.Entity, Points: score.Points, Multipliers: score.Multipliers, Unknown: score.Unknown, Score: score.Score}
+}
+
+func TestScoreEventLoggedCall(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	date := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	qsos := []QSO{
+		{CallSign: "YB1BBB", Frequency: "7.135", Mode: "SSB", Date: date},
+		{CallSign: "VK2/YB1BBB", Frequency: "14.2", Mode: "SSB", Date: date.Add(time.Hour)},
+	}
+	importQSOs(t, store, "event", qsos...)
+
+	rules := ScoringRules{
+		Home: "YB0AAA",
+		Points: []PointRule{
+			{Location: LocationEntity, Points: 1},
+			{Location: LocationContinent, Points: 2},
+		},
+		Multipliers: []Multiplier{{Kind: MultDXCC}},
+	}
+	scores, err := ScoreEvent(ctx, store, "event", rules, callsign.Default)
+	if err != nil || len(scores) != 1 {
+		t.Fatalf("got %+v (%v), want 1 station", scores, err)
+	}
+	// 1 point in Indonesia, 2 from Australia, 2 entities
+	want := stationScore{CallSign: "YB1BBB", Entity: "Indonesia", Points: 3, Multipliers: 2, Score: 6}
+	if got := newStationScore(scores[0]); got != want {
+		t.Fatalf("got %+v, want %+v, the VK2 QSO scored in Australia", got, want)
+	}
+}
@@ -0,0 +1,259 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollCounters collection holding one document per named sequence
+const CollCounters = "counters"
+
+// SequenceKey names a sequence, empty parts are left out of the name so a
+// sequence can be per event, per event and frequency, or per event, frequency and mode
+type SequenceKey struct {
+	EventID   string `json:"event_id" bson:"event_id"`
+	Frequency string `json:"frequency,omitempty" bson:"frequency,omitempty"`
+	Mode      string `json:"mode,omitempty" bson:"mode,omitempty"`
+}
+
+// SequenceFormat describes how the numbers of a sequence are printed
+type SequenceFormat struct {
+	Digits      int    `json:"digits" bson:"digits"`
+	Prefix      string `json:"prefix" bson:"prefix"`
+	Suffix      string `json:"suffix" bson:"suffix"`
+	YearlyReset bool   `json:"yearly_reset" bson:"yearly_reset"`
+}
+
+// sequenceCounter is the document stored in CollCounters
+type sequenceCounter struct {
+	ID        string      `bson:"_id"`
+	Key       SequenceKey `bson:"key"`
+	Year      int         `bson:"year,omitempty"`
+	Value     int64       `bson:"value"`
+	UpdatedAt time.Time   `bson:"updated_at"`
+}
+
+// Sequence type hands out certificate numbers with an atomic $inc on
+// CollCounters. Numbers are gap free when Next and Reserve are called with the
+// txCtx of the transaction that issues the certificates, an aborted
+// transaction gives its numbers back.
+type Sequence struct {
+	store  Querier
+	key    SequenceKey
+	format SequenceFormat
+	now    func() time.Time
+}
+
+// NewSequence function, store is an *Adaptor or a *MemoryAdaptor
+func NewSequence(store Querier, key SequenceKey, format SequenceFormat) *Sequence {
+	return &Sequence{store: store, key: key, format: format, now: time.Now}
+}
+
+// Name method returns the _id of the counter document used at time at
+func (seq *Sequence) Name(at time.Time) string {
+	parts := []string{"event:" + seq.key.EventID}
+	if seq.key.Frequency != "" {
+		parts = append(parts, "frequency:"+seq.key.Frequency)
+	}
+	if seq.key.Mode != "" {
+		parts = append(parts, "mode:"+seq.key.Mode)
+	}
+	if seq.format.YearlyReset {
+		parts = append(parts, "year:"+strconv.Itoa(at.UTC().Year()))
+	}
+	return strings.Join(parts, "|")
+}
+
+// Format method prints n with the zero padding, prefix and suffix of the
+// sequence, numbers wider than Digits are printed in full
+func (seq *Sequence) Format(n int64) string {
+	return fmt.Sprintf("%s%0*d%s", seq.format.Prefix, seq.format.Digits, n, seq.format.Suffix)
+}
+
+// Current method returns the last number handed out, 0 when none was
+func (seq *Sequence) Current(ctx context.Context) (int64, error) {
+	var counter sequenceCounter
+	err := seq.store.QueryFindV2(ctx, CollCounters, &options.FindOneOptions{}, bson.M{"_id": seq.Name(seq.now())}, &counter)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	return counter.Value, err
+}
+
+// Next method returns the next formatted number
+func (seq *Sequence) Next(ctx context.Context) (string, error) {
+	numbers, err := seq.Reserve(ctx, 1)
+	if err != nil {
+		return "", err
+	}
+	return numbers[0], nil
+}
+
+// NextValue method returns the next number
+func (seq *Sequence) NextValue(ctx context.Context) (int64, error) {
+	last, err := seq.reserve(ctx, 1)
+	return last, err
+}
+
+// Reserve method hands out n consecutive numbers at once, for batch printing
+func (seq *Sequence) Reserve(ctx context.Context, n int64) ([]string, error) {
+	last, err := seq.reserve(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := make([]string, 0, n)
+	for value := last - n + 1; value <= last; value++ {
+		numbers = append(numbers, seq.Format(value))
+	}
+	return numbers, nil
+}
+
+// Seed method starts a sequence that does not exist yet after value, so
+// numbering carries on from a counter kept elsewhere. A sequence that exists
+// is left as it is.
+func (seq *Sequence) Seed(ctx context.Context, value int64) error {
+	at := seq.now()
+	setOnInsert := bson.M{"key": seq.key, "value": value}
+	if seq.format.YearlyReset {
+		setOnInsert["year"] = at.UTC().Year()
+	}
+	opt := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter sequenceCounter
+	err := seq.store.QueryFindAndUpdateV2(ctx, CollCounters, opt, bson.M{"_id": seq.Name(at)}, bson.M{"$setOnInsert": setOnInsert}, &counter)
+	if errors.Is(err, ErrDuplicateKey) {
+		// another seed created it first
+		return nil
+	}
+	return err
+}
+
+// certificateSequence returns the sequence numbering the certificates of
+// frequency. A new sequence is seeded from the counter of the event
+// attribute of frequency, so events numbered before sequences existed carry
+// on where they were.
+func certificateSequence(ctx context.Context, store Querier, eventID, frequency string, format SequenceFormat) (*Sequence, error) {
+	seq := NewSequence(store, SequenceKey{EventID: eventID, Frequency: frequency}, format)
+	OID, err := primitive.ObjectIDFromHex(eventID)
+	if err != nil {
+		// not an event document, nothing to carry on from
+		return seq, nil
+	}
+
+	event := models.Event{}
+	opt := &options.FindOneOptions{Projection: bson.M{"attributes": bson.M{"$elemMatch": bson.M{"frequency": frequency}}}}
+	err = store.QueryFindV2(ctx, models.CollEvent, opt, bson.M{"_id": OID}, &event)
+	if errors.Is(err, ErrNotFound) || err == nil && len(event.Attributes) == 0 {
+		return seq, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return seq, seq.Seed(ctx, int64(event.Attributes[0].Counter))
+}
+
+// setEventCounter raises the counter of the event attribute of frequency to
+// counter, which still tells how many certificates of frequency were issued,
+// when there is one. A lower counter, stored by a slower writer, never
+// lowers it.
+func setEventCounter(ctx context.Context, store Querier, eventID, frequency string, counter int64) error {
+	OID, err := primitive.ObjectIDFromHex(eventID)
+	if err != nil {
+		return nil
+	}
+	filter := bson.M{"_id": OID, "attributes": bson.M{"$elemMatch": bson.M{"frequency": frequency}}}
+	update := bson.M{"$max": bson.M{"attributes.$.counter": counter}}
+	return store.QueryUpdateOne(ctx, models.CollEvent, &options.UpdateOptions{}, filter, update, &mongo.UpdateResult{})
+}
+
+// reserve increases the counter by n and returns the new value
+func (seq *Sequence) reserve(ctx context.Context, n int64) (int64, error) {
+	if n < 1 {
+		return 0, validationError("Sequence.Reserve", "cannot reserve %d numbers", n)
+	}
+
+	at := seq.now()
+	setOnInsert := bson.M{"key": seq.key}
+	if seq.format.YearlyReset {
+		setOnInsert["year"] = at.UTC().Year()
+	}
+	update := bson.M{
+		"$inc":         bson.M{"value": n},
+		"$set":         bson.M{"updated_at": at},
+		"$setOnInsert": setOnInsert,
+	}
+	opt := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var counter sequenceCounter
+	err := seq.store.QueryFindAndUpdateV2(ctx, CollCounters, opt, bson.M{"_id": seq.Name(at)}, update, &counter)
+	if errors.Is(err, ErrDuplicateKey) {
+		// two upserts raced on a new counter, the document exists now
+		err = seq.store.QueryFindAndUpdateV2(ctx, CollCounters, opt, bson.M{"_id": seq.Name(at)}, update, &counter)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return counter.Value, nil
+}
+
+// QueryNextEventCounter method increases the counter of the event attribute
+// matching frequency and returns the new value in a single atomic operation,
+// unlike QueryIncreaseEventCounter followed by QueryEventCounterValue.
+//
+// Deprecated: IssueCertificate and RenderBatch number certificates with the
+// Sequence of the event and frequency, see certificateSequence.
+func (adaptor *Adaptor) QueryNextEventCounter(ctx context.Context, id, frequency string) (int, error) {
+	OID, err := objectID("QueryNextEventCounter", id)
+	if err != nil {
+		return 0, err
+	}
+
+	opt := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{
+			"attributes": bson.M{
+				"$elemMatch": bson.M{
+					"frequency": frequency,
+				},
+			},
+		})
+
+	eventResult := models.Event{}
+	err = adaptor.QueryFindAndUpdateV2(
+		ctx,
+		models.CollEvent,
+		opt,
+		bson.M{
+			"_id": OID,
+			"attributes": bson.M{
+				"$elemMatch": bson.M{
+					"frequency": frequency,
+				},
+			},
+		},
+		bson.M{
+			"$inc": bson.M{
+				"attributes.$.counter": 1,
+			},
+		}, &eventResult)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(eventResult.Attributes) == 0 {
+		return 0, &Error{Op: "QueryNextEventCounter", Kind: ErrNotFound, Err: fmt.Errorf("frequency %q not found", frequency)}
+	}
+
+	return eventResult.Attributes[0].Counter, nil
+}
@@ -0,0 +1,84 @@
+package gomongo
+
+import (
+	"context"
+	"testing"
+
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestCertificateSequence(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+	eventID := primitive.NewObjectID()
+	if _, err := store.QueryInsertV3(ctx, models.CollEvent, bson.M{"_id": eventID, "attributes": bson.A{bson.M{"frequency": "7.100", "counter": 5}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// numbering carries on from the event counter
+	seq, err := certificateSequence(ctx, store, eventID.Hex(), "7.100", SequenceFormat{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := seq.NextValue(ctx)
+	if err != nil || value != 6 {
+		t.Fatalf("got %d (%v), want 6", value, err)
+	}
+	if err := setEventCounter(ctx, store, eventID.Hex(), "7.100", value); err != nil {
+		t.Fatal(err)
+	}
+
+	// a second lookup keeps the sequence as it is
+	seq, err = certificateSequence(ctx, store, eventID.Hex(), "7.100", SequenceFormat{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := seq.NextValue(ctx); err != nil || value != 7 {
+		t.Fatalf("got %d (%v), want 7", value, err)
+	}
+
+	var event models.Event
+	if err := store.QueryFindV2(ctx, models.CollEvent, &options.FindOneOptions{}, bson.M{"_id": eventID}, &event); err != nil || event.Attributes[0].Counter != 6 {
+		t.Fatalf("got %+v (%v), want the event counter at 6", event, err)
+	}
+
+	// an event without the frequency, or no event document at all, starts at 1
+	for _, id := range []string{eventID.Hex(), "event"} {
+		seq, err := certificateSequence(ctx, store, id, "14.200", SequenceFormat{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value, err := seq.NextValue(ctx); err != nil || value != 1 {
+			t.Fatalf("%s: got %d (%v), want 1", id, value, err)
+		}
+	}
+}
+
+func TestSetEventCounter(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+	eventID := primitive.NewObjectID()
+	if _, err := store.QueryInsertV3(ctx, models.CollEvent, bson.M{"_id": eventID, "attributes": bson.A{bson.M{"frequency": "7.100", "counter": 0}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a slower writer storing a lower number does not lower the counter
+	for _, c := range []struct {
+		counter, want int64
+	}{
+		{3, 3},
+		{2, 3},
+		{4, 4},
+	} {
+		if err := setEventCounter(ctx, store, eventID.Hex(), "7.100", c.counter); err != nil {
+			t.Fatal(err)
+		}
+		var event models.Event
+		if err := store.QueryFindV2(ctx, models.CollEvent, nil, bson.M{"_id": eventID}, &event); err != nil || int64(event.Attributes[0].Counter) != c.want {
+			t.Fatalf("after %d: got %+v (%v), want %d", c.counter, event.Attributes, err, c.want)
+		}
+	}
+}
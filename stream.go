@@ -0,0 +1,300 @@
+package gomongo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// streamFlushEvery is the number of records written between two flushes
+const streamFlushEvery = 100
+
+// QueryEach method calls fn with every document matching query, one at a
+// time, without loading the result in memory. The next document is read when
+// fn returns, raw is only valid until then. An error from fn stops the
+// iteration and is returned as is.
+func (adaptor *Adaptor) QueryEach(ctx context.Context, collName string, findOptions *options.FindOptions, query interface{}, fn func(raw bson.Raw) error) error {
+	collection := adaptor.Client.Database(adaptor.DBName).Collection(collName)
+	cursor, err := collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return wrapError("QueryEach", err)
+	}
+
+	return eachCursor(ctx, "QueryEach", cursor, fn)
+}
+
+// QueryAggregateEach method is QueryEach for the results of pipeline
+func (adaptor *Adaptor) QueryAggregateEach(ctx context.Context, collName string, aggregateOptions *options.AggregateOptions, pipeline interface{}, fn func(raw bson.Raw) error) error {
+	collection := adaptor.Client.Database(adaptor.DBName).Collection(collName)
+	cursor, err := collection.Aggregate(ctx, pipeline, aggregateOptions)
+	if err != nil {
+		return wrapError("QueryAggregateEach", err)
+	}
+
+	return eachCursor(ctx, "QueryAggregateEach", cursor, fn)
+}
+
+// eachCursor calls fn with every document of cursor and closes it
+func eachCursor(ctx context.Context, op string, cursor *mongo.Cursor, fn func(raw bson.Raw) error) error {
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(ctx) {
+		if err := fn(cursor.Current); err != nil {
+			return err
+		}
+	}
+	return wrapError(op, cursor.Err())
+}
+
+// QueryEach method
+func (m *MemoryAdaptor) QueryEach(ctx context.Context, collName string, findOptions *options.FindOptions, query interface{}, fn func(raw bson.Raw) error) error {
+	if findOptions == nil {
+		findOptions = options.Find()
+	}
+
+	unlock := m.lock(ctx)
+	docs, err := m.findSorted(collName, query, findOptions.Sort, findOptions.Skip, findOptions.Limit, findOptions.Projection)
+	unlock()
+	if err != nil {
+		return wrapError("QueryEach", err)
+	}
+
+	return eachDocument(ctx, "QueryEach", docs, fn)
+}
+
+// QueryAggregateEach method
+func (m *MemoryAdaptor) QueryAggregateEach(ctx context.Context, collName string, aggregateOptions *options.AggregateOptions, pipeline interface{}, fn func(raw bson.Raw) error) error {
+	stages, err := toStages(pipeline)
+	if err != nil {
+		return wrapError("QueryAggregateEach", err)
+	}
+
+	unlock := m.lock(ctx)
+	docs := make([]bson.M, len(m.collections[collName]))
+	for i, doc := range m.collections[collName] {
+		docs[i] = deepCopy(doc).(bson.M)
+	}
+	docs, err = m.runPipeline(docs, stages)
+	unlock()
+	if err != nil {
+		return wrapError("QueryAggregateEach", err)
+	}
+
+	return eachDocument(ctx, "QueryAggregateEach", docs, fn)
+}
+
+// eachDocument calls fn with every doc until ctx is done
+func eachDocument(ctx context.Context, op string, docs []bson.M, fn func(raw bson.Raw) error) error {
+	for _, doc := range docs {
+		if err := ctx.Err(); err != nil {
+			return wrapError(op, err)
+		}
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return wrapError(op, err)
+		}
+		if err := fn(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Each function decodes every document of collName matching filter into T
+// and calls fn with it, see Querier.QueryEach
+func Each[T any](ctx context.Context, store Querier, collName string, findOptions *options.FindOptions, filter interface{}, fn func(document T) error) error {
+	return store.QueryEach(ctx, collName, findOptions, filter, func(raw bson.Raw) error {
+		var document T
+		if err := bson.Unmarshal(raw, &document); err != nil {
+			return wrapError("Each", err)
+		}
+		return fn(document)
+	})
+}
+
+// Stream function sends every document of collName matching filter on the
+// returned channel, holding at most buffer documents ahead of the reader. The
+// error channel receives the result once the documents channel is closed.
+// Cancel ctx to stop early when not reading the documents channel to the end.
+func Stream[T any](ctx context.Context, store Querier, collName string, findOptions *options.FindOptions, filter interface{}, buffer int) (<-chan T, <-chan error) {
+	documents := make(chan T, buffer)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(documents)
+
+		errc <- Each(ctx, store, collName, findOptions, filter, func(document T) error {
+			if err := ctx.Err(); err != nil {
+				return wrapError("Stream", err)
+			}
+			select {
+			case documents <- document:
+				return nil
+			case <-ctx.Done():
+				return wrapError("Stream", ctx.Err())
+			}
+		})
+	}()
+
+	return documents, errc
+}
+
+// Each method, see Each function
+func (repo *Repository[T]) Each(ctx context.Context, filter interface{}, fn func(document T) error, opts ...*options.FindOptions) error {
+	return Each(ctx, repo.store, repo.collName, options.MergeFindOptions(opts...), filter, fn)
+}
+
+// Stream method, see Stream function
+func (repo *Repository[T]) Stream(ctx context.Context, filter interface{}, buffer int, opts ...*options.FindOptions) (<-chan T, <-chan error) {
+	return Stream[T](ctx, repo.store, repo.collName, options.MergeFindOptions(opts...), filter, buffer)
+}
+
+// StreamFormat type
+type StreamFormat int
+
+// Stream formats
+const (
+	// StreamJSONArray writes one JSON array
+	StreamJSONArray StreamFormat = iota
+	// StreamNDJSON writes one JSON document per line
+	StreamNDJSON
+)
+
+// ContentType method
+func (format StreamFormat) ContentType() string {
+	if format == StreamNDJSON {
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// RecordWriter type writes records to an io.Writer as they come, flushing
+// every streamFlushEvery records and on Close. Close must be called to
+// terminate a JSON array.
+type RecordWriter struct {
+	w       io.Writer
+	buf     *bufio.Writer
+	format  StreamFormat
+	count   int64
+	started bool
+}
+
+// NewRecordWriter function
+func NewRecordWriter(w io.Writer, format StreamFormat) *RecordWriter {
+	return &RecordWriter{w: w, buf: bufio.NewWriter(w), format: format}
+}
+
+// Write method writes one record, a bson.Raw is written as the document it holds
+func (rw *RecordWriter) Write(record interface{}) error {
+	if raw, ok := record.(bson.Raw); ok {
+		var document bson.M
+		if err := bson.Unmarshal(raw, &document); err != nil {
+			return wrapError("RecordWriter.Write", err)
+		}
+		record = document
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return wrapError("RecordWriter.Write", err)
+	}
+
+	switch rw.format {
+	case StreamNDJSON:
+		data = append(data, '\n')
+	default:
+		separator := byte(',')
+		if !rw.started {
+			separator = '['
+		}
+		if err := rw.buf.WriteByte(separator); err != nil {
+			return err
+		}
+	}
+	rw.started = true
+
+	if _, err := rw.buf.Write(data); err != nil {
+		return err
+	}
+
+	rw.count++
+	if rw.count%streamFlushEvery == 0 {
+		return rw.Flush()
+	}
+	return nil
+}
+
+// Flush method writes the buffered records and flushes an http.ResponseWriter
+func (rw *RecordWriter) Flush() error {
+	if err := rw.buf.Flush(); err != nil {
+		return err
+	}
+	if flusher, ok := rw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// Close method terminates the JSON array and flushes, it does not close the io.Writer
+func (rw *RecordWriter) Close() error {
+	if rw.format == StreamJSONArray {
+		closing := "]"
+		if !rw.started {
+			closing = "[]"
+		}
+		if _, err := rw.buf.WriteString(closing); err != nil {
+			return err
+		}
+		rw.started = true
+	}
+	return rw.Flush()
+}
+
+// Count method returns the number of records written
+func (rw *RecordWriter) Count() int64 {
+	return rw.count
+}
+
+// StreamJSON function writes every document of collName matching filter to w
+// and returns how many were written
+func StreamJSON(ctx context.Context, store Querier, collName string, findOptions *options.FindOptions, filter interface{}, w io.Writer, format StreamFormat) (int64, error) {
+	rw := NewRecordWriter(w, format)
+	err := store.QueryEach(ctx, collName, findOptions, filter, func(raw bson.Raw) error {
+		return rw.Write(raw)
+	})
+	if err != nil {
+		return rw.Count(), err
+	}
+	return rw.Count(), rw.Close()
+}
+
+// StreamReportLog method writes the report of GetReportLog to w without the
+// 2000 rows cap, a request.Limit of 0 writes every QSO of the event
+func (adaptor *Adaptor) StreamReportLog(ctx context.Context, request models.TRequestCallSignReport, w io.Writer, format StreamFormat) (int64, error) {
+	var eventName string
+	if err := adaptor.GetEventName(ctx, request.EventID, &eventName); err != nil {
+		return 0, err
+	}
+
+	// without the cap the $sort of a large event spills to disk
+	rw := NewRecordWriter(w, format)
+	err := adaptor.QueryAggregateEach(ctx, models.CollIdentity, options.Aggregate().SetAllowDiskUse(true), reportLogPipeline(request, eventName, int64(request.Limit)).Stages(), func(raw bson.Raw) error {
+		var record models.TResponseCallSignReport
+		if err := bson.Unmarshal(raw, &record); err != nil {
+			return wrapError("StreamReportLog", err)
+		}
+		return rw.Write(record)
+	})
+	if err != nil {
+		return rw.Count(), err
+	}
+	return rw.Count(), rw.Close()
+}
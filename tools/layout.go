@@ -0,0 +1,313 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agustadewa/gomongo/modes"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+
+	"github.com/agustadewa/gomongo/bandplan"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Field sources understood by CertField.Source
+const (
+	FieldCallSign   = "call_sign"
+	FieldName       = "name"
+	FieldFrequency  = "frequency"
+	FieldBand       = "band"
+	FieldMode       = "mode"
+	FieldRST        = "rst"
+	FieldDate       = "date"
+	FieldUTC        = "utc"
+	FieldCertNumber = "certificate_number"
+	FieldText       = "text"
+)
+
+// default time layouts for FieldDate and FieldUTC
+const (
+	defaultDateFormat = "02 Jan 2006"
+	defaultUTCFormat  = "15:04"
+)
+
+// CertColor type
+type CertColor struct {
+	R int `json:"r" bson:"r"`
+	G int `json:"g" bson:"g"`
+	B int `json:"b" bson:"b"`
+}
+
+// CertPosition type
+type CertPosition struct {
+	X float64 `json:"x" bson:"x"`
+	Y float64 `json:"y" bson:"y"`
+}
+
+// CertField is one text element drawn on a certificate.
+// Format is a time layout for FieldDate and FieldUTC, for every other source
+// it is a text where #CALLSIGN#, #NAME#, #FREQUENCY#, #BAND#, #MODE#, #RST#,
+// #NO#, #DATE#, #UTC# and #VALUE# are replaced.
+// Unit is the unit FieldFrequency is printed in, Hz, kHz, MHz or GHz, the
+// stored value is printed as is when it is empty.
+type CertField struct {
+	Source    string       `json:"source" bson:"source"`
+	Format    string       `json:"format,omitempty" bson:"format,omitempty"`
+	FontDir   string       `json:"font_dir" bson:"font_dir"`
+	FontName  string       `json:"font_name" bson:"font_name"`
+	FontSize  float64      `json:"font_size" bson:"font_size"`
+	FontColor CertColor    `json:"font_color" bson:"font_color"`
+	Position  CertPosition `json:"position" bson:"position"`
+	Width     float64      `json:"width" bson:"width"`
+	Height    float64      `json:"height" bson:"height"`
+	TextAlign string       `json:"text_align" bson:"text_align"`
+	Unit      string       `json:"unit,omitempty" bson:"unit,omitempty"`
+}
+
+// CertLayout is an ordered list of fields rendered on top of the template image
+type CertLayout struct {
+	Name   string      `json:"name" bson:"name"`
+	Fields []CertField `json:"fields" bson:"fields"`
+	Page   PageSetup   `json:"page" bson:"page"`
+}
+
+// CertValues holds the values a layout can print
+type CertValues struct {
+	CertNumber string
+	CallSign   string
+	Name       string
+	Frequency  string
+	Band       string
+	Mode       string
+	RST        string
+	Date       time.Time
+}
+
+// NewCertValues builds the values of one identity attribute
+func NewCertValues(certNumber string, identity models.Identity, identityIndex int) (CertValues, error) {
+	if identityIndex < 0 || identityIndex >= len(identity.Attributes) {
+		return CertValues{}, errors.New("identity attribute not found")
+	}
+	identityAttribute := identity.Attributes[identityIndex]
+
+	numericFullDate, _ := strconv.ParseInt(identityAttribute.Date, 10, 64)
+
+	return CertValues{
+		CertNumber: certNumber,
+		CallSign:   identity.CallSign,
+		Name:       identity.Name,
+		Frequency:  identityAttribute.Frequency,
+		Band:       identityAttribute.Band,
+		Mode:       modes.Label(identityAttribute.Mode),
+		RST:        identityAttribute.RST,
+		Date:       time.Unix(numericFullDate/1000, 0).UTC(),
+	}, nil
+}
+
+// Replace method replaces the #TOKEN# placeholders in format
+func (values CertValues) Replace(format, value string) string {
+	return strings.NewReplacer(
+		"#CALLSIGN#", values.CallSign,
+		"#NAME#", values.Name,
+		"#FREQUENCY#", values.Frequency,
+		"#BAND#", values.Band,
+		"#MODE#", values.Mode,
+		"#RST#", values.RST,
+		"#NO#", values.CertNumber,
+		"#DATE#", values.Date.Format(defaultDateFormat),
+		"#UTC#", values.Date.Format(defaultUTCFormat),
+		"#VALUE#", value,
+	).Replace(format)
+}
+
+// Text method returns the text printed for the field
+func (field CertField) Text(values CertValues) (string, error) {
+	var value string
+
+	switch field.Source {
+	case FieldDate, FieldUTC:
+		layout := field.Format
+		if layout == "" {
+			layout = defaultDateFormat
+			if field.Source == FieldUTC {
+				layout = defaultUTCFormat
+			}
+		}
+		return values.Date.Format(layout), nil
+	case FieldCallSign:
+		value = values.CallSign
+	case FieldName:
+		value = values.Name
+	case FieldFrequency:
+		value = values.Frequency
+		if field.Unit != "" {
+			unit, err := bandplan.ParseUnit(field.Unit)
+			if err != nil {
+				return "", err
+			}
+			if frequency, err := bandplan.Parse(value); err == nil {
+				value = frequency.Format(unit)
+			}
+		}
+	case FieldBand:
+		value = values.Band
+	case FieldMode:
+		value = values.Mode
+	case FieldRST:
+		value = values.RST
+	case FieldCertNumber:
+		value = values.CertNumber
+	case FieldText:
+	default:
+		return "", fmt.Errorf("unknown field source %q", field.Source)
+	}
+
+	if field.Format == "" {
+		return value, nil
+	}
+	return values.Replace(field.Format, value), nil
+}
+
+// Validate method
+func (layout CertLayout) Validate() error {
+	if len(layout.Fields) == 0 {
+		return errors.New("layout has no fields")
+	}
+	if err := layout.Page.Validate(); err != nil {
+		return fmt.Errorf("page: %w", err)
+	}
+	for i, field := range layout.Fields {
+		if _, err := field.Text(CertValues{}); err != nil {
+			return fmt.Errorf("field %d: %w", i, err)
+		}
+		if field.FontName == "" {
+			return fmt.Errorf("field %d: font name is empty", i)
+		}
+	}
+	return nil
+}
+
+// coreFonts are the standard PDF fonts, they need no font file
+var coreFonts = map[string]bool{"courier": true, "helvetica": true, "arial": true, "times": true, "symbol": true, "zapfdingbats": true}
+
+// addFonts registers every font used by the layout
+func (layout CertLayout) addFonts(pdf *gofpdf.Fpdf) {
+	for _, field := range layout.Fields {
+		if field.FontName != "" && !coreFonts[strings.ToLower(field.FontName)] {
+			pdf.SetFontLocation(field.FontDir)
+			pdf.AddFont(field.FontName, "", fmt.Sprintf("%s.json", field.FontName))
+		}
+	}
+}
+
+// draw renders the fields on the current page
+func (layout CertLayout) draw(pdf *gofpdf.Fpdf, values CertValues) {
+	for _, field := range layout.Fields {
+		text, err := field.Text(values)
+		if err != nil {
+			pdf.SetError(err)
+			return
+		}
+
+		width, height := field.Width, field.Height
+		if width == 0 {
+			width = 10
+		}
+		if height == 0 {
+			height = 10
+		}
+
+		pdf.SetFont(field.FontName, "", field.FontSize)
+		pdf.SetXY(field.Position.X, field.Position.Y)
+		pdf.SetTextColor(field.FontColor.R, field.FontColor.G, field.FontColor.B)
+		pdf.CellFormat(width, height, text, "", 0, field.TextAlign, false, 0, "")
+	}
+}
+
+// LegacyLayout converts a TYPE 1 … TYPE 5 template into a layout
+func LegacyLayout(imageCertTemplate models.ImageCertTemplate) (CertLayout, error) {
+	properties := imageCertTemplate.TemplateProperties
+
+	callSign := legacyField(FieldCallSign, 40, properties.CallSign.FontName, properties.CallSign.FontSize,
+		CertColor{properties.CallSign.FontColor.R, properties.CallSign.FontColor.G, properties.CallSign.FontColor.B},
+		CertPosition{properties.CallSign.TextPosition.X, properties.CallSign.TextPosition.Y},
+		properties.CallSign.TextAlign)
+	callSign2 := legacyField(FieldCallSign, 40, properties.CallSign2.FontName, properties.CallSign2.FontSize,
+		CertColor{properties.CallSign2.FontColor.R, properties.CallSign2.FontColor.G, properties.CallSign2.FontColor.B},
+		CertPosition{properties.CallSign2.TextPosition.X, properties.CallSign2.TextPosition.Y},
+		properties.CallSign2.TextAlign)
+	name := legacyField(FieldName, 10, properties.IdentityName.FontName, properties.IdentityName.FontSize,
+		CertColor{properties.IdentityName.FontColor.R, properties.IdentityName.FontColor.G, properties.IdentityName.FontColor.B},
+		CertPosition{properties.IdentityName.TextPosition.X, properties.IdentityName.TextPosition.Y},
+		properties.IdentityName.TextAlign)
+	frequency := legacyField(FieldFrequency, 10, properties.Frequency.FontName, properties.Frequency.FontSize,
+		CertColor{properties.Frequency.FontColor.R, properties.Frequency.FontColor.G, properties.Frequency.FontColor.B},
+		CertPosition{properties.Frequency.TextPosition.X, properties.Frequency.TextPosition.Y},
+		properties.Frequency.TextAlign)
+	date := legacyField(FieldDate, 10, properties.Date.FontName, properties.Date.FontSize,
+		CertColor{properties.Date.FontColor.R, properties.Date.FontColor.G, properties.Date.FontColor.B},
+		CertPosition{properties.Date.TextPosition.X, properties.Date.TextPosition.Y},
+		properties.Date.TextAlign)
+	utc := legacyField(FieldUTC, 10, properties.UTC.FontName, properties.UTC.FontSize,
+		CertColor{properties.UTC.FontColor.R, properties.UTC.FontColor.G, properties.UTC.FontColor.B},
+		CertPosition{properties.UTC.TextPosition.X, properties.UTC.TextPosition.Y},
+		properties.UTC.TextAlign)
+	band := legacyField(FieldBand, 10, properties.Band.FontName, properties.Band.FontSize,
+		CertColor{properties.Band.FontColor.R, properties.Band.FontColor.G, properties.Band.FontColor.B},
+		CertPosition{properties.Band.TextPosition.X, properties.Band.TextPosition.Y},
+		properties.Band.TextAlign)
+	mode := legacyField(FieldMode, 10, properties.Mode.FontName, properties.Mode.FontSize,
+		CertColor{properties.Mode.FontColor.R, properties.Mode.FontColor.G, properties.Mode.FontColor.B},
+		CertPosition{properties.Mode.TextPosition.X, properties.Mode.TextPosition.Y},
+		properties.Mode.TextAlign)
+	rst := legacyField(FieldRST, 10, properties.RST.FontName, properties.RST.FontSize,
+		CertColor{properties.RST.FontColor.R, properties.RST.FontColor.G, properties.RST.FontColor.B},
+		CertPosition{properties.RST.TextPosition.X, properties.RST.TextPosition.Y},
+		properties.RST.TextAlign)
+	certNumber := legacyField(FieldCertNumber, 10, properties.CertificateNumber.FontName, properties.CertificateNumber.FontSize,
+		CertColor{properties.CertificateNumber.FontColor.R, properties.CertificateNumber.FontColor.G, properties.CertificateNumber.FontColor.B},
+		CertPosition{properties.CertificateNumber.TextPosition.X, properties.CertificateNumber.TextPosition.Y},
+		properties.CertificateNumber.TextAlign)
+
+	frequencyBand := frequency
+	frequencyBand.Format = "#FREQUENCY# - #BAND#"
+
+	var fields []CertField
+	switch properties.TemplateType {
+	case "TYPE 1":
+		fields = []CertField{callSign, name, frequencyBand}
+	case "TYPE 2":
+		fields = []CertField{callSign, name, frequencyBand, date, utc, band, mode, rst, certNumber}
+	case "TYPE 3":
+		fields = []CertField{callSign, name, date, utc, band, frequency, mode, rst, certNumber}
+	case "TYPE 4":
+		fields = []CertField{callSign, name, callSign2, date, utc, frequency, mode, rst}
+	case "TYPE 5":
+		fields = []CertField{callSign, name, utc, frequency, mode, rst}
+	default:
+		return CertLayout{}, errors.New("handler not found")
+	}
+
+	// PrintPDFV4 always loaded the fonts from the call sign font directory
+	for i := range fields {
+		fields[i].FontDir = properties.CallSign.FontDir
+	}
+
+	return CertLayout{Name: properties.TemplateType, Fields: fields}, nil
+}
+
+func legacyField(source string, width float64, fontName string, fontSize float64, fontColor CertColor, position CertPosition, textAlign string) CertField {
+	return CertField{
+		Source:    source,
+		FontName:  fontName,
+		FontSize:  fontSize,
+		FontColor: fontColor,
+		Position:  position,
+		Width:     width,
+		Height:    10,
+		TextAlign: textAlign,
+	}
+}
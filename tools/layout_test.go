@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+)
+
+var testIdentity = models.Identity{
+	CallSign: "YB0AAA",
+	Name:     "Budi",
+	Attributes: []models.IdentityAttribute{
+		{Frequency: "7.135", Band: "40m", Mode: "SSB", RST: "59", Date: "1772368200000"},
+	},
+}
+
+func TestNewCertValues(t *testing.T) {
+	values, err := NewCertValues("0012", testIdentity, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values.CallSign != "YB0AAA" || values.Frequency != "7.135" || values.Mode != "SSB" || !values.Date.Equal(time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)) {
+		t.Fatalf("got %+v, want the first attribute of YB0AAA", values)
+	}
+	if _, err := NewCertValues("0012", testIdentity, 1); err == nil {
+		t.Fatal("want an error for a missing attribute")
+	}
+
+	for stored, want := range map[string]string{"FT4": "FT4", "usb": "USB", "Phone": "Phone"} {
+		identity := models.Identity{CallSign: "YB0AAA", Attributes: []models.IdentityAttribute{{Mode: stored}}}
+		if values, err := NewCertValues("0012", identity, 0); err != nil || values.Mode != want {
+			t.Errorf("%q: got mode %q (%v), want %q", stored, values.Mode, err, want)
+		}
+	}
+}
+
+func TestFieldText(t *testing.T) {
+	values, _ := NewCertValues("0012", testIdentity, 0)
+	for _, c := range []struct {
+		field CertField
+		want  string
+	}{
+		{CertField{Source: FieldCallSign}, "YB0AAA"},
+		{CertField{Source: FieldFrequency, Format: "#FREQUENCY# - #BAND#"}, "7.135 - 40m"},
+		{CertField{Source: FieldFrequency, Unit: "kHz"}, "7135"},
+		{CertField{Source: FieldDate}, "01 Mar 2026"},
+		{CertField{Source: FieldUTC}, "12:30"},
+		{CertField{Source: FieldUTC, Format: "1504Z"}, "1230Z"},
+		{CertField{Source: FieldText, Format: "No. #NO# for #NAME#, #MODE# #RST#"}, "No. 0012 for Budi, SSB 59"},
+	} {
+		got, err := c.field.Text(values)
+		if err != nil || got != c.want {
+			t.Errorf("%+v: got %q (%v), want %q", c.field, got, err, c.want)
+		}
+	}
+
+	if _, err := (CertField{Source: "qth"}).Text(values); err == nil {
+		t.Error("want an error for an unknown source")
+	}
+	if _, err := (CertField{Source: FieldFrequency, Unit: "furlong"}).Text(values); err == nil {
+		t.Error("want an error for an unknown unit")
+	}
+}
+
+func TestLayoutValidate(t *testing.T) {
+	layout := CertLayout{Name: "test", Fields: []CertField{{Source: FieldCallSign, FontName: "Helvetica"}}}
+	if err := layout.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []CertLayout{
+		{Name: "empty"},
+		{Fields: []CertField{{Source: FieldCallSign}}},
+		{Fields: []CertField{{Source: "qth", FontName: "Helvetica"}}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v: want an error", bad)
+		}
+	}
+}
+
+func TestLegacyLayout(t *testing.T) {
+	var template models.ImageCertTemplate
+	template.TemplateProperties.TemplateType = "TYPE 2"
+	template.TemplateProperties.CallSign.FontDir = "fonts"
+
+	layout, err := LegacyLayout(template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layout.Name != "TYPE 2" || len(layout.Fields) != 9 || layout.Fields[2].Format != "#FREQUENCY# - #BAND#" || layout.Fields[8].FontDir != "fonts" {
+		t.Fatalf("got %+v, want the 9 fields of TYPE 2", layout)
+	}
+
+	template.TemplateProperties.TemplateType = "TYPE 9"
+	if _, err := LegacyLayout(template); err == nil {
+		t.Fatal("want an error for an unknown template type")
+	}
+}
+
+// layoutSource returns layout for every template
+type layoutSource struct {
+	layout CertLayout
+	asked  int
+}
+
+// CertLayoutFor method
+func (source *layoutSource) CertLayoutFor(ctx context.Context, imageCertTemplate models.ImageCertTemplate) (CertLayout, error) {
+	source.asked++
+	return source.layout, nil
+}
+
+func TestPrintPDFV4(t *testing.T) {
+	templatePath := writeTemplate(t)
+	var template models.ImageCertTemplate
+	template.TemplateProperties.TemplateType = "TYPE 1"
+	template.TemplateProperties.CallSign.FontName = "Helvetica"
+	template.TemplateProperties.IdentityName.FontName = "Helvetica"
+	template.TemplateProperties.Frequency.FontName = "Helvetica"
+
+	var out bytes.Buffer
+	if err := (Tools{}).PrintPDFV4("0012", testIdentity, 0, templatePath, "PNG", &out, template); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out.Bytes(), []byte("%PDF")) {
+		t.Fatalf("got %q, want a PDF", out.Bytes()[:8])
+	}
+
+	// the stored layout is rendered instead of the built in one
+	source := &layoutSource{layout: CertLayout{Fields: []CertField{{Source: "qth", FontName: "Helvetica"}}}}
+	err := Tools{Layouts: source}.PrintPDFV4("0012", testIdentity, 0, templatePath, "PNG", &out, template)
+	if source.asked != 1 || err == nil || !strings.Contains(err.Error(), "qth") {
+		t.Fatalf("got %v after %d lookups, want the stored layout rendered", err, source.asked)
+	}
+}
+
+// writeTemplate writes a small template image
+func writeTemplate(t *testing.T) string {
+	t.Helper()
+	templatePath := filepath.Join(t.TempDir(), "template.png")
+	file, err := os.Create(templatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, image.NewRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	return templatePath
+}
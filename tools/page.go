@@ -0,0 +1,193 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Page sizes understood by PageSetup.Size
+const (
+	PageA3     = "A3"
+	PageA4     = "A4"
+	PageA5     = "A5"
+	PageLetter = "Letter"
+	PageLegal  = "Legal"
+	// PageQSL is the 140×90 mm QSL card
+	PageQSL = "QSL"
+)
+
+// Orientations understood by PageSetup.Orientation
+const (
+	Landscape = "L"
+	Portrait  = "P"
+)
+
+// Backgrounds understood by PageSetup.Background
+const (
+	// BackgroundStretch covers the page with the template, distorted when
+	// the page and the image differ in shape
+	BackgroundStretch = "stretch"
+	// BackgroundFit scales the template to the largest size within the page,
+	// centered, leaving blank bands
+	BackgroundFit = "fit"
+	// BackgroundFill scales the template to the smallest size covering the
+	// page, centered, cropping what falls outside
+	BackgroundFill = "fill"
+)
+
+// pageSizes are the named page sizes in mm, short side first
+var pageSizes = map[string][2]float64{
+	"A3":     {297, 420},
+	"A4":     {210, 297},
+	"A5":     {148, 210},
+	"LETTER": {215.9, 279.4},
+	"LEGAL":  {215.9, 355.6},
+	"QSL":    {90, 140},
+}
+
+// maxPageSide is the longest side of a page in mm, a little more than A0
+const maxPageSide = 1200.0
+
+// mmPerUnit is the length of each unit of PageSetup.Unit in mm
+var mmPerUnit = map[string]float64{
+	"mm": 1,
+	"cm": 10,
+	"in": 25.4,
+	"pt": 25.4 / 72,
+}
+
+// PageSetup type is the page a layout is printed on, the zero value is a
+// landscape A4 page in mm with the template stretched over it. The field
+// positions and sizes are in Unit from the top left corner of the page as
+// oriented.
+type PageSetup struct {
+	Size string `json:"size,omitempty" bson:"size,omitempty"`
+	// Width and Height give a custom size in Unit, they take precedence over Size
+	Width       float64 `json:"width,omitempty" bson:"width,omitempty"`
+	Height      float64 `json:"height,omitempty" bson:"height,omitempty"`
+	Orientation string  `json:"orientation,omitempty" bson:"orientation,omitempty"`
+	// Unit is mm, cm, in or pt, mm when empty
+	Unit       string `json:"unit,omitempty" bson:"unit,omitempty"`
+	Background string `json:"background,omitempty" bson:"background,omitempty"`
+}
+
+// unit returns the unit of the page
+func (page PageSetup) unit() string {
+	if page.Unit == "" {
+		return "mm"
+	}
+	return strings.ToLower(page.Unit)
+}
+
+// Dimensions method returns the width and height of the page in its unit,
+// as oriented. A named size is landscape unless Orientation is Portrait, a
+// custom size keeps its shape unless Orientation says otherwise. A custom
+// side must be finite and at most 1200 mm.
+func (page PageSetup) Dimensions() (width, height float64, err error) {
+	perUnit, ok := mmPerUnit[page.unit()]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown page unit %q", page.Unit)
+	}
+
+	switch {
+	case math.IsNaN(page.Width) || math.IsNaN(page.Height) || math.IsInf(page.Width, 0) || math.IsInf(page.Height, 0):
+		return 0, 0, fmt.Errorf("custom page size %gx%g", page.Width, page.Height)
+	case page.Width > 0 && page.Height > 0:
+		if page.Width*perUnit > maxPageSide || page.Height*perUnit > maxPageSide {
+			return 0, 0, fmt.Errorf("custom page size %gx%g %s larger than %g mm", page.Width, page.Height, page.unit(), maxPageSide)
+		}
+		width, height = page.Width, page.Height
+	case page.Width != 0 || page.Height != 0:
+		return 0, 0, fmt.Errorf("custom page size %gx%g", page.Width, page.Height)
+	default:
+		size := page.Size
+		if size == "" {
+			size = PageA4
+		}
+		mm, ok := pageSizes[strings.ToUpper(size)]
+		if !ok {
+			return 0, 0, fmt.Errorf("unknown page size %q", page.Size)
+		}
+		width, height = mm[1]/perUnit, mm[0]/perUnit
+		if page.Orientation == "" {
+			return width, height, nil
+		}
+	}
+
+	switch strings.ToUpper(page.Orientation) {
+	case "":
+	case Landscape:
+		if width < height {
+			width, height = height, width
+		}
+	case Portrait:
+		if width > height {
+			width, height = height, width
+		}
+	default:
+		return 0, 0, fmt.Errorf("unknown page orientation %q", page.Orientation)
+	}
+	return width, height, nil
+}
+
+// Validate method
+func (page PageSetup) Validate() error {
+	if _, _, err := page.Dimensions(); err != nil {
+		return err
+	}
+	switch page.Background {
+	case "", BackgroundStretch, BackgroundFit, BackgroundFill:
+		return nil
+	}
+	return fmt.Errorf("unknown page background %q", page.Background)
+}
+
+// newPDF returns an empty document of the page and its dimensions
+func (page PageSetup) newPDF() (*gofpdf.Fpdf, float64, float64, error) {
+	width, height, err := page.Dimensions()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// the size is already oriented, a portrait page keeps it as is
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		OrientationStr: Portrait,
+		UnitStr:        page.unit(),
+		Size:           gofpdf.SizeType{Wd: width, Ht: height},
+	})
+	return pdf, width, height, nil
+}
+
+// backgroundRect returns where an image of imageWidth×imageHeight is drawn
+// on a page of width×height, as the background of the page says
+func (page PageSetup) backgroundRect(imageWidth, imageHeight, width, height float64) (x, y, w, h float64) {
+	if page.Background == "" || page.Background == BackgroundStretch || imageWidth == 0 || imageHeight == 0 {
+		return 0, 0, width, height
+	}
+
+	scale := math.Min(width/imageWidth, height/imageHeight)
+	if page.Background == BackgroundFill {
+		scale = math.Max(width/imageWidth, height/imageHeight)
+	}
+	w, h = imageWidth*scale, imageHeight*scale
+	return (width - w) / 2, (height - h) / 2, w, h
+}
+
+// drawBackground draws the template image on the current page
+func (page PageSetup) drawBackground(pdf *gofpdf.Fpdf, templatePath, fileType string, width, height float64) {
+	options := gofpdf.ImageOptions{ImageType: fileType, ReadDpi: true}
+
+	var imageWidth, imageHeight float64
+	if page.Background != "" && page.Background != BackgroundStretch {
+		info := pdf.RegisterImageOptions(templatePath, options)
+		if info == nil {
+			return
+		}
+		imageWidth, imageHeight = info.Width(), info.Height()
+	}
+	x, y, w, h := page.backgroundRect(imageWidth, imageHeight, width, height)
+	pdf.ImageOptions(templatePath, x, y, w, h, false, options, 0, "")
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPageDimensions(t *testing.T) {
+	for _, c := range []struct {
+		page          PageSetup
+		width, height float64
+	}{
+		{PageSetup{}, 297, 210},
+		{PageSetup{Size: PageQSL}, 140, 90},
+		{PageSetup{Size: PageLetter, Orientation: Portrait, Unit: "in"}, 8.5, 11},
+		{PageSetup{Width: 90, Height: 140}, 90, 140},
+		{PageSetup{Width: 90, Height: 140, Orientation: Landscape}, 140, 90},
+		{PageSetup{Width: 120, Height: 84.1, Unit: "cm"}, 120, 84.1},
+	} {
+		width, height, err := c.page.Dimensions()
+		if err != nil || math.Abs(width-c.width) > 1e-9 || math.Abs(height-c.height) > 1e-9 {
+			t.Errorf("%+v: got %gx%g (%v), want %gx%g", c.page, width, height, err, c.width, c.height)
+		}
+	}
+}
+
+func TestPageValidate(t *testing.T) {
+	for _, page := range []PageSetup{
+		{Size: "B5"}, {Unit: "px"}, {Orientation: "X"}, {Width: 90}, {Background: "tile"},
+		{Width: math.NaN(), Height: 90}, {Width: math.Inf(1), Height: 90}, {Width: 90, Height: math.Inf(-1)},
+		{Width: 100000, Height: 90}, {Width: 90, Height: 48, Unit: "in"},
+	} {
+		if err := page.Validate(); err == nil {
+			t.Errorf("%+v: want a validation error", page)
+		}
+	}
+}
+
+func TestPrintPDFIdentityPage(t *testing.T) {
+	templatePath := writeTemplate(t)
+
+	// the media box is in points, 140×90 mm and 8.5×11 in
+	for page, mediaBox := range map[PageSetup]string{
+		{Size: PageQSL, Background: BackgroundFit}:                                        "/MediaBox [0 0 396.85 255.12]",
+		{Size: PageLetter, Orientation: Portrait, Unit: "in", Background: BackgroundFill}: "/MediaBox [0 0 612.00 792.00]",
+	} {
+		layout := CertLayout{Name: "page", Page: page, Fields: []CertField{{Source: FieldCallSign, FontName: "Helvetica", FontSize: 20}}}
+		if err := layout.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		for _, multiPage := range []MultiPage{PagePerAttribute, PageQSOTable} {
+			var pdf bytes.Buffer
+			if err := (Tools{}).PrintPDFIdentity([]string{"0001"}, testIdentity, templatePath, "PNG", &pdf, layout, multiPage); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(pdf.String(), mediaBox) {
+				t.Fatalf("%+v %s: want %s", page, multiPage, mediaBox)
+			}
+		}
+	}
+}
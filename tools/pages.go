@@ -0,0 +1,208 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// MultiPage type is how PrintPDFIdentity lays out the attributes of an identity
+type MultiPage string
+
+// Multi-page layouts
+const (
+	// PagePerAttribute renders one certificate page per attribute
+	PagePerAttribute MultiPage = "pages"
+	// PageQSOTable renders the certificate of the first attribute followed by
+	// a table of every QSO, continued on as many pages as needed
+	PageQSOTable MultiPage = "table"
+)
+
+// QSO table geometry in mm, the columns share the width of the page
+const (
+	tableMargin    = 15.0
+	tableTitle     = 12.0
+	tableRowHeight = 7.0
+	tableFont      = "Helvetica"
+	tableFontSize  = 10.0
+)
+
+// tableColumns are the heading and relative width of the QSO table columns
+var tableColumns = []struct {
+	heading string
+	width   float64
+}{
+	{"No", 15},
+	{"Date", 40},
+	{"UTC", 25},
+	{"Frequency", 45},
+	{"Band", 35},
+	{"Mode", 40},
+	{"RST", 30},
+	{"Certificate", 37},
+}
+
+// qsoTable is the geometry of the QSO table on a page, in the unit of the page
+type qsoTable struct {
+	margin, title, row float64
+	widths             []float64
+	rowsPerPage        int
+}
+
+// newQSOTable returns the geometry of the QSO table on page
+func newQSOTable(page PageSetup, width, height float64) qsoTable {
+	perUnit := mmPerUnit[page.unit()]
+	table := qsoTable{margin: tableMargin / perUnit, title: tableTitle / perUnit, row: tableRowHeight / perUnit}
+
+	var total float64
+	for _, column := range tableColumns {
+		total += column.width
+	}
+	for _, column := range tableColumns {
+		table.widths = append(table.widths, column.width*(width-2*table.margin)/total)
+	}
+
+	table.rowsPerPage = int((height - 2*table.margin - table.title - table.row) / table.row)
+	if table.rowsPerPage < 1 {
+		table.rowsPerPage = 1
+	}
+	return table
+}
+
+// PrintPDFV5 method renders every attribute of identity with the layout of
+// imageCertTemplate as PrintPDFV4 resolves it, see PrintPDFIdentity
+func (tool Tools) PrintPDFV5(certNumbers []string, identity models.Identity, templatePath, fileType string, w io.Writer, imageCertTemplate models.ImageCertTemplate, multiPage MultiPage) error {
+	layout, err := tool.layoutFor(imageCertTemplate)
+	if err != nil {
+		return err
+	}
+
+	return tool.PrintPDFIdentity(certNumbers, identity, templatePath, fileType, w, layout, multiPage)
+}
+
+// PrintPDFIdentity method renders every attribute of identity into a single
+// PDF, certNumbers holds the certificate number of each attribute, in
+// attribute order. PagePerAttribute prints one certificate per attribute,
+// PageQSOTable prints the certificate of the first attribute and the QSOs
+// in date order on the pages after it.
+func (tool Tools) PrintPDFIdentity(certNumbers []string, identity models.Identity, templatePath, fileType string, w io.Writer, layout CertLayout, multiPage MultiPage) error {
+	if len(identity.Attributes) == 0 {
+		return errors.New("identity has no attribute")
+	}
+	certNumber := func(i int) string {
+		if i < len(certNumbers) {
+			return certNumbers[i]
+		}
+		return ""
+	}
+
+	switch multiPage {
+	case PagePerAttribute:
+		pages := make([]CertPage, len(identity.Attributes))
+		for i := range identity.Attributes {
+			pages[i] = CertPage{CertNumber: certNumber(i), Identity: identity, IdentityIndex: i}
+		}
+		return tool.PrintPDFPages(pages, templatePath, fileType, w, layout)
+	case PageQSOTable:
+	default:
+		return fmt.Errorf("unknown multi-page layout %q", multiPage)
+	}
+
+	rows := make([]CertValues, len(identity.Attributes))
+	for i := range identity.Attributes {
+		values, err := NewCertValues(certNumber(i), identity, i)
+		if err != nil {
+			return err
+		}
+		rows[i] = values
+	}
+	first := rows[0]
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Date.Before(rows[j].Date)
+	})
+
+	pdf, width, height, err := layout.Page.newPDF()
+	if err != nil {
+		return err
+	}
+	pdf.SetAutoPageBreak(false, 0)
+	layout.addFonts(pdf)
+
+	// the template is drawn on the certificate page only, the table pages
+	// are plain
+	certPage := true
+	pdf.SetHeaderFunc(func() {
+		if certPage {
+			layout.Page.drawBackground(pdf, templatePath, fileType, width, height)
+			layout.draw(pdf, first)
+		}
+	})
+	pdf.AddPage()
+
+	certPage = false
+	table := newQSOTable(layout.Page, width, height)
+	tablePages := (len(rows) + table.rowsPerPage - 1) / table.rowsPerPage
+	for page := 0; page < tablePages; page++ {
+		pdf.AddPage()
+		start := page * table.rowsPerPage
+		end := start + table.rowsPerPage
+		if end > len(rows) {
+			end = len(rows)
+		}
+		table.draw(pdf, first, rows[start:end], start, page+1, tablePages)
+	}
+
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("error creating pdf: %w", err)
+	}
+	return nil
+}
+
+// draw renders one page of the QSO table, rows numbered from start+1
+func (table qsoTable) draw(pdf *gofpdf.Fpdf, station CertValues, rows []CertValues, start, page, pages int) {
+	title := "QSO log of " + station.CallSign
+	if station.Name != "" {
+		title += " - " + station.Name
+	}
+	if pages > 1 {
+		title += fmt.Sprintf(" (page %d of %d)", page, pages)
+	}
+
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont(tableFont, "B", tableFontSize+2)
+	pdf.SetXY(table.margin, table.margin)
+	pdf.CellFormat(0, table.title, title, "", 1, "L", false, 0, "")
+
+	pdf.SetFont(tableFont, "B", tableFontSize)
+	pdf.SetFillColor(230, 230, 230)
+	pdf.SetX(table.margin)
+	for c, column := range tableColumns {
+		pdf.CellFormat(table.widths[c], table.row, column.heading, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(table.row)
+
+	pdf.SetFont(tableFont, "", tableFontSize)
+	for i, row := range rows {
+		cells := []string{
+			strconv.Itoa(start + i + 1),
+			row.Date.Format(defaultDateFormat),
+			row.Date.Format(defaultUTCFormat),
+			row.Frequency,
+			row.Band,
+			row.Mode,
+			row.RST,
+			row.CertNumber,
+		}
+		pdf.SetX(table.margin)
+		for c := range tableColumns {
+			pdf.CellFormat(table.widths[c], table.row, cells[c], "1", 0, "C", false, 0, "")
+		}
+		pdf.Ln(table.row)
+	}
+}
@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+)
+
+// pagesIdentity returns YB1BBB with 30 QSOs on 40m, the latest first
+func pagesIdentity() models.Identity {
+	date := time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+	identity := models.Identity{CallSign: "YB1BBB", Name: "Budi"}
+	for i := 0; i < 30; i++ {
+		millis := date.Add(time.Duration(30-i)*time.Hour).UnixNano() / int64(time.Millisecond)
+		identity.Attributes = append(identity.Attributes, models.IdentityAttribute{Frequency: "7.135", Band: "40m", Mode: "SSB", Date: strconv.FormatInt(millis, 10)})
+	}
+	return identity
+}
+
+func TestPrintPDFIdentity(t *testing.T) {
+	templatePath := writeTemplate(t)
+	layout := CertLayout{Name: "pages", Fields: []CertField{{Source: FieldCallSign, FontName: "Helvetica", FontSize: 20}}}
+
+	for _, c := range []struct {
+		multiPage MultiPage
+		pages     int
+	}{
+		{PagePerAttribute, 30},
+		// the certificate and the table of 30 QSOs on 2 pages
+		{PageQSOTable, 3},
+	} {
+		t.Run(string(c.multiPage), func(t *testing.T) {
+			var pdf bytes.Buffer
+			if err := (Tools{}).PrintPDFIdentity([]string{"0001", "0002"}, pagesIdentity(), templatePath, "PNG", &pdf, layout, c.multiPage); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Count(pdf.String(), "<</Type /Page\n"); got != c.pages {
+				t.Fatalf("got %d pages, want %d", got, c.pages)
+			}
+		})
+	}
+}
+
+func TestPrintPDFIdentityUnknownField(t *testing.T) {
+	layout := CertLayout{Name: "pages", Fields: []CertField{{Source: "qth", FontName: "Helvetica", FontSize: 20}}}
+	err := (Tools{}).PrintPDFIdentity([]string{"0001"}, pagesIdentity(), writeTemplate(t), "PNG", &bytes.Buffer{}, layout, PageQSOTable)
+	if err == nil || !strings.Contains(err.Error(), "qth") {
+		t.Fatalf("got %v, want the unknown field reported", err)
+	}
+}
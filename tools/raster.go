@@ -0,0 +1,341 @@
+package tools
+
+import (
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // template images
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// CertFormat type is the file format a certificate is rendered to
+type CertFormat string
+
+// Certificate formats
+const (
+	CertPDF  CertFormat = "pdf"
+	CertPNG  CertFormat = "png"
+	CertJPEG CertFormat = "jpeg"
+)
+
+// MaxRasterPixels is the largest PNG or JPEG certificate, an A4 page at 600 dpi
+const MaxRasterPixels = 36000000
+
+// default raster settings
+const (
+	defaultDPI         = 150
+	defaultJPEGQuality = 90
+	// cellMargin is the space gofpdf leaves between a cell edge and left or
+	// right aligned text, in pt
+	cellMargin = 2.835
+)
+
+// CertOutput type selects the format of a rendered certificate, the zero
+// value is a PDF
+type CertOutput struct {
+	Format CertFormat `json:"format,omitempty" bson:"format,omitempty"`
+	// DPI is the resolution of a PNG or JPEG, 150 when 0
+	DPI float64 `json:"dpi,omitempty" bson:"dpi,omitempty"`
+	// Quality is the quality of a JPEG from 1 to 100, 90 when 0
+	Quality int `json:"quality,omitempty" bson:"quality,omitempty"`
+}
+
+// Validate method
+func (output CertOutput) Validate() error {
+	switch output.Format {
+	case "", CertPDF, CertPNG, CertJPEG:
+	default:
+		return fmt.Errorf("unknown certificate format %q", output.Format)
+	}
+	if output.DPI < 0 || output.DPI > 1200 {
+		return fmt.Errorf("dpi %g out of range", output.DPI)
+	}
+	if output.Quality < 0 || output.Quality > 100 {
+		return fmt.Errorf("jpeg quality %d out of range", output.Quality)
+	}
+	return nil
+}
+
+// dpi returns the resolution of the output
+func (output CertOutput) dpi() float64 {
+	if output.DPI == 0 {
+		return defaultDPI
+	}
+	return output.DPI
+}
+
+// Raster method reports whether the output is an image rather than a PDF
+func (output CertOutput) Raster() bool {
+	return output.Format == CertPNG || output.Format == CertJPEG
+}
+
+// Ext method returns the file name extension of the output
+func (output CertOutput) Ext() string {
+	switch output.Format {
+	case CertPNG:
+		return ".png"
+	case CertJPEG:
+		return ".jpg"
+	}
+	return ".pdf"
+}
+
+// ContentType method returns the MIME type of the output
+func (output CertOutput) ContentType() string {
+	switch output.Format {
+	case CertPNG:
+		return "image/png"
+	case CertJPEG:
+		return "image/jpeg"
+	}
+	return "application/pdf"
+}
+
+// PrintCertificate method renders one certificate as a PDF, PNG or JPEG, as
+// output says
+func (tool Tools) PrintCertificate(certNumber string, identity models.Identity, identityIndex int, templatePath, fileType string, w io.Writer, layout CertLayout, output CertOutput) error {
+	if !output.Raster() {
+		if err := output.Validate(); err != nil {
+			return err
+		}
+		return tool.PrintPDFLayout(certNumber, identity, identityIndex, templatePath, fileType, w, layout)
+	}
+	return tool.PrintImageLayout(certNumber, identity, identityIndex, templatePath, w, layout, output)
+}
+
+// PrintImageLayout method renders the fields of layout on top of the
+// template image into a PNG or JPEG, laid out as PrintPDFLayout does
+func (tool Tools) PrintImageLayout(certNumber string, identity models.Identity, identityIndex int, templatePath string, w io.Writer, layout CertLayout, output CertOutput) error {
+	if err := output.Validate(); err != nil {
+		return err
+	}
+	if !output.Raster() {
+		return fmt.Errorf("%q is not an image format", output.Format)
+	}
+
+	values, err := NewCertValues(certNumber, identity, identityIndex)
+	if err != nil {
+		return err
+	}
+	img, err := layout.rasterize(templatePath, values, output.dpi())
+	if err != nil {
+		return err
+	}
+
+	if output.Format == CertPNG {
+		return png.Encode(w, img)
+	}
+	quality := output.Quality
+	if quality == 0 {
+		quality = defaultJPEGQuality
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
+
+// RasterSize method returns the size in pixels of the page of layout
+// rendered as output, it fails above MaxRasterPixels
+func (layout CertLayout) RasterSize(output CertOutput) (width, height int, err error) {
+	return layout.rasterSize(output.dpi())
+}
+
+// rasterSize returns the size in pixels of the page at dpi
+func (layout CertLayout) rasterSize(dpi float64) (width, height int, err error) {
+	pageWidth, pageHeight, err := layout.Page.Dimensions()
+	if err != nil {
+		return 0, 0, err
+	}
+	scale := mmPerUnit[layout.Page.unit()] / 25.4 * dpi
+	width, height = int(math.Round(pageWidth*scale)), int(math.Round(pageHeight*scale))
+	if width*height > MaxRasterPixels {
+		return 0, 0, fmt.Errorf("a %dx%d image is larger than %d pixels", width, height, MaxRasterPixels)
+	}
+	return width, height, nil
+}
+
+// rasterize draws the template image and the fields of layout at dpi
+func (layout CertLayout) rasterize(templatePath string, values CertValues, dpi float64) (*image.RGBA, error) {
+	pixelWidth, pixelHeight, err := layout.rasterSize(dpi)
+	if err != nil {
+		return nil, err
+	}
+	width, height, _ := layout.Page.Dimensions()
+	// pixels per unit of the page and per pt
+	scale := mmPerUnit[layout.Page.unit()] / 25.4 * dpi
+	pt := dpi / 72
+
+	img := image.NewRGBA(image.Rect(0, 0, pixelWidth, pixelHeight))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	template, err := decodeImage(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	bounds := template.Bounds()
+	x, y, w, h := layout.Page.backgroundRect(float64(bounds.Dx()), float64(bounds.Dy()), width, height)
+	rect := image.Rect(int(math.Round(x*scale)), int(math.Round(y*scale)), int(math.Round((x+w)*scale)), int(math.Round((y+h)*scale)))
+	draw.CatmullRom.Scale(img, rect, template, bounds, draw.Over, nil)
+
+	fonts := map[string]*opentype.Font{}
+	for _, field := range layout.Fields {
+		text, err := field.Text(values)
+		if err != nil {
+			return nil, err
+		}
+		if text == "" {
+			continue
+		}
+
+		key := field.FontDir + "/" + field.FontName
+		f, ok := fonts[key]
+		if !ok {
+			if f, err = loadFont(field); err != nil {
+				return nil, err
+			}
+			fonts[key] = f
+		}
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: field.FontSize, DPI: dpi, Hinting: font.HintingNone})
+		if err != nil {
+			return nil, fmt.Errorf("font %s: %w", field.FontName, err)
+		}
+
+		cellWidth, cellHeight := field.Width, field.Height
+		if cellWidth == 0 {
+			cellWidth = 10
+		}
+		if cellHeight == 0 {
+			cellHeight = 10
+		}
+		cellWidth, cellHeight = cellWidth*scale, cellHeight*scale
+		fontSize := field.FontSize * pt
+
+		// the text is placed in its cell the way gofpdf.CellFormat places it
+		drawer := font.Drawer{
+			Dst:  img,
+			Src:  image.NewUniform(color.RGBA{R: uint8(field.FontColor.R), G: uint8(field.FontColor.G), B: uint8(field.FontColor.B), A: 255}),
+			Face: unkerned{face},
+		}
+		textWidth := float64(drawer.MeasureString(text)) / 64
+		dx := cellMargin * pt
+		switch {
+		case strings.Contains(field.TextAlign, "R"):
+			dx = cellWidth - cellMargin*pt - textWidth
+		case strings.Contains(field.TextAlign, "C"):
+			dx = (cellWidth - textWidth) / 2
+		}
+		var dy float64
+		switch {
+		case strings.Contains(field.TextAlign, "T"):
+			dy = (fontSize - cellHeight) / 2
+		case strings.Contains(field.TextAlign, "B"):
+			dy = (cellHeight - fontSize) / 2
+		}
+
+		drawer.Dot = fixed.Point26_6{
+			X: fixed.Int26_6(math.Round((field.Position.X*scale + dx) * 64)),
+			Y: fixed.Int26_6(math.Round((field.Position.Y*scale + dy + cellHeight/2 + 0.3*fontSize) * 64)),
+		}
+		drawer.DrawString(text)
+		face.Close()
+	}
+	return img, nil
+}
+
+// unkerned is a font face without kerning, as gofpdf prints text
+type unkerned struct {
+	font.Face
+}
+
+// Kern method
+func (unkerned) Kern(r0, r1 rune) fixed.Int26_6 {
+	return 0
+}
+
+// decodeImage reads a PNG, JPEG or GIF template image
+func decodeImage(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// loadFont returns the TrueType or OpenType font of field: FontName.ttf or
+// FontName.otf in FontDir, else the font file embedded by the gofpdf font
+// definition FontName.json the PDF renderers use. The core PDF fonts are
+// drawn with the Go fonts.
+func loadFont(field CertField) (*opentype.Font, error) {
+	switch strings.ToLower(field.FontName) {
+	case "courier":
+		return opentype.Parse(gomono.TTF)
+	case "helvetica", "arial", "times", "symbol", "zapfdingbats":
+		return opentype.Parse(goregular.TTF)
+	}
+
+	base := filepath.Join(field.FontDir, field.FontName)
+	for _, ext := range []string{".ttf", ".otf"} {
+		if data, err := os.ReadFile(base + ext); err == nil {
+			return parseFont(field.FontName, data)
+		}
+	}
+
+	definition, err := os.ReadFile(base + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("font %s: %w", field.FontName, err)
+	}
+	var def struct {
+		File string
+	}
+	if err := json.Unmarshal(definition, &def); err != nil {
+		return nil, fmt.Errorf("font %s: %w", field.FontName, err)
+	}
+	if def.File == "" {
+		return nil, fmt.Errorf("font %s: the font file is not embedded", field.FontName)
+	}
+
+	compressed, err := os.Open(filepath.Join(field.FontDir, def.File))
+	if err != nil {
+		return nil, fmt.Errorf("font %s: %w", field.FontName, err)
+	}
+	defer compressed.Close()
+	reader, err := zlib.NewReader(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("font %s: %w", field.FontName, err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("font %s: %w", field.FontName, err)
+	}
+	return parseFont(field.FontName, data)
+}
+
+// parseFont parses the font file data of the font name
+func parseFont(name string, data []byte) (*opentype.Font, error) {
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("font %s: %w", name, err)
+	}
+	return f, nil
+}
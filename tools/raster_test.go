@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// writeFonts writes a TrueType file and a font compressed by the gofpdf
+// font maker and returns their directory
+func writeFonts(t *testing.T) string {
+	t.Helper()
+	fontDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(fontDir, "GoRegular.ttf"), goregular.TTF, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(gomono.TTF)
+	zw.Close()
+	if err := os.WriteFile(filepath.Join(fontDir, "GoMono.z"), compressed.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(fontDir, "GoMono.json"), []byte(`{"Tp":"TrueType","File":"GoMono.z"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return fontDir
+}
+
+// rasterLayout returns a QSL card layout with the call sign in red in
+// GoRegular and the number in blue in GoMono
+func rasterLayout(t *testing.T) CertLayout {
+	t.Helper()
+	fontDir := writeFonts(t)
+	return CertLayout{Name: "raster", Page: PageSetup{Size: PageQSL}, Fields: []CertField{
+		{Source: FieldCallSign, FontDir: fontDir, FontName: "GoRegular", FontSize: 40, FontColor: CertColor{R: 255}, Position: CertPosition{X: 10, Y: 30}, Width: 120, TextAlign: "C"},
+		{Source: FieldCertNumber, FontDir: fontDir, FontName: "GoMono", FontSize: 12, FontColor: CertColor{B: 255}, Position: CertPosition{X: 10, Y: 70}},
+	}}
+}
+
+// renderImage renders the certificate of testIdentity and decodes it
+func renderImage(t *testing.T, layout CertLayout, output CertOutput) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := (Tools{}).PrintCertificate("0001", testIdentity, 0, writeTemplate(t), "PNG", &buf, layout, output); err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := image.Decode(&buf)
+	if err != nil || format != string(output.Format) {
+		t.Fatalf("got %s (%v), want %s", format, err, output.Format)
+	}
+	return img
+}
+
+func TestPrintCertificateSize(t *testing.T) {
+	layout := rasterLayout(t)
+	// 140×90 mm
+	for _, c := range []struct {
+		output        CertOutput
+		width, height int
+	}{
+		{CertOutput{Format: CertPNG, DPI: 100}, 551, 354},
+		{CertOutput{Format: CertJPEG, DPI: 50, Quality: 80}, 276, 177},
+	} {
+		t.Run(string(c.output.Format), func(t *testing.T) {
+			if bounds := renderImage(t, layout, c.output).Bounds(); bounds.Dx() != c.width || bounds.Dy() != c.height {
+				t.Fatalf("got %v, want %dx%d", bounds, c.width, c.height)
+			}
+		})
+	}
+}
+
+func TestPrintCertificateColors(t *testing.T) {
+	img := renderImage(t, rasterLayout(t), CertOutput{Format: CertPNG, DPI: 100})
+	var red, blue int
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			switch {
+			case r > 0xc000 && g < 0x4000 && b < 0x4000:
+				red++
+			case b > 0xc000 && r < 0x4000 && g < 0x4000:
+				blue++
+			}
+		}
+	}
+	if red == 0 {
+		t.Error("got no red pixel, want the call sign drawn")
+	}
+	if blue == 0 {
+		t.Error("got no blue pixel, want the number drawn")
+	}
+}
+
+func TestPrintCertificatePDF(t *testing.T) {
+	// the PDF renderers need gofpdf font definitions, use a core font
+	layout := CertLayout{Name: "pdf", Page: PageSetup{Size: PageQSL}, Fields: []CertField{{Source: FieldCallSign, FontName: "Helvetica", FontSize: 40}}}
+	var pdf bytes.Buffer
+	if err := (Tools{}).PrintCertificate("0001", testIdentity, 0, writeTemplate(t), "PNG", &pdf, layout, CertOutput{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(pdf.Bytes(), []byte("%PDF")) {
+		t.Fatalf("got %d bytes without the %%PDF header, want a PDF by default", pdf.Len())
+	}
+}
+
+func TestPrintCertificateUnknownFormat(t *testing.T) {
+	if err := (Tools{}).PrintCertificate("0001", testIdentity, 0, writeTemplate(t), "PNG", &bytes.Buffer{}, rasterLayout(t), CertOutput{Format: "bmp"}); err == nil {
+		t.Fatal("want an unknown format rejected")
+	}
+}
+
+func TestRasterSize(t *testing.T) {
+	layout := CertLayout{Page: PageSetup{Size: PageA4, Orientation: Portrait}}
+	for _, c := range []struct {
+		dpi           float64
+		width, height int
+		ok            bool
+	}{
+		{600, 4961, 7016, true},
+		{1200, 0, 0, false},
+	} {
+		width, height, err := layout.RasterSize(CertOutput{Format: CertPNG, DPI: c.dpi})
+		if (err == nil) != c.ok || width != c.width || height != c.height {
+			t.Errorf("A4 at %g dpi: got %dx%d (%v), want %dx%d", c.dpi, width, height, err, c.width, c.height)
+		}
+	}
+}
+
+func TestPrintImageLayoutTooLarge(t *testing.T) {
+	// the image is refused before it is allocated
+	layout := CertLayout{Page: PageSetup{Width: 1200, Height: 1200}, Fields: []CertField{{Source: FieldCallSign, FontName: "Helvetica"}}}
+	if err := (Tools{}).PrintImageLayout("0001", testIdentity, 0, "missing.png", &bytes.Buffer{}, layout, CertOutput{Format: CertPNG, DPI: 1200}); err == nil {
+		t.Fatal("want a 1200 mm page at 1200 dpi rejected")
+	}
+}
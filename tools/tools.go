@@ -2,6 +2,7 @@ package tools
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -19,6 +20,7 @@ import (
 
 	"gitlab.com/yosiaagustadewa/qsl-service/models"
 
+	"github.com/agustadewa/gomongo/bandplan"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator"
 	"github.com/jung-kurt/gofpdf"
@@ -27,8 +29,33 @@ import (
 
 var validate *validator.Validate
 
+// LayoutSource type resolves the layout a template is rendered with, see
+// gomongo.Adaptor.CertLayoutFor
+type LayoutSource interface {
+	CertLayoutFor(ctx context.Context, imageCertTemplate models.ImageCertTemplate) (CertLayout, error)
+}
+
 // Tools type
-type Tools struct{}
+type Tools struct {
+	// Layouts resolves the stored layout of a template, the built in TYPE 1 …
+	// TYPE 5 layouts are used when it is nil
+	Layouts LayoutSource
+}
+
+// layoutFor returns the layout of imageCertTemplate
+func (tool Tools) layoutFor(imageCertTemplate models.ImageCertTemplate) (CertLayout, error) {
+	if tool.Layouts == nil {
+		return LegacyLayout(imageCertTemplate)
+	}
+	return tool.Layouts.CertLayoutFor(context.Background(), imageCertTemplate)
+}
+
+// legacyFrequencies are the frequencies PrintPDF and PrintPDFV2 print for a
+// band, the stations of the events they were written for
+var legacyFrequencies = map[string]bandplan.Frequency{
+	"40m": 7135000,
+	"2m":  145240000,
+}
 
 // PrintPDF method
 func (tool Tools) PrintPDF(name, callSign, band, templatePath, outPath, fileType string) error {
@@ -54,12 +81,13 @@ func (tool Tools) PrintPDF(name, callSign, band, templatePath, outPath, fileType
 
 		pdf.SetFont("ATOMICCLOCKRADIO", "", 23)
 		pdf.SetTextColor(255, 255, 255)
-		if band == "40 m" {
-			pdf.SetXY(131, 43)
-			pdf.Cell(10, 10, "7.135")
-		} else if band == "2 m" {
-			pdf.SetXY(119, 43)
-			pdf.Cell(10, 10, "145.240")
+		if frequency, ok := legacyFrequencies[bandplan.NormalizeBand(band)]; ok {
+			x := 131.0
+			if frequency.In(bandplan.MHz) >= 100 {
+				x = 119
+			}
+			pdf.SetXY(x, 43)
+			pdf.Cell(10, 10, frequency.String())
 		}
 	})
 
@@ -97,12 +125,9 @@ func (tool Tools) PrintPDFV2(name, callSign, band, templatePath, fileType string
 		// FREQUENCY
 		pdf.SetFont("OrangeTypewriter", "", 16)
 		pdf.SetTextColor(0, 0, 0)
-		if band == "40 m" {
+		if frequency, ok := legacyFrequencies[bandplan.NormalizeBand(band)]; ok {
 			pdf.SetXY(279, 23)
-			pdf.CellFormat(10, 10, "7.135 MHz", "", 0, "R", false, 0, "")
-		} else if band == "2 m" {
-			pdf.SetXY(279, 23)
-			pdf.CellFormat(10, 10, "145.240 MHz", "", 0, "R", false, 0, "")
+			pdf.CellFormat(10, 10, frequency.String()+" MHz", "", 0, "R", false, 0, "")
 		}
 	})
 
@@ -155,354 +180,60 @@ func (tool Tools) PrintPDFV3(name, callSign, band, frequency, templatePath, file
 	return err
 }
 
-// PrintPDFV4 method
+// PrintPDFV4 method renders the stored layout of the template type of
+// imageCertTemplate, or its built in layout when none is stored
 func (tool Tools) PrintPDFV4(certNumber string, identity models.Identity, identityIndex int, templatePath, fileType string, w io.Writer, imageCertTemplate models.ImageCertTemplate) error {
-	identityAttribute := identity.Attributes[identityIndex]
+	layout, err := tool.layoutFor(imageCertTemplate)
+	if err != nil {
+		return err
+	}
 
-	pdf := gofpdf.New("L", "mm", "A4", "")
+	return tool.PrintPDFLayout(certNumber, identity, identityIndex, templatePath, fileType, w, layout)
+}
 
-	addFont := func(fontName string) {
-		if fontName != "" {
-			pdf.SetFontLocation(imageCertTemplate.TemplateProperties.CallSign.FontDir)
-			pdf.AddFont(fontName, "", fmt.Sprintf("%s.json", fontName))
-		}
-	}
+// PrintPDFLayout method renders the fields of layout on top of the template image
+func (tool Tools) PrintPDFLayout(certNumber string, identity models.Identity, identityIndex int, templatePath, fileType string, w io.Writer, layout CertLayout) error {
+	return tool.PrintPDFPages([]CertPage{{CertNumber: certNumber, Identity: identity, IdentityIndex: identityIndex}}, templatePath, fileType, w, layout)
+}
 
-	addFont(imageCertTemplate.TemplateProperties.CallSign.FontName)
-	addFont(imageCertTemplate.TemplateProperties.CallSign2.FontName)
-	addFont(imageCertTemplate.TemplateProperties.IdentityName.FontName)
-	addFont(imageCertTemplate.TemplateProperties.CertificateNumber.FontName)
-	addFont(imageCertTemplate.TemplateProperties.Date.FontName)
-	addFont(imageCertTemplate.TemplateProperties.UTC.FontName)
-	addFont(imageCertTemplate.TemplateProperties.Band.FontName)
-	addFont(imageCertTemplate.TemplateProperties.Mode.FontName)
-	addFont(imageCertTemplate.TemplateProperties.RST.FontName)
-	addFont(imageCertTemplate.TemplateProperties.Frequency.FontName)
-
-	var handler func(imageCertTemplate models.ImageCertTemplate) func()
-
-	if imageCertTemplate.TemplateProperties.TemplateType == "TYPE 1" {
-		handler = func(imageCertTemplate models.ImageCertTemplate) func() {
-			return func() {
-				pdf.ImageOptions(templatePath, 0, 0, 297, 210, false, gofpdf.ImageOptions{ImageType: fileType, ReadDpi: true}, 0, "")
-
-				// CALL SIGN
-				pdf.SetFont(imageCertTemplate.TemplateProperties.CallSign.FontName, "", imageCertTemplate.TemplateProperties.CallSign.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.CallSign.TextPosition.X, imageCertTemplate.TemplateProperties.CallSign.TextPosition.Y)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.CallSign.FontColor.R, imageCertTemplate.TemplateProperties.CallSign.FontColor.G, imageCertTemplate.TemplateProperties.CallSign.FontColor.B)
-				pdf.CellFormat(40, 10, identity.CallSign, "", 0, imageCertTemplate.TemplateProperties.CallSign.TextAlign, false, 0, "")
-
-				// NAME
-				pdf.SetFont(imageCertTemplate.TemplateProperties.IdentityName.FontName, "", imageCertTemplate.TemplateProperties.IdentityName.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.IdentityName.TextPosition.X, imageCertTemplate.TemplateProperties.IdentityName.TextPosition.Y)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.IdentityName.FontColor.R, imageCertTemplate.TemplateProperties.IdentityName.FontColor.G, imageCertTemplate.TemplateProperties.IdentityName.FontColor.B)
-				pdf.CellFormat(10, 10, identity.Name, "", 0, imageCertTemplate.TemplateProperties.IdentityName.TextAlign, false, 0, "")
-
-				// FREQUENCY
-				pdf.SetFont(imageCertTemplate.TemplateProperties.Frequency.FontName, "", imageCertTemplate.TemplateProperties.Frequency.FontSize)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.Frequency.FontColor.R, imageCertTemplate.TemplateProperties.Frequency.FontColor.G, imageCertTemplate.TemplateProperties.Frequency.FontColor.B)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.Frequency.TextPosition.X, imageCertTemplate.TemplateProperties.Frequency.TextPosition.Y)
-				pdf.CellFormat(10, 10, fmt.Sprintf("%s - %s", identityAttribute.Frequency, identityAttribute.Band), "", 0, imageCertTemplate.TemplateProperties.Frequency.TextAlign, false, 0, "")
-			}
-		}
-	} else if imageCertTemplate.TemplateProperties.TemplateType == "TYPE 2" {
-		handler = func(imageCertTemplate models.ImageCertTemplate) func() {
-			return func() {
-				// pdf.Image("./assets/templates/template1.jpg", 0, 0, 297, 200, true, "", 0, "")
-				pdf.ImageOptions(templatePath, 0, 0, 297, 210, false, gofpdf.ImageOptions{ImageType: fileType, ReadDpi: true}, 0, "")
-
-				// CALL SIGN
-				pdf.SetFont(imageCertTemplate.TemplateProperties.CallSign.FontName, "", imageCertTemplate.TemplateProperties.CallSign.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.CallSign.TextPosition.X, imageCertTemplate.TemplateProperties.CallSign.TextPosition.Y)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.CallSign.FontColor.R, imageCertTemplate.TemplateProperties.CallSign.FontColor.G, imageCertTemplate.TemplateProperties.CallSign.FontColor.B)
-				pdf.CellFormat(40, 10, identity.CallSign, "", 0, imageCertTemplate.TemplateProperties.CallSign.TextAlign, false, 0, "")
-
-				// NAME
-				pdf.SetFont(imageCertTemplate.TemplateProperties.IdentityName.FontName, "", imageCertTemplate.TemplateProperties.IdentityName.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.IdentityName.TextPosition.X, imageCertTemplate.TemplateProperties.IdentityName.TextPosition.Y)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.IdentityName.FontColor.R, imageCertTemplate.TemplateProperties.IdentityName.FontColor.G, imageCertTemplate.TemplateProperties.IdentityName.FontColor.B)
-				pdf.CellFormat(10, 10, identity.Name, "", 0, imageCertTemplate.TemplateProperties.IdentityName.TextAlign, false, 0, "")
-
-				// FREQUENCY
-				pdf.SetFont(imageCertTemplate.TemplateProperties.Frequency.FontName, "", imageCertTemplate.TemplateProperties.Frequency.FontSize)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.Frequency.FontColor.R, imageCertTemplate.TemplateProperties.Frequency.FontColor.G, imageCertTemplate.TemplateProperties.Frequency.FontColor.B)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.Frequency.TextPosition.X, imageCertTemplate.TemplateProperties.Frequency.TextPosition.Y)
-				pdf.CellFormat(10, 10, fmt.Sprintf("%s - %s", identityAttribute.Frequency, identityAttribute.Band), "", 0, imageCertTemplate.TemplateProperties.Frequency.TextAlign, false, 0, "")
-
-				numericFullDate, _ := strconv.ParseInt(identityAttribute.Date, 10, 64)
-				fullDate := time.Unix(numericFullDate/1000, 0).UTC()
-
-				// DATE
-				simpleDate := fullDate.Format("02 Jan 2006")
-				// fmt.Println(numericFullDate, simpleDate)
-
-				pdf.SetFont(imageCertTemplate.TemplateProperties.Date.FontName, "", imageCertTemplate.TemplateProperties.Date.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.Date.TextPosition.X, imageCertTemplate.TemplateProperties.Date.TextPosition.Y)
-				dateFontColor := imageCertTemplate.TemplateProperties.Date.FontColor
-				pdf.SetTextColor(dateFontColor.R, dateFontColor.G, dateFontColor.B)
-				pdf.CellFormat(10, 10, simpleDate, "", 0, imageCertTemplate.TemplateProperties.Date.TextAlign, false, 0, "")
-
-				// UTC
-				simpleUTCTime := fullDate.Format("15:04")
-				// fmt.Println(simpleUTCTime)
-
-				pdf.SetFont(imageCertTemplate.TemplateProperties.UTC.FontName, "", imageCertTemplate.TemplateProperties.UTC.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.UTC.TextPosition.X, imageCertTemplate.TemplateProperties.UTC.TextPosition.Y)
-				utcFontColor := imageCertTemplate.TemplateProperties.UTC.FontColor
-				pdf.SetTextColor(utcFontColor.R, utcFontColor.G, utcFontColor.B)
-				pdf.CellFormat(10, 10, simpleUTCTime, "", 0, imageCertTemplate.TemplateProperties.UTC.TextAlign, false, 0, "")
-
-				// BAND
-				// fmt.Println(identityAttribute.Band)
-
-				pdf.SetFont(imageCertTemplate.TemplateProperties.Band.FontName, "", imageCertTemplate.TemplateProperties.Band.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.Band.TextPosition.X, imageCertTemplate.TemplateProperties.Band.TextPosition.Y)
-				bandFontColor := imageCertTemplate.TemplateProperties.Band.FontColor
-				pdf.SetTextColor(bandFontColor.R, bandFontColor.G, bandFontColor.B)
-				pdf.CellFormat(10, 10, identityAttribute.Band, "", 0, imageCertTemplate.TemplateProperties.Band.TextAlign, false, 0, "")
-
-				// MODE
-				// fmt.Println(identityAttribute.Mode)
-
-				pdf.SetFont(imageCertTemplate.TemplateProperties.Mode.FontName, "", imageCertTemplate.TemplateProperties.Mode.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.Mode.TextPosition.X, imageCertTemplate.TemplateProperties.Mode.TextPosition.Y)
-				modeFontColor := imageCertTemplate.TemplateProperties.Mode.FontColor
-				pdf.SetTextColor(modeFontColor.R, modeFontColor.G, modeFontColor.B)
-				pdf.CellFormat(10, 10, identityAttribute.Mode, "", 0, imageCertTemplate.TemplateProperties.Mode.TextAlign, false, 0, "")
-
-				// RST
-				// fmt.Println(identityAttribute.RST)
-
-				pdf.SetFont(imageCertTemplate.TemplateProperties.RST.FontName, "", imageCertTemplate.TemplateProperties.RST.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.RST.TextPosition.X, imageCertTemplate.TemplateProperties.RST.TextPosition.Y)
-				rstFontColor := imageCertTemplate.TemplateProperties.RST.FontColor
-				pdf.SetTextColor(rstFontColor.R, rstFontColor.G, rstFontColor.B)
-				pdf.CellFormat(10, 10, identityAttribute.RST, "", 0, imageCertTemplate.TemplateProperties.RST.TextAlign, false, 0, "")
-
-				// CERTIFICATE NUMBER
-				// fmt.Println(certNumber)
-				pdf.SetFont(imageCertTemplate.TemplateProperties.CertificateNumber.FontName, "", imageCertTemplate.TemplateProperties.CertificateNumber.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.CertificateNumber.TextPosition.X, imageCertTemplate.TemplateProperties.CertificateNumber.TextPosition.Y)
-				certificateNumberFontColor := imageCertTemplate.TemplateProperties.CertificateNumber.FontColor
-				pdf.SetTextColor(certificateNumberFontColor.R, certificateNumberFontColor.G, certificateNumberFontColor.B)
-				pdf.CellFormat(10, 10, certNumber, "", 0, imageCertTemplate.TemplateProperties.CertificateNumber.TextAlign, false, 0, "")
-			}
-		}
-	} else if imageCertTemplate.TemplateProperties.TemplateType == "TYPE 3" {
-		handler = func(imageCertTemplate models.ImageCertTemplate) func() {
-			return func() {
-				// pdf.Image("./assets/templates/template1.jpg", 0, 0, 297, 200, true, "", 0, "")
-				pdf.ImageOptions(templatePath, 0, 0, 297, 210, false, gofpdf.ImageOptions{ImageType: fileType, ReadDpi: true}, 0, "")
-
-				// CALL SIGN
-				pdf.SetFont(imageCertTemplate.TemplateProperties.CallSign.FontName, "", imageCertTemplate.TemplateProperties.CallSign.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.CallSign.TextPosition.X, imageCertTemplate.TemplateProperties.CallSign.TextPosition.Y)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.CallSign.FontColor.R, imageCertTemplate.TemplateProperties.CallSign.FontColor.G, imageCertTemplate.TemplateProperties.CallSign.FontColor.B)
-				pdf.CellFormat(40, 10, identity.CallSign, "", 0, imageCertTemplate.TemplateProperties.CallSign.TextAlign, false, 0, "")
-
-				// NAME
-				pdf.SetFont(imageCertTemplate.TemplateProperties.IdentityName.FontName, "", imageCertTemplate.TemplateProperties.IdentityName.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.IdentityName.TextPosition.X, imageCertTemplate.TemplateProperties.IdentityName.TextPosition.Y)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.IdentityName.FontColor.R, imageCertTemplate.TemplateProperties.IdentityName.FontColor.G, imageCertTemplate.TemplateProperties.IdentityName.FontColor.B)
-				pdf.CellFormat(10, 10, identity.Name, "", 0, imageCertTemplate.TemplateProperties.IdentityName.TextAlign, false, 0, "")
-
-				numericFullDate, _ := strconv.ParseInt(identityAttribute.Date, 10, 64)
-				fullDate := time.Unix(numericFullDate/1000, 0).UTC()
-
-				// DATE
-				simpleDate := fullDate.Format("02 Jan 2006")
-				// fmt.Println(numericFullDate, simpleDate)
-
-				pdf.SetFont(imageCertTemplate.TemplateProperties.Date.FontName, "", imageCertTemplate.TemplateProperties.Date.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.Date.TextPosition.X, imageCertTemplate.TemplateProperties.Date.TextPosition.Y)
-				dateFontColor := imageCertTemplate.TemplateProperties.Date.FontColor
-				pdf.SetTextColor(dateFontColor.R, dateFontColor.G, dateFontColor.B)
-				pdf.CellFormat(10, 10, simpleDate, "", 0, imageCertTemplate.TemplateProperties.Date.TextAlign, false, 0, "")
-
-				// UTC
-				simpleUTCTime := fullDate.Format("15:04")
-				// fmt.Println(simpleUTCTime)
-
-				pdf.SetFont(imageCertTemplate.TemplateProperties.UTC.FontName, "", imageCertTemplate.TemplateProperties.UTC.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.UTC.TextPosition.X, imageCertTemplate.TemplateProperties.UTC.TextPosition.Y)
-				utcFontColor := imageCertTemplate.TemplateProperties.UTC.FontColor
-				pdf.SetTextColor(utcFontColor.R, utcFontColor.G, utcFontColor.B)
-				pdf.CellFormat(10, 10, simpleUTCTime, "", 0, imageCertTemplate.TemplateProperties.UTC.TextAlign, false, 0, "")
-
-				// BAND
-				// fmt.Println(identityAttribute.Band)
-
-				pdf.SetFont(imageCertTemplate.TemplateProperties.Band.FontName, "", imageCertTemplate.TemplateProperties.Band.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.Band.TextPosition.X, imageCertTemplate.TemplateProperties.Band.TextPosition.Y)
-				bandFontColor := imageCertTemplate.TemplateProperties.Band.FontColor
-				pdf.SetTextColor(bandFontColor.R, bandFontColor.G, bandFontColor.B)
-				pdf.CellFormat(10, 10, identityAttribute.Band, "", 0, imageCertTemplate.TemplateProperties.Band.TextAlign, false, 0, "")
-
-				// FREQUENCY
-				pdf.SetFont(imageCertTemplate.TemplateProperties.Frequency.FontName, "", imageCertTemplate.TemplateProperties.Frequency.FontSize)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.Frequency.FontColor.R, imageCertTemplate.TemplateProperties.Frequency.FontColor.G, imageCertTemplate.TemplateProperties.Frequency.FontColor.B)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.Frequency.TextPosition.X, imageCertTemplate.TemplateProperties.Frequency.TextPosition.Y)
-				pdf.CellFormat(10, 10, identityAttribute.Frequency, "", 0, imageCertTemplate.TemplateProperties.Frequency.TextAlign, false, 0, "")
-
-				// MODE
-				// fmt.Println(identityAttribute.Mode)
-
-				pdf.SetFont(imageCertTemplate.TemplateProperties.Mode.FontName, "", imageCertTemplate.TemplateProperties.Mode.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.Mode.TextPosition.X, imageCertTemplate.TemplateProperties.Mode.TextPosition.Y)
-				modeFontColor := imageCertTemplate.TemplateProperties.Mode.FontColor
-				pdf.SetTextColor(modeFontColor.R, modeFontColor.G, modeFontColor.B)
-				pdf.CellFormat(10, 10, identityAttribute.Mode, "", 0, imageCertTemplate.TemplateProperties.Mode.TextAlign, false, 0, "")
-
-				// RST
-				// fmt.Println(identityAttribute.RST)
-
-				pdf.SetFont(imageCertTemplate.TemplateProperties.RST.FontName, "", imageCertTemplate.TemplateProperties.RST.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.RST.TextPosition.X, imageCertTemplate.TemplateProperties.RST.TextPosition.Y)
-				rstFontColor := imageCertTemplate.TemplateProperties.RST.FontColor
-				pdf.SetTextColor(rstFontColor.R, rstFontColor.G, rstFontColor.B)
-				pdf.CellFormat(10, 10, identityAttribute.RST, "", 0, imageCertTemplate.TemplateProperties.RST.TextAlign, false, 0, "")
-
-				// CERTIFICATE NUMBER
-				// fmt.Println(certNumber)
-				pdf.SetFont(imageCertTemplate.TemplateProperties.CertificateNumber.FontName, "", imageCertTemplate.TemplateProperties.CertificateNumber.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.CertificateNumber.TextPosition.X, imageCertTemplate.TemplateProperties.CertificateNumber.TextPosition.Y)
-				certificateNumberFontColor := imageCertTemplate.TemplateProperties.CertificateNumber.FontColor
-				pdf.SetTextColor(certificateNumberFontColor.R, certificateNumberFontColor.G, certificateNumberFontColor.B)
-				pdf.CellFormat(10, 10, certNumber, "", 0, imageCertTemplate.TemplateProperties.CertificateNumber.TextAlign, false, 0, "")
+// CertPage type is one certificate of PrintPDFPages
+type CertPage struct {
+	CertNumber    string
+	Identity      models.Identity
+	IdentityIndex int
+}
 
-			}
-		}
-	} else if imageCertTemplate.TemplateProperties.TemplateType == "TYPE 4" {
-		handler = func(imageCertTemplate models.ImageCertTemplate) func() {
-			return func() {
-				// pdf.Image("./assets/templates/template1.jpg", 0, 0, 297, 200, true, "", 0, "")
-				pdf.ImageOptions(templatePath, 0, 0, 297, 210, false, gofpdf.ImageOptions{ImageType: fileType, ReadDpi: true}, 0, "")
-
-				// CALL SIGN
-				pdf.SetFont(imageCertTemplate.TemplateProperties.CallSign.FontName, "", imageCertTemplate.TemplateProperties.CallSign.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.CallSign.TextPosition.X, imageCertTemplate.TemplateProperties.CallSign.TextPosition.Y)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.CallSign.FontColor.R, imageCertTemplate.TemplateProperties.CallSign.FontColor.G, imageCertTemplate.TemplateProperties.CallSign.FontColor.B)
-				pdf.CellFormat(40, 10, identity.CallSign, "", 0, imageCertTemplate.TemplateProperties.CallSign.TextAlign, false, 0, "")
-
-				// NAME
-				pdf.SetFont(imageCertTemplate.TemplateProperties.IdentityName.FontName, "", imageCertTemplate.TemplateProperties.IdentityName.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.IdentityName.TextPosition.X, imageCertTemplate.TemplateProperties.IdentityName.TextPosition.Y)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.IdentityName.FontColor.R, imageCertTemplate.TemplateProperties.IdentityName.FontColor.G, imageCertTemplate.TemplateProperties.IdentityName.FontColor.B)
-				pdf.CellFormat(10, 10, identity.Name, "", 0, imageCertTemplate.TemplateProperties.IdentityName.TextAlign, false, 0, "")
-
-				// CALL SIGN 2
-				pdf.SetFont(imageCertTemplate.TemplateProperties.CallSign2.FontName, "", imageCertTemplate.TemplateProperties.CallSign2.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.CallSign2.TextPosition.X, imageCertTemplate.TemplateProperties.CallSign2.TextPosition.Y)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.CallSign2.FontColor.R, imageCertTemplate.TemplateProperties.CallSign2.FontColor.G, imageCertTemplate.TemplateProperties.CallSign2.FontColor.B)
-				pdf.CellFormat(40, 10, identity.CallSign, "", 0, imageCertTemplate.TemplateProperties.CallSign2.TextAlign, false, 0, "")
-
-				numericFullDate, _ := strconv.ParseInt(identityAttribute.Date, 10, 64)
-				fullDate := time.Unix(numericFullDate/1000, 0).UTC()
-
-				// DATE
-				simpleDate := fullDate.Format("02 Jan 2006")
-				// fmt.Println(numericFullDate, simpleDate)
-
-				pdf.SetFont(imageCertTemplate.TemplateProperties.Date.FontName, "", imageCertTemplate.TemplateProperties.Date.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.Date.TextPosition.X, imageCertTemplate.TemplateProperties.Date.TextPosition.Y)
-				dateFontColor := imageCertTemplate.TemplateProperties.Date.FontColor
-				pdf.SetTextColor(dateFontColor.R, dateFontColor.G, dateFontColor.B)
-				pdf.CellFormat(10, 10, simpleDate, "", 0, imageCertTemplate.TemplateProperties.Date.TextAlign, false, 0, "")
-
-				// UTC
-				simpleUTCTime := fullDate.Format("15:04")
-				// fmt.Println(simpleUTCTime)
-
-				pdf.SetFont(imageCertTemplate.TemplateProperties.UTC.FontName, "", imageCertTemplate.TemplateProperties.UTC.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.UTC.TextPosition.X, imageCertTemplate.TemplateProperties.UTC.TextPosition.Y)
-				utcFontColor := imageCertTemplate.TemplateProperties.UTC.FontColor
-				pdf.SetTextColor(utcFontColor.R, utcFontColor.G, utcFontColor.B)
-				pdf.CellFormat(10, 10, simpleUTCTime, "", 0, imageCertTemplate.TemplateProperties.UTC.TextAlign, false, 0, "")
-
-				// FREQUENCY
-				pdf.SetFont(imageCertTemplate.TemplateProperties.Frequency.FontName, "", imageCertTemplate.TemplateProperties.Frequency.FontSize)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.Frequency.FontColor.R, imageCertTemplate.TemplateProperties.Frequency.FontColor.G, imageCertTemplate.TemplateProperties.Frequency.FontColor.B)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.Frequency.TextPosition.X, imageCertTemplate.TemplateProperties.Frequency.TextPosition.Y)
-				pdf.CellFormat(10, 10, identityAttribute.Frequency, "", 0, imageCertTemplate.TemplateProperties.Frequency.TextAlign, false, 0, "")
-
-				// MODE
-				pdf.SetFont(imageCertTemplate.TemplateProperties.Mode.FontName, "", imageCertTemplate.TemplateProperties.Mode.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.Mode.TextPosition.X, imageCertTemplate.TemplateProperties.Mode.TextPosition.Y)
-				modeFontColor := imageCertTemplate.TemplateProperties.Mode.FontColor
-				pdf.SetTextColor(modeFontColor.R, modeFontColor.G, modeFontColor.B)
-				pdf.CellFormat(10, 10, identityAttribute.Mode, "", 0, imageCertTemplate.TemplateProperties.Mode.TextAlign, false, 0, "")
-
-				// RST
-				pdf.SetFont(imageCertTemplate.TemplateProperties.RST.FontName, "", imageCertTemplate.TemplateProperties.RST.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.RST.TextPosition.X, imageCertTemplate.TemplateProperties.RST.TextPosition.Y)
-				rstFontColor := imageCertTemplate.TemplateProperties.RST.FontColor
-				pdf.SetTextColor(rstFontColor.R, rstFontColor.G, rstFontColor.B)
-				pdf.CellFormat(10, 10, identityAttribute.RST, "", 0, imageCertTemplate.TemplateProperties.RST.TextAlign, false, 0, "")
-			}
-		}
-	} else if imageCertTemplate.TemplateProperties.TemplateType == "TYPE 5" {
-		handler = func(imageCertTemplate models.ImageCertTemplate) func() {
-			return func() {
-				// pdf.Image("./assets/templates/template1.jpg", 0, 0, 297, 200, true, "", 0, "")
-				pdf.ImageOptions(templatePath, 0, 0, 297, 210, false, gofpdf.ImageOptions{ImageType: fileType, ReadDpi: true}, 0, "")
-
-				// CALL SIGN
-				pdf.SetFont(imageCertTemplate.TemplateProperties.CallSign.FontName, "", imageCertTemplate.TemplateProperties.CallSign.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.CallSign.TextPosition.X, imageCertTemplate.TemplateProperties.CallSign.TextPosition.Y)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.CallSign.FontColor.R, imageCertTemplate.TemplateProperties.CallSign.FontColor.G, imageCertTemplate.TemplateProperties.CallSign.FontColor.B)
-				pdf.CellFormat(40, 10, identity.CallSign, "", 0, imageCertTemplate.TemplateProperties.CallSign.TextAlign, false, 0, "")
-
-				// NAME
-				pdf.SetFont(imageCertTemplate.TemplateProperties.IdentityName.FontName, "", imageCertTemplate.TemplateProperties.IdentityName.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.IdentityName.TextPosition.X, imageCertTemplate.TemplateProperties.IdentityName.TextPosition.Y)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.IdentityName.FontColor.R, imageCertTemplate.TemplateProperties.IdentityName.FontColor.G, imageCertTemplate.TemplateProperties.IdentityName.FontColor.B)
-				pdf.CellFormat(10, 10, identity.Name, "", 0, imageCertTemplate.TemplateProperties.IdentityName.TextAlign, false, 0, "")
-
-				// UTC
-				numericFullDate, _ := strconv.ParseInt(identityAttribute.Date, 10, 64)
-				fullDate := time.Unix(numericFullDate/1000, 0).UTC()
-
-				simpleUTCTime := fullDate.Format("15:04")
-
-				pdf.SetFont(imageCertTemplate.TemplateProperties.UTC.FontName, "", imageCertTemplate.TemplateProperties.UTC.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.UTC.TextPosition.X, imageCertTemplate.TemplateProperties.UTC.TextPosition.Y)
-				utcFontColor := imageCertTemplate.TemplateProperties.UTC.FontColor
-				pdf.SetTextColor(utcFontColor.R, utcFontColor.G, utcFontColor.B)
-				pdf.CellFormat(10, 10, simpleUTCTime, "", 0, imageCertTemplate.TemplateProperties.UTC.TextAlign, false, 0, "")
-
-				// FREQUENCY
-				pdf.SetFont(imageCertTemplate.TemplateProperties.Frequency.FontName, "", imageCertTemplate.TemplateProperties.Frequency.FontSize)
-				pdf.SetTextColor(imageCertTemplate.TemplateProperties.Frequency.FontColor.R, imageCertTemplate.TemplateProperties.Frequency.FontColor.G, imageCertTemplate.TemplateProperties.Frequency.FontColor.B)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.Frequency.TextPosition.X, imageCertTemplate.TemplateProperties.Frequency.TextPosition.Y)
-				pdf.CellFormat(10, 10, identityAttribute.Frequency, "", 0, imageCertTemplate.TemplateProperties.Frequency.TextAlign, false, 0, "")
-
-				// MODE
-				pdf.SetFont(imageCertTemplate.TemplateProperties.Mode.FontName, "", imageCertTemplate.TemplateProperties.Mode.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.Mode.TextPosition.X, imageCertTemplate.TemplateProperties.Mode.TextPosition.Y)
-				modeFontColor := imageCertTemplate.TemplateProperties.Mode.FontColor
-				pdf.SetTextColor(modeFontColor.R, modeFontColor.G, modeFontColor.B)
-				pdf.CellFormat(10, 10, identityAttribute.Mode, "", 0, imageCertTemplate.TemplateProperties.Mode.TextAlign, false, 0, "")
-
-				// RST
-				pdf.SetFont(imageCertTemplate.TemplateProperties.RST.FontName, "", imageCertTemplate.TemplateProperties.RST.FontSize)
-				pdf.SetXY(imageCertTemplate.TemplateProperties.RST.TextPosition.X, imageCertTemplate.TemplateProperties.RST.TextPosition.Y)
-				rstFontColor := imageCertTemplate.TemplateProperties.RST.FontColor
-				pdf.SetTextColor(rstFontColor.R, rstFontColor.G, rstFontColor.B)
-				pdf.CellFormat(10, 10, identityAttribute.RST, "", 0, imageCertTemplate.TemplateProperties.RST.TextAlign, false, 0, "")
-			}
+// PrintPDFPages method renders one page per certificate into a single PDF,
+// the fields of layout on top of the template image
+func (tool Tools) PrintPDFPages(pages []CertPage, templatePath, fileType string, w io.Writer, layout CertLayout) error {
+	pageValues := make([]CertValues, len(pages))
+	for i, page := range pages {
+		values, err := NewCertValues(page.CertNumber, page.Identity, page.IdentityIndex)
+		if err != nil {
+			return err
 		}
-	} else {
-		return errors.New("handler not found")
+		pageValues[i] = values
 	}
 
-	pdf.SetHeaderFunc(handler(imageCertTemplate))
-
-	err := pdf.Output(w)
+	pdf, width, height, err := layout.Page.newPDF()
 	if err != nil {
-		fmt.Println("ERROR CREATING PDF", err.Error())
-		return errors.New("error creating pdf")
+		return err
 	}
-	return err
+	layout.addFonts(pdf)
+
+	var values CertValues
+	pdf.SetHeaderFunc(func() {
+		layout.Page.drawBackground(pdf, templatePath, fileType, width, height)
+		layout.draw(pdf, values)
+	})
+	for _, values = range pageValues {
+		pdf.AddPage()
+	}
+
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("error creating pdf: %w", err)
+	}
+	return nil
 }
 
 // SaveImageFromB64 method
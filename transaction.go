@@ -0,0 +1,120 @@
+package gomongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/agustadewa/gomongo/modes"
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WithTransaction method runs fn inside a multi-document transaction.
+// Every Query* method called with txCtx joins the transaction. The whole
+// callback is retried on TransientTransactionError and the commit is retried
+// on UnknownTransactionCommitResult, so fn must be safe to run again.
+// Transactions need a replica set or a sharded cluster.
+func (adaptor *Adaptor) WithTransaction(ctx context.Context, fn func(txCtx context.Context) error, opts ...*options.TransactionOptions) error {
+	session, err := adaptor.Client.StartSession()
+	if err != nil {
+		return wrapError("WithTransaction", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	}, opts...)
+
+	return wrapError("WithTransaction", err)
+}
+
+// WithTransaction method runs fn holding the store, the other callers wait
+// until it ends, and restores every collection when it fails. fn uses the
+// store with txCtx from a single goroutine, like a mongo session.
+func (m *MemoryAdaptor) WithTransaction(ctx context.Context, fn func(txCtx context.Context) error, opts ...*options.TransactionOptions) error {
+	unlock := m.lock(ctx)
+	defer unlock()
+
+	snapshot := make(map[string][]bson.M, len(m.collections))
+	for collName, docs := range m.collections {
+		copied := make([]bson.M, len(docs))
+		for i, doc := range docs {
+			copied[i] = deepCopy(doc).(bson.M)
+		}
+		snapshot[collName] = copied
+	}
+
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, m)); err != nil {
+		m.collections = snapshot
+		return wrapError("WithTransaction", err)
+	}
+	return nil
+}
+
+// IssueCertificate method takes the next number of the certificate
+// Sequence of the event and frequency, the one RenderBatch numbers with,
+// stores it on the identity attribute and on the event attribute and logs
+// the download in a single transaction, so a failure leaves neither counter
+// nor log behind. A QSO flagged as a dupe gets no number, it fails with
+// ErrNotFound.
+func (adaptor *Adaptor) IssueCertificate(ctx context.Context, eventID, callSign, frequency, mode string, downloadLogData models.DownloadLog) (int, error) {
+	return IssueCertificate(ctx, adaptor, eventID, callSign, frequency, mode, downloadLogData)
+}
+
+// IssueCertificate function, see Adaptor.IssueCertificate
+func IssueCertificate(ctx context.Context, store Querier, eventID, callSign, frequency, mode string, downloadLogData models.DownloadLog) (int, error) {
+	var counter int
+
+	err := store.WithTransaction(ctx, func(txCtx context.Context) error {
+		seq, err := certificateSequence(txCtx, store, eventID, frequency, SequenceFormat{})
+		if err != nil {
+			return err
+		}
+		value, err := seq.NextValue(txCtx)
+		if err != nil {
+			return err
+		}
+		counter = int(value)
+
+		ok, err := setIdentityCounter(txCtx, store, eventID, callSign, frequency, mode, value)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// a dupe or an unknown QSO must not use up a number
+			return &Error{Op: "IssueCertificate", Kind: ErrNotFound, Err: fmt.Errorf("no QSO of %s on %s", callSign, frequency)}
+		}
+		// the event counter still tells how many certificates were issued
+		if err := setEventCounter(txCtx, store, eventID, frequency, value); err != nil {
+			return err
+		}
+		_, err = store.QueryInsertV3(txCtx, models.CollCertificateDownloadLog, downloadLogData)
+		return err
+	})
+
+	return counter, err
+}
+
+// setIdentityCounter stores counter on the attribute of callSign worked on
+// frequency and mode in eventID, attributes flagged as dupes are skipped. It
+// returns false when no attribute matched.
+func setIdentityCounter(ctx context.Context, store Querier, eventID, callSign, frequency, mode string, counter int64) (bool, error) {
+	attributeElem := bson.M{"frequency": frequency, "dupe": bson.M{"$ne": true}}
+	if mode != "" {
+		attributeElem["mode"] = modes.Filter(mode)
+	}
+	filter := bson.M{
+		"event_id":   eventID,
+		"call_sign":  callSign,
+		"attributes": bson.M{"$elemMatch": attributeElem},
+	}
+	update := bson.M{"$set": bson.M{"attributes.$.counter": counter}}
+
+	result := mongo.UpdateResult{}
+	if err := store.QueryUpdateOne(ctx, models.CollIdentity, &options.UpdateOptions{}, filter, update, &result); err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
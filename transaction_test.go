@@ -0,0 +1,88 @@
+package gomongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gitlab.com/yosiaagustadewa/qsl-service/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestIssueCertificate(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	eventID := primitive.NewObjectID()
+	if _, err := store.QueryInsertV3(ctx, models.CollEvent, bson.M{"_id": eventID, "attributes": bson.A{bson.M{"frequency": "7.135", "counter": 0}}}); err != nil {
+		t.Fatal(err)
+	}
+	// the same station worked in another event
+	qso := QSO{CallSign: "YB1BBB", Frequency: "7.135", Mode: "SSB", Date: time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)}
+	for _, id := range []string{"other-event", eventID.Hex()} {
+		if _, err := ImportQSOs(ctx, store, id, []QSO{qso}, ImportOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counter, err := IssueCertificate(ctx, store, eventID.Hex(), "YB1BBB", "7.135", "SSB", models.DownloadLog{CallSign: "YB1BBB"})
+	if err != nil || counter != 1 {
+		t.Fatalf("got %d (%v), want 1", counter, err)
+	}
+	for id, want := range map[string]int{eventID.Hex(): 1, "other-event": 0} {
+		var identity models.Identity
+		if err := store.QueryFindV2(ctx, models.CollIdentity, nil, bson.M{"event_id": id, "call_sign": "YB1BBB"}, &identity); err != nil {
+			t.Fatal(err)
+		}
+		if identity.Attributes[0].Counter != want {
+			t.Errorf("%s: got counter %d, want %d", id, identity.Attributes[0].Counter, want)
+		}
+	}
+	var event models.Event
+	if err := store.QueryFindV2(ctx, models.CollEvent, nil, bson.M{"_id": eventID}, &event); err != nil || event.Attributes[0].Counter != 1 {
+		t.Fatalf("got %+v (%v), want the event counter at 1", event.Attributes, err)
+	}
+
+	// an unknown QSO rolls back and does not use up a number
+	if _, err := IssueCertificate(ctx, store, eventID.Hex(), "JA1AAA", "7.135", "SSB", models.DownloadLog{CallSign: "JA1AAA"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	if logs, err := store.QueryCount(ctx, models.CollCertificateDownloadLog, bson.M{}); err != nil || logs != 1 {
+		t.Fatalf("got %d download logs (%v), want 1", logs, err)
+	}
+	if counter, err := IssueCertificate(ctx, store, eventID.Hex(), "YB1BBB", "7.135", "", models.DownloadLog{CallSign: "YB1BBB"}); err != nil || counter != 2 {
+		t.Fatalf("got %d (%v), want 2", counter, err)
+	}
+}
+
+func TestMemoryTransactionKeepsOtherWrites(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAdaptor()
+
+	written := make(chan error, 1)
+	err := store.WithTransaction(ctx, func(txCtx context.Context) error {
+		if _, err := store.QueryInsertV3(txCtx, "event", bson.M{"name": "rolled back"}); err != nil {
+			return err
+		}
+		// a write outside the transaction while it runs
+		go func() {
+			_, err := store.QueryInsertV3(ctx, "event", bson.M{"name": "kept"})
+			written <- err
+		}()
+		time.Sleep(50 * time.Millisecond)
+		return errors.New("abort")
+	})
+	if err == nil {
+		t.Fatal("want the transaction error")
+	}
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+
+	var events []bson.M
+	if err := store.QueryFindManyV2(ctx, "event", nil, bson.M{}, &events); err != nil || len(events) != 1 || events[0]["name"] != "kept" {
+		t.Fatalf("got %v (%v), want only the write outside the transaction", events, err)
+	}
+}